@@ -0,0 +1,78 @@
+// Command ai-cli-provider-echo is the reference provider plugin. It answers
+// every prompt by echoing the last user message back word by word, which makes
+// it useful for trying out the plugin protocol without a model server.
+//
+// Install it anywhere on PATH and use it as "ai-cli echo".
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/ahr9n/ai-cli/pkg/provider/plugin"
+)
+
+type echoProvider struct{}
+
+func (echoProvider) CreateCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions) (*provider.Completion, error) {
+	var collector provider.Collector
+	err := echoProvider{}.StreamCompletion(ctx, messages, opts, collector.Handle)
+	return collector.Completion(), err
+}
+
+func (echoProvider) StreamCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions, onEvent provider.StreamHandler) error {
+	var prompt string
+	for _, msg := range messages {
+		if msg.Role == prompts.RoleUser {
+			prompt = msg.Content
+		}
+	}
+	if prompt == "" {
+		return fmt.Errorf("no user message to echo")
+	}
+
+	words := strings.Fields(prompt)
+	for i, word := range words {
+		if i > 0 {
+			word = " " + word
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(20 * time.Millisecond):
+		}
+		onEvent(provider.StreamEvent{Type: provider.EventContent, Content: word})
+	}
+
+	onEvent(provider.StreamEvent{Type: provider.EventUsage, Usage: &provider.Usage{
+		PromptTokens:     len(words),
+		CompletionTokens: len(words),
+		TotalTokens:      2 * len(words),
+	}})
+	onEvent(provider.StreamEvent{Type: provider.EventFinish, FinishReason: provider.FinishStop})
+	return nil
+}
+
+func (echoProvider) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	return []provider.ModelInfo{{Name: "echo", Description: "Repeats the last user message"}}, nil
+}
+
+func (echoProvider) GetDefaultModel() string {
+	return "echo"
+}
+
+func (echoProvider) Name() string {
+	return "Echo"
+}
+
+func (echoProvider) Description() string {
+	return "Reference plugin that echoes the last user message"
+}
+
+func main() {
+	plugin.Main(echoProvider{})
+}
@@ -1,15 +1,38 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/ahr9n/ai-cli/pkg/cli"
+	"github.com/ahr9n/ai-cli/pkg/config"
 )
 
 func main() {
+	// Ctrl+C is left to the commands: chats use it to stop an answer
+	// rather than to end the process.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+
+	// Settings saved by versions before profiles move into the default
+	// profile on the first run.
+	if legacy, err := config.MigrateLegacy(); err != nil {
+		log.Printf("Warning: %v\n", err)
+	} else if legacy != "" {
+		fmt.Fprintf(os.Stderr, "Moved the settings in %s to the default profile\n", legacy)
+	}
+
 	cmd := cli.NewRootCommand()
-	if err := cmd.Execute(); err != nil {
+	err := cmd.ExecuteContext(ctx)
+	stop()
+	if errors.Is(err, cli.ErrInterrupted) {
+		os.Exit(cli.ExitInterrupted)
+	}
+	if err != nil {
 		log.Printf("Error executing command: %v\n", err)
 		os.Exit(1)
 	}
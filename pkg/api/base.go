@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,20 +12,33 @@ import (
 type BaseClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string
 }
 
-func (c *BaseClient) DoPost(path string, payload interface{}) (*http.Response, error) {
+// NewBaseClient returns a client for the server at baseURL. It has no
+// client-wide timeout; requests are bounded by their context.
+func NewBaseClient(baseURL string, headers map[string]string) *BaseClient {
+	return &BaseClient{
+		BaseURL:    baseURL,
+		HTTPClient: &http.Client{},
+		Headers:    headers,
+	}
+}
+
+func (c *BaseClient) DoPost(ctx context.Context, path string, payload interface{}) (*http.Response, error) {
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/%s", c.BaseURL, path), bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/%s", c.BaseURL, path), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	c.setHeaders(req)
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -34,11 +48,12 @@ func (c *BaseClient) DoPost(path string, payload interface{}) (*http.Response, e
 	return resp, nil
 }
 
-func (c *BaseClient) DoGet(path string) (*http.Response, error) {
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s", c.BaseURL, path), nil)
+func (c *BaseClient) DoGet(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s", c.BaseURL, path), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	c.setHeaders(req)
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -48,6 +63,12 @@ func (c *BaseClient) DoGet(path string) (*http.Response, error) {
 	return resp, nil
 }
 
+func (c *BaseClient) setHeaders(req *http.Request) {
+	for key, value := range c.Headers {
+		req.Header.Set(key, value)
+	}
+}
+
 func (c *BaseClient) HandleError(resp *http.Response) error {
 	if resp.StatusCode == http.StatusNotFound {
 		return fmt.Errorf("resource not found")
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/files"
+)
+
+// refPrefix marks a file reference in a prompt, as in "explain @main.go".
+const refPrefix = "@"
+
+// Limits on attached files unless --file-limit and --files-total-limit say
+// otherwise.
+const (
+	defaultFileLimit       = 256 << 10
+	defaultFilesTotalLimit = 1 << 20
+)
+
+// refPattern finds words starting with refPrefix.
+var refPattern = regexp.MustCompile(`(^|\s)` + regexp.QuoteMeta(refPrefix) + `(\S+)`)
+
+// findRefs returns the files of the references in text that name existing
+// files, directories or globs with matches, and text with the prefix of
+// those references removed. Other words starting with the prefix, such as
+// handles, are left alone. Punctuation after a reference is not part of it
+// unless the file's name has it too. A reference to the whole filesystem or
+// home directory is an error, as it is with --file.
+func findRefs(text string) (string, []string, error) {
+	var b strings.Builder
+	var paths []string
+	last := 0
+	for _, m := range refPattern.FindAllStringSubmatchIndex(text, -1) {
+		word := text[m[4]:m[5]]
+		ref := word
+		var matches []string
+		for ref != "" {
+			var err error
+			if matches, err = files.Match(ref); err == nil {
+				break
+			}
+			if errors.Is(err, files.ErrTooBroad) {
+				return "", nil, err
+			}
+			trimmed := strings.TrimRight(ref, ".,;:!?)]}'\"")
+			if trimmed == ref {
+				ref = ""
+				break
+			}
+			ref = trimmed
+		}
+		if ref == "" {
+			continue
+		}
+		paths = append(paths, matches...)
+		b.WriteString(text[last : m[4]-len(refPrefix)])
+		last = m[4]
+	}
+	b.WriteString(text[last:])
+	return b.String(), paths, nil
+}
+
+// attachFiles appends the files given with --file and those referenced in
+// prompt to it, each in a fenced block headed by its path, and lists what
+// was attached and skipped on out.
+func attachFiles(prompt string, paths []string, opts *ChatOptions, out io.Writer) (string, error) {
+	prompt, referenced, err := findRefs(prompt)
+	if err != nil {
+		return "", fmt.Errorf("cannot attach files: %w", err)
+	}
+	if len(paths)+len(referenced) == 0 {
+		return prompt, nil
+	}
+
+	var matched []string
+	for _, path := range paths {
+		matches, err := files.Match(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot attach files: %w", err)
+		}
+		matched = append(matched, matches...)
+	}
+	expansion := files.Read(append(matched, referenced...), files.Limits{
+		MaxFileSize:  opts.FileLimit,
+		MaxTotalSize: opts.FilesTotalLimit,
+	})
+	if !opts.Quiet {
+		listAttachments(out, expansion)
+	}
+
+	var b strings.Builder
+	b.WriteString(prompt)
+	for _, f := range expansion.Files {
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		lang := strings.TrimPrefix(filepath.Ext(f.Path), ".")
+		fmt.Fprintf(&b, "File: %s\n%s", filepath.ToSlash(f.Path), fence(lang, f.Content))
+	}
+	return b.String(), nil
+}
+
+// listAttachments tells the user which files go with the prompt.
+func listAttachments(out io.Writer, expansion *files.Expansion) {
+	if n := len(expansion.Files); n > 0 {
+		noun := "files"
+		if n == 1 {
+			noun = "file"
+		}
+		fmt.Fprintf(out, "Attaching %d %s (%s):\n", n, noun, formatSize(expansion.Size()))
+		for _, f := range expansion.Files {
+			fmt.Fprintf(out, "  %s (%s)\n", f.Path, formatSize(int64(len(f.Content))))
+		}
+	}
+	for _, s := range expansion.Skipped {
+		reason := s.Reason
+		if s.Size > 0 {
+			reason += ", " + formatSize(s.Size)
+		}
+		fmt.Fprintf(out, "Skipping %s: %s\n", s.Path, reason)
+	}
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ahr9n/ai-cli/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindRefs(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n"), 0644))
+	require.NoError(t, os.WriteFile("notes.txt", []byte("notes\n"), 0644))
+
+	text, paths, err := findRefs("explain @main.go, ask @someone about @notes.txt.")
+	require.NoError(t, err)
+	assert.Equal(t, "explain main.go, ask @someone about notes.txt.", text)
+	assert.Equal(t, []string{"main.go", "notes.txt"}, paths)
+
+	for _, ref := range []string{"@/", "@~", "@~/", "@~,", "@/*"} {
+		_, _, err := findRefs("read " + ref + " please")
+		assert.ErrorIs(t, err, files.ErrTooBroad, ref)
+	}
+
+	var out bytes.Buffer
+	_, err = attachFiles("everything in @~", nil, &ChatOptions{}, &out)
+	assert.ErrorIs(t, err, files.ErrTooBroad)
+	assert.ErrorContains(t, err, "cannot attach files: read "+filepath.Clean(dir))
+	assert.Empty(t, out.String())
+}
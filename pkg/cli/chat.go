@@ -1,30 +1,69 @@
 package cli
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"os"
-	"strings"
+	"time"
 
 	"github.com/ahr9n/ai-cli/pkg/prompts"
 	"github.com/ahr9n/ai-cli/pkg/provider"
 	"github.com/ahr9n/ai-cli/pkg/utils"
 )
 
-func runChat(p provider.Provider, opts *ChatOptions, args []string) error {
+func runChat(ctx context.Context, p provider.Provider, opts *ChatOptions, args []string) error {
+	mode, err := resolveReasoningMode(opts.Reasoning)
+	if err != nil {
+		return err
+	}
+	opts.Reasoning = mode
+
 	if opts.Interactive {
-		return runInteractiveMode(p, opts)
+		if opts.Compaction, err = validateCompaction(opts.Compaction); err != nil {
+			return err
+		}
+		if opts.Output != OutputText {
+			return fmt.Errorf("--output %s is not supported in interactive mode", opts.Output)
+		}
+		if opts.Editor {
+			return fmt.Errorf("--editor cannot be used with -i; use /edit instead")
+		}
+		if opts.NoStream {
+			return fmt.Errorf("--no-stream is only for single prompts; interactive mode always streams")
+		}
+		// Interactive mode reads the conversation itself from stdin.
+		for _, arg := range args {
+			if arg == stdinArg {
+				return fmt.Errorf("%q cannot be used with -i, which reads the conversation from stdin", stdinArg)
+			}
+		}
+		if imageFromStdin(opts) {
+			return fmt.Errorf("--image %s cannot be used with -i, which reads the conversation from stdin", stdinArg)
+		}
+		return runInteractiveMode(ctx, p, opts)
 	}
 
-	if len(args) == 0 {
-		return fmt.Errorf("please provide a prompt or use -i for interactive mode")
+	// Images are read first, as one of them may come from stdin.
+	images, err := loadImages(opts.Images)
+	if err != nil {
+		return err
+	}
+	prompt, err := promptFromArgs(ctx, opts, args)
+	if err != nil {
+		return err
 	}
+	return handleSinglePrompt(ctx, p, prompt, images, opts)
+}
 
-	prompt := strings.Join(args, " ")
-	return handleSinglePrompt(p, prompt, opts)
+// withTimeout bounds ctx by the --timeout flag; a zero timeout leaves it unbounded.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
-func handleSinglePrompt(p provider.Provider, prompt string, opts *ChatOptions) error {
+func handleSinglePrompt(ctx context.Context, p provider.Provider, prompt string, images []provider.Image, opts *ChatOptions) error {
 	messages := []provider.Message{}
 
 	if opts.SystemPrompt != "" {
@@ -36,111 +75,114 @@ func handleSinglePrompt(p provider.Provider, prompt string, opts *ChatOptions) e
 	messages = append(messages, provider.Message{
 		Role:    prompts.RoleUser,
 		Content: prompt,
+		Images:  images,
 	})
 
-	loader := utils.InitLoader(utils.Dots)
-	response, err := p.CreateCompletion(messages, &provider.CompletionOptions{
-		Model:       opts.Model,
-		Temperature: opts.Temperature,
-	})
+	ctx, cancel := withTimeout(ctx, opts.Timeout)
+	defer cancel()
 
-	loader.Stop()
-	if err != nil {
-		return fmt.Errorf("chat completion failed: %w", err)
+	if opts.Output != OutputText {
+		return writeCompletion(ctx, p, messages, opts)
 	}
 
-	fmt.Println(response)
-
-	return nil
-}
-
-func runInteractiveMode(p provider.Provider, opts *ChatOptions) error {
-	fmt.Printf("Starting interactive chat mode with %s (type 'exit' or 'quit' to quit, 'clear' to reset history)\n", p.Name())
-	fmt.Printf("Model: %s\n", opts.Model)
-	if opts.MaxHistory > 0 {
-		fmt.Printf("Message history limit: %d messages\n", opts.MaxHistory)
-	}
+	// The answer is printed as it streams in unless --no-stream asks for all
+	// of it at once. Either way Ctrl+C still prints what arrived before it.
+	interrupts := newInterrupter()
+	defer interrupts.Stop()
+	ctx, release := interrupts.Context(ctx)
 
-	var messages []provider.Message
-	if opts.SystemPrompt != "" {
-		messages = append(messages, provider.Message{
-			Role:    prompts.RoleSystem,
-			Content: opts.SystemPrompt,
-		})
-		fmt.Printf("System prompt: %s\n", opts.SystemPrompt)
+	completionOpts := &provider.CompletionOptions{
+		Model:       opts.Model,
+		Temperature: opts.Temperature,
 	}
-	scanner := bufio.NewScanner(os.Stdin)
-
-	for {
-		fmt.Print("\nYou: ")
-		if !scanner.Scan() {
-			break
-		}
-
-		input := scanner.Text()
-		if input == "" {
-			continue
-		}
-
-		switch strings.ToLower(input) {
-		case "exit", "quit":
-			return nil
-		case "clear":
-			if opts.SystemPrompt != "" {
-				messages = []provider.Message{{
-					Role:    prompts.RoleSystem,
-					Content: opts.SystemPrompt,
-				}}
-			} else {
-				messages = []provider.Message{}
+	printer := newResponsePrinter(opts)
+	loader := startLoader(opts)
+
+	var response *provider.Completion
+	var err error
+	started := false
+	if opts.NoStream {
+		response, err = p.CreateCompletion(ctx, messages, completionOpts)
+	} else {
+		var collector provider.Collector
+		err = p.StreamCompletion(ctx, messages, completionOpts, func(event provider.StreamEvent) {
+			collector.Handle(event)
+			visible := event.Type == provider.EventContent ||
+				(event.Type == provider.EventReasoning && opts.Reasoning != ReasoningHide)
+			if !visible {
+				return
 			}
-			fmt.Println("Conversation history cleared")
-			continue
-		}
 
-		messages = append(messages, provider.Message{
-			Role:    prompts.RoleUser,
-			Content: input,
-		})
-		loader := utils.InitLoader(utils.Dots)
-
-		var response strings.Builder
-		err := p.StreamCompletion(messages, &provider.CompletionOptions{
-			Model:       opts.Model,
-			Temperature: opts.Temperature,
-		}, func(chunk string) {
-			if response.Len() == 0 {
+			if !started {
+				started = true
 				loader.Stop()
-				fmt.Print("\nAssistant: ")
 			}
-			fmt.Print(chunk)
-			response.WriteString(chunk)
+			if event.Type == provider.EventReasoning {
+				printer.Reasoning(event.Content)
+			} else {
+				printer.Content(event.Content)
+			}
 		})
-		loader.Stop()
+		response = collector.Completion()
+	}
+	interrupted := release()
+	loader.Stop()
 
-		if err != nil {
-			fmt.Printf("\nError: %v\n", err)
-			continue
+	if err != nil && !interrupted {
+		// Keep what was streamed before the error on its own line.
+		if started {
+			printer.Finish()
 		}
-		fmt.Println()
+		return fmt.Errorf("chat completion failed: %w", err)
+	}
+	if opts.NoStream && response != nil {
+		printer.Reasoning(response.Reasoning)
+		printer.Content(response.Content)
+	}
+	printer.Finish()
+	if interrupted {
+		return interruptedError(opts)
+	}
+	reportCompletion(response, opts)
 
-		messages = append(messages, provider.Message{
-			Role:    prompts.RoleAssistant,
-			Content: response.String(),
-		})
+	return nil
+}
 
-		if opts.MaxHistory > 0 && len(messages) > opts.MaxHistory {
-			if messages[0].Role == prompts.RoleSystem {
-				messages = append([]provider.Message{messages[0]}, messages[len(messages)-opts.MaxHistory+1:]...)
-			} else {
-				messages = messages[len(messages)-opts.MaxHistory:]
-			}
-		}
+// interruptedError tells the user that the answer was cut short and returns
+// ErrInterrupted.
+func interruptedError(opts *ChatOptions) error {
+	if !opts.Quiet {
+		fmt.Fprintln(os.Stderr, "[interrupted]")
 	}
+	return ErrInterrupted
+}
+
+// startLoader shows the progress spinner on stderr while waiting for a
+// response, unless the output flags ask for quiet.
+func startLoader(opts *ChatOptions) *utils.Loader {
+	return utils.StartLoader(opts.Spinner, utils.LoaderOptions{
+		Quiet:   opts.Quiet,
+		NoColor: opts.NoColor,
+	})
+}
 
-	if scanner.Err() != nil {
-		return fmt.Errorf("input error: %w", scanner.Err())
+// reportCompletion tells the user about truncated answers and, with --stats,
+// prints the usage reported by the backend. --quiet drops the notice. It
+// writes to stderr so that the answer on stdout stays clean.
+func reportCompletion(c *provider.Completion, opts *ChatOptions) {
+	if c.Truncated() && !opts.Quiet {
+		fmt.Fprintln(os.Stderr, "[response truncated: the model reached its length limit]")
+	}
+	if !opts.Stats || c.Usage == nil {
+		return
 	}
 
-	return nil
+	stats := fmt.Sprintf("[tokens: %d prompt, %d completion", c.Usage.PromptTokens, c.Usage.CompletionTokens)
+	if tps := c.Usage.TokensPerSecond(); tps > 0 {
+		stats += fmt.Sprintf(", %.1f tokens/s", tps)
+	}
+	if c.Usage.TotalDuration > 0 {
+		stats += fmt.Sprintf(", %s total", c.Usage.TotalDuration.Round(time.Millisecond))
+	}
+	fmt.Fprintln(os.Stderr, stats+"]")
 }
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/ahr9n/ai-cli/pkg/tokens"
+)
+
+// Compaction strategies for the --compaction flag: what happens to earlier
+// messages when the conversation outgrows the context window.
+const (
+	// CompactDrop drops the oldest messages.
+	CompactDrop = "drop"
+	// CompactSummarize replaces the oldest messages with a summary written
+	// by the model.
+	CompactSummarize = "summarize"
+	// CompactWindow keeps only the latest messages, --max-history of them
+	// or defaultWindow, and drops older ones as well when they do not fit.
+	CompactWindow = "window"
+)
+
+// defaultWindow is the number of messages the window strategy keeps when
+// --max-history is not set.
+const defaultWindow = 20
+
+// summaryTemperature keeps summaries close to what was said.
+const summaryTemperature = 0.2
+
+const summarizePrompt = `You condense conversations so that they can be continued without the original messages.
+Write a concise summary of the conversation you are given, in the language it is written in.
+Keep decisions, conclusions, facts, names, file names, code identifiers, errors and open questions.
+Leave out pleasantries. Reply with the summary only.`
+
+// summaryPrefix starts the message holding a summary, so that the model
+// knows what it is reading.
+const summaryPrefix = "Summary of the earlier conversation:\n\n"
+
+// validateCompaction checks the --compaction flag.
+func validateCompaction(strategy string) (string, error) {
+	switch strategy {
+	case "":
+		return CompactDrop, nil
+	case CompactDrop, CompactSummarize, CompactWindow:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("invalid compaction strategy %q (use drop, summarize or window)", strategy)
+	}
+}
+
+// historyLimit returns the number of messages to keep regardless of their
+// size, or zero for no limit.
+func (s *chatState) historyLimit() int {
+	if s.opts.MaxHistory > 0 {
+		return s.opts.MaxHistory
+	}
+	if s.opts.Compaction == CompactWindow {
+		return defaultWindow
+	}
+	return 0
+}
+
+// fitContext makes the conversation fit the token budget of the model's
+// context window, using the compaction strategy.
+func (s *chatState) fitContext(ctx context.Context, p provider.Provider) {
+	length, _ := s.contextLength(ctx)
+	budget := tokens.Budget(length)
+	if budget <= 0 {
+		return
+	}
+
+	tokenizer := s.tokenizer()
+	if tokens.CountMessages(tokenizer, s.messages) <= budget {
+		return
+	}
+
+	if s.opts.Compaction == CompactSummarize {
+		summarized, err := s.summarize(ctx, p, budget)
+		if err == nil {
+			s.notice("[summarized %d earlier messages to fit the %d-token context window]", summarized, length)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Warning: could not summarize earlier messages, dropping them instead: %v\n", err)
+	}
+
+	trimmed, dropped := tokens.Trim(tokenizer, s.messages, budget)
+	if dropped > 0 {
+		s.messages = trimmed
+		s.notice("[dropped %d earlier messages to fit the %d-token context window]", dropped, length)
+	}
+}
+
+// summarize replaces the oldest messages, and any earlier summary, with a
+// summary written by the model. It compacts down to half of budget so that
+// the next few questions fit without another summary. It returns the number
+// of messages replaced.
+func (s *chatState) summarize(ctx context.Context, p provider.Provider, budget int) (int, error) {
+	tokenizer := s.tokenizer()
+	lead := leadingSystemMessages(s.messages)
+	_, count := tokens.Trim(tokenizer, s.messages, budget/2)
+	if count == 0 {
+		return 0, fmt.Errorf("only the latest question is left")
+	}
+	old := s.messages[lead : lead+count]
+
+	var transcript strings.Builder
+	var systemPrompt []provider.Message
+	for _, msg := range s.messages[:lead] {
+		if msg.Summary {
+			fmt.Fprintf(&transcript, "Earlier summary:\n%s\n\n", strings.TrimPrefix(msg.Content, summaryPrefix))
+		} else {
+			systemPrompt = append(systemPrompt, msg)
+		}
+	}
+	for _, msg := range old {
+		fmt.Fprintf(&transcript, "%s: %s\n\n", roleLabel(msg.Role), msg.Content)
+	}
+
+	loader := startLoader(s.opts)
+	loader.SetMessage("Summarizing earlier messages")
+	response, err := p.CreateCompletion(ctx, []provider.Message{
+		{Role: prompts.RoleSystem, Content: summarizePrompt},
+		{Role: prompts.RoleUser, Content: transcript.String()},
+	}, &provider.CompletionOptions{
+		Model:       s.opts.Model,
+		Temperature: summaryTemperature,
+	})
+	loader.Stop()
+	if err != nil {
+		return 0, err
+	}
+	summary := strings.TrimSpace(response.Content)
+	if summary == "" {
+		return 0, fmt.Errorf("the model returned an empty summary")
+	}
+
+	messages := append(systemPrompt, provider.Message{
+		Role:    prompts.RoleSystem,
+		Content: summaryPrefix + summary,
+		Summary: true,
+	})
+	s.messages = append(messages, s.messages[lead+count:]...)
+	return count, nil
+}
+
+// notice tells the user about changes to the history, unless --quiet.
+func (s *chatState) notice(format string, args ...any) {
+	if !s.opts.Quiet {
+		fmt.Fprintf(s.out, format+"\n", args...)
+	}
+}
+
+// leadingSystemMessages counts the system messages at the start of the
+// history: the system prompt and the summary of earlier messages.
+func leadingSystemMessages(messages []provider.Message) int {
+	n := 0
+	for n < len(messages) && messages[n].Role == prompts.RoleSystem {
+		n++
+	}
+	return n
+}
+
+// roleLabel names the author of a message in transcripts.
+func roleLabel(role string) string {
+	switch role {
+	case prompts.RoleUser:
+		return "User"
+	case prompts.RoleAssistant:
+		return "Assistant"
+	default:
+		return "System"
+	}
+}
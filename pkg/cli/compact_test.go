@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// summaryProvider answers every completion with a fixed summary, or fails
+// with err, and keeps the requests it got.
+type summaryProvider struct {
+	fakeProvider
+	summary  string
+	err      error
+	requests [][]provider.Message
+}
+
+func (p *summaryProvider) CreateCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions) (*provider.Completion, error) {
+	p.requests = append(p.requests, messages)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &provider.Completion{Content: p.summary}, nil
+}
+
+// newCompactChat returns a conversation of seven short messages after the
+// system prompt, each worth five tokens to the heuristic tokenizer, in a
+// 40-token context window that leaves 30 tokens for the conversation.
+func newCompactChat(t *testing.T, strategy string) *chatState {
+	t.Helper()
+	s, _ := newTestChat(t)
+	s.opts.SystemPrompt = "sys"
+	s.opts.ContextLength = 40
+	s.opts.Compaction = strategy
+	s.messages = []provider.Message{
+		{Role: prompts.RoleSystem, Content: "sys"},
+		{Role: prompts.RoleUser, Content: "u1"},
+		{Role: prompts.RoleAssistant, Content: "a1"},
+		{Role: prompts.RoleUser, Content: "u2"},
+		{Role: prompts.RoleAssistant, Content: "a2"},
+		{Role: prompts.RoleUser, Content: "u3"},
+		{Role: prompts.RoleAssistant, Content: "a3"},
+		{Role: prompts.RoleUser, Content: "u4"},
+	}
+	return s
+}
+
+func TestFitContextDrop(t *testing.T) {
+	s := newCompactChat(t, CompactDrop)
+	s.opts.Quiet = false
+	out := s.out.(interface{ String() string })
+	p := &summaryProvider{summary: "unused"}
+
+	s.fitContext(context.Background(), p)
+	assert.Equal(t, []string{"system: sys", "user: u2", "assistant: a2", "user: u3", "assistant: a3", "user: u4"}, contents(s.messages))
+	assert.Equal(t, "[dropped 2 earlier messages to fit the 40-token context window]\n", out.String())
+	assert.Empty(t, p.requests, "dropping asks no model")
+
+	// Once it fits, nothing changes.
+	s.fitContext(context.Background(), p)
+	assert.Len(t, s.messages, 6)
+}
+
+func TestFitContextSummarize(t *testing.T) {
+	s := newCompactChat(t, CompactSummarize)
+	s.opts.Quiet = false
+	out := s.out.(interface{ String() string })
+	p := &summaryProvider{summary: "  They said hello.\n"}
+
+	s.fitContext(context.Background(), p)
+	assert.Equal(t, []string{"system: sys", "system: " + summaryPrefix + "They said hello.", "user: u4"}, contents(s.messages))
+	assert.True(t, s.messages[1].Summary)
+	assert.False(t, s.messages[0].Summary, "the system prompt stays as it was")
+	assert.Equal(t, "[summarized 6 earlier messages to fit the 40-token context window]\n", out.String())
+
+	require.Len(t, p.requests, 1)
+	request := p.requests[0]
+	assert.Equal(t, summarizePrompt, request[0].Content)
+	assert.Equal(t, "User: u1\n\nAssistant: a1\n\nUser: u2\n\nAssistant: a2\n\nUser: u3\n\nAssistant: a3\n\n", request[1].Content)
+
+	// A later summary takes in the earlier one instead of adding another.
+	s.messages = append(s.messages,
+		provider.Message{Role: prompts.RoleAssistant, Content: "a4"},
+		provider.Message{Role: prompts.RoleUser, Content: "u5"},
+		provider.Message{Role: prompts.RoleAssistant, Content: "a5"},
+		provider.Message{Role: prompts.RoleUser, Content: "u6"},
+	)
+	p.summary = "Longer story."
+	s.fitContext(context.Background(), p)
+	assert.Equal(t, []string{"system: sys", "system: " + summaryPrefix + "Longer story.", "user: u6"}, contents(s.messages))
+	require.Len(t, p.requests, 2)
+	assert.Equal(t, "Earlier summary:\nThey said hello.\n\nUser: u4\n\nAssistant: a4\n\nUser: u5\n\nAssistant: a5\n\n", p.requests[1][1].Content)
+}
+
+func TestFitContextSummarizeFailure(t *testing.T) {
+	for name, p := range map[string]*summaryProvider{
+		"error":         {err: errors.New("model crashed")},
+		"empty summary": {summary: " \n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newCompactChat(t, CompactSummarize)
+			s.fitContext(context.Background(), p)
+			assert.Equal(t, []string{"system: sys", "user: u2", "assistant: a2", "user: u3", "assistant: a3", "user: u4"}, contents(s.messages),
+				"the oldest messages are dropped instead")
+		})
+	}
+}
+
+func TestFitContextWindow(t *testing.T) {
+	s := newCompactChat(t, CompactWindow)
+	s.opts.MaxHistory = 5
+
+	// The window applies after every answer, whatever the size.
+	s.trimHistory()
+	assert.Equal(t, []string{"system: sys", "assistant: a2", "user: u3", "assistant: a3", "user: u4"}, contents(s.messages))
+
+	// Messages that do not fit are dropped as well.
+	s.opts.ContextLength = 28
+	s.fitContext(context.Background(), &summaryProvider{})
+	assert.Equal(t, []string{"system: sys", "user: u3", "assistant: a3", "user: u4"}, contents(s.messages))
+	assert.Equal(t, prompts.RoleSystem, s.messages[0].Role)
+}
+
+func TestHistoryLimit(t *testing.T) {
+	s := newCompactChat(t, CompactWindow)
+	assert.Equal(t, defaultWindow, s.historyLimit())
+	s.opts.MaxHistory = 3
+	assert.Equal(t, 3, s.historyLimit())
+	s.opts.MaxHistory = 0
+	s.opts.Compaction = CompactDrop
+	assert.Equal(t, 0, s.historyLimit())
+}
+
+func TestFitContextUnknownWindow(t *testing.T) {
+	s := newCompactChat(t, CompactDrop)
+	s.opts.ContextLength = 0
+	s.fitContext(context.Background(), &summaryProvider{})
+	assert.Len(t, s.messages, 8, "without a known context window nothing is dropped")
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ahr9n/ai-cli/pkg/lineedit"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/ahr9n/ai-cli/pkg/session"
+)
+
+// modelListTimeout bounds the one request made to complete model names.
+const modelListTimeout = 5 * time.Second
+
+// completer completes interactive input: slash command names, their
+// arguments, and paths.
+type completer struct {
+	commands *slashRegistry
+	provider provider.Provider
+	store    *session.Store
+
+	modelsOnce sync.Once
+	models     []string
+}
+
+// Complete returns the candidates for line, each a full replacement of it.
+func (c *completer) Complete(line string) []string {
+	if !strings.HasPrefix(line, "/") {
+		return c.completeWord(line, lineedit.CompletePath)
+	}
+
+	name, args, hasArgs := strings.Cut(line[1:], " ")
+	if !hasArgs {
+		var names []string
+		for _, cmd := range c.commands.Commands() {
+			names = append(names, "/"+cmd.Name)
+		}
+		return lineedit.CompleteWords(line, names)
+	}
+
+	prefix := "/" + name + " "
+	var candidates []string
+	switch name {
+	case "model":
+		candidates = lineedit.CompleteWords(args, c.modelNames())
+	case "load":
+		candidates = lineedit.CompleteWords(args, c.sessionIDs())
+	case "preset":
+		candidates = lineedit.CompleteWords(args, []string{"creative", "concise", "code", "default"})
+	case "export", "image":
+		candidates = lineedit.CompletePath(args)
+	default:
+		return c.completeWord(line, lineedit.CompletePath)
+	}
+	for i := range candidates {
+		candidates[i] = prefix + candidates[i]
+	}
+	return candidates
+}
+
+// completeWord completes the last word of line when it looks like a path
+// or is a file reference.
+func (c *completer) completeWord(line string, complete func(string) []string) []string {
+	start := strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+	if strings.HasPrefix(word, refPrefix) {
+		start += len(refPrefix)
+		word = word[len(refPrefix):]
+	} else if !looksLikePath(word) {
+		return nil
+	}
+	candidates := complete(word)
+	for i := range candidates {
+		candidates[i] = line[:start] + candidates[i]
+	}
+	return candidates
+}
+
+func looksLikePath(word string) bool {
+	return strings.HasPrefix(word, "/") || strings.HasPrefix(word, "./") ||
+		strings.HasPrefix(word, "../") || strings.HasPrefix(word, "~/")
+}
+
+// modelNames asks the provider for its models the first time they are
+// needed. A failure just leaves nothing to complete.
+func (c *completer) modelNames() []string {
+	c.modelsOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), modelListTimeout)
+		defer cancel()
+		models, err := c.provider.ListModels(ctx)
+		if err != nil {
+			return
+		}
+		for _, m := range models {
+			c.models = append(c.models, m.Name)
+		}
+	})
+	return c.models
+}
+
+func (c *completer) sessionIDs() []string {
+	ids := []string{session.Last}
+	if c.store == nil {
+		return ids
+	}
+	sessions, err := c.store.List()
+	if err != nil {
+		return ids
+	}
+	for _, sess := range sessions {
+		ids = append(ids, sess.ID)
+	}
+	return ids
+}
+
+// historyFile returns where interactive input is remembered, next to the
+// saved sessions.
+func historyFile() string {
+	dir, err := session.DefaultDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(dir), "history")
+}
@@ -0,0 +1,461 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ahr9n/ai-cli/pkg/config"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/spf13/cobra"
+)
+
+// settingFlags maps settings to the chat command flags they provide defaults
+// for.
+var settingFlags = map[string]string{
+	"url":            "url",
+	"model":          "model",
+	"temperature":    "temperature",
+	"system_prompt":  "system",
+	"preset":         "preset",
+	"max_history":    "max-history",
+	"context_length": "context-length",
+	"compaction":     "compaction",
+	"timeout":        "timeout",
+	"reasoning":      "reasoning",
+	"api_key":        "api-key",
+	"base_path":      "base-path",
+}
+
+// profileFlag returns the value of the global --profile flag.
+func profileFlag(cmd *cobra.Command) string {
+	if f := cmd.Flag("profile"); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
+
+// providerEnvLayer holds the settings read from the provider's own
+// environment variables, such as OLLAMA_HOST and OPENAI_API_KEY.
+func providerEnvLayer(reg provider.Registration) config.Layer {
+	layer := config.Layer{
+		Source:   "env",
+		Provider: string(reg.Type),
+		Settings: &config.Profile{},
+		Vars:     make(map[string]string),
+	}
+
+	if reg.URLEnv != "" {
+		if url := os.Getenv(reg.URLEnv); url != "" {
+			if !strings.Contains(url, "://") {
+				url = "http://" + url
+			}
+			layer.Settings.URL = url
+			layer.Vars["url"] = reg.URLEnv
+		}
+	}
+	if reg.APIKeyEnv != "" {
+		if key := os.Getenv(reg.APIKeyEnv); key != "" {
+			layer.Settings.APIKey = key
+			layer.Vars["api_key"] = reg.APIKeyEnv
+		}
+	}
+	return layer
+}
+
+// resolveSettings merges the configuration layers for a provider.
+func resolveSettings(cmd *cobra.Command, reg provider.Registration) (*config.Resolved, error) {
+	layers, err := config.Layers(profileFlag(cmd), providerEnvLayer(reg))
+	if err != nil {
+		return nil, err
+	}
+	return config.Resolve(layers, string(reg.Type)), nil
+}
+
+// applySettings uses the resolved settings for every flag not given on the
+// command line.
+func applySettings(cmd *cobra.Command, resolved *config.Resolved) error {
+	return applySettingsTo(cmd, resolved, settingFlags)
+}
+
+// applySettingsTo is applySettings for a command whose flags take their
+// defaults from other settings, as given by flags.
+func applySettingsTo(cmd *cobra.Command, resolved *config.Resolved, flags map[string]string) error {
+	for _, key := range config.Keys {
+		setting, ok := resolved.Get(key)
+		if !ok {
+			continue
+		}
+		name, ok := flags[key]
+		if !ok {
+			continue
+		}
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		if err := f.Value.Set(setting.Value); err != nil {
+			return fmt.Errorf("invalid %s from %s: %w", key, setting.Source, err)
+		}
+	}
+	return nil
+}
+
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage configuration profiles",
+		Long: `Manage the configuration file and its profiles. A profile holds defaults for
+the chat commands: provider, url, model, temperature, system_prompt, preset,
+max_history, timeout, reasoning, api_key, base_path and headers, and the model
+of the embed command: embedding_model.
+
+Settings are resolved in this order, later sources winning:
+  built-in defaults
+  user config (the current profile, or the one named with --profile)
+  project config (` + config.ProjectFile + ` in the working directory or a parent)
+  environment (` + config.EnvPrefix + `MODEL, ` + config.EnvPrefix + `SYSTEM_PROMPT, ..., OLLAMA_HOST)
+  command-line flags
+
+"config get", "config set" and "config list" work on the user config only;
+"config explain" shows the effective values and where they come from.`,
+	}
+
+	cmd.AddCommand(
+		newConfigGetCommand(),
+		newConfigSetCommand(),
+		newConfigListCommand(),
+		newConfigUseProfileCommand(),
+		newConfigExplainCommand(),
+	)
+
+	return cmd
+}
+
+func newConfigGetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get [key]",
+		Short: "Print a setting of the active profile",
+		Example: `  ai-cli config get model
+  ai-cli config get headers.X-Team --profile lab`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			name := cfg.ActiveProfileName(profileFlag(cmd))
+			profile := cfg.Profile(name)
+			if profile == nil {
+				return fmt.Errorf("profile %q does not exist", name)
+			}
+
+			value, ok, err := profile.Get(args[0])
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%s is not set in profile %q", args[0], name)
+			}
+
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			if format != OutputText {
+				return writeOutput(format, config.Setting{
+					Key:    args[0],
+					Value:  value,
+					Source: fmt.Sprintf("user config (profile %s)", name),
+				})
+			}
+			fmt.Println(value)
+			return nil
+		},
+	}
+}
+
+func newConfigSetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set [key] [value]",
+		Short: "Change a setting of the active profile",
+		Long: `Change a setting of the active profile, creating the profile if needed. An
+empty value removes the setting. Use "plugins.<name>" to declare a provider
+plugin that is not on PATH.`,
+		Example: `  ai-cli config set model llama3
+  ai-cli config set --profile lab provider openai-compatible
+  ai-cli config set --profile lab headers.X-Team ml
+  ai-cli config set plugins.gateway /opt/gateway/bin/gateway-plugin`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, value := args[0], args[1]
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			if name, ok := strings.CutPrefix(key, "plugins."); ok {
+				if name == "" {
+					return fmt.Errorf("plugin name missing in %q", key)
+				}
+				if cfg.Plugins == nil {
+					cfg.Plugins = make(map[string]string)
+				}
+				if value == "" {
+					delete(cfg.Plugins, name)
+				} else {
+					cfg.Plugins[name] = value
+				}
+				if err := cfg.Save(); err != nil {
+					return err
+				}
+				return reportSet(cmd, statusDocument{Status: "set", Key: key, Value: value})
+			}
+
+			if key == "provider" && value != "" {
+				if _, ok := provider.Lookup(provider.ProviderType(value)); !ok {
+					return fmt.Errorf("invalid provider: %s", value)
+				}
+			}
+
+			name := cfg.ActiveProfileName(profileFlag(cmd))
+			if err := cfg.EnsureProfile(name).Set(key, value); err != nil {
+				return err
+			}
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+
+			if key == "api_key" {
+				value = maskSecret(value)
+			}
+			return reportSet(cmd, statusDocument{Status: "set", Profile: name, Key: key, Value: value})
+		},
+	}
+}
+
+// reportSet confirms a change in the structured output formats; in text mode
+// "config set" stays silent.
+func reportSet(cmd *cobra.Command, doc statusDocument) error {
+	format, err := outputFormat(cmd)
+	if err != nil || format == OutputText {
+		return err
+	}
+	return writeOutput(format, doc)
+}
+
+// configDocument is the structured form of "config list". API keys are
+// masked.
+type configDocument struct {
+	Path           string                     `json:"path"`
+	CurrentProfile string                     `json:"current_profile,omitempty"`
+	ActiveProfile  string                     `json:"active_profile"`
+	Profiles       map[string]*config.Profile `json:"profiles"`
+	Plugins        map[string]string          `json:"plugins,omitempty"`
+}
+
+func newConfigListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List all profiles and their settings",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := config.Path()
+			if err != nil {
+				return err
+			}
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			if format != OutputText {
+				doc := configDocument{
+					Path:           path,
+					CurrentProfile: cfg.CurrentProfile,
+					ActiveProfile:  cfg.ActiveProfileName(profileFlag(cmd)),
+					Profiles:       make(map[string]*config.Profile),
+					Plugins:        cfg.Plugins,
+				}
+				for name, profile := range cfg.Profiles {
+					masked := *profile
+					if masked.APIKey != "" {
+						masked.APIKey = maskSecret(masked.APIKey)
+					}
+					doc.Profiles[name] = &masked
+				}
+				return writeOutput(format, doc)
+			}
+
+			fmt.Printf("Config file: %s\n", path)
+			if len(cfg.Profiles) == 0 {
+				fmt.Println("No profiles defined")
+			}
+
+			active := cfg.ActiveProfileName(profileFlag(cmd))
+			for _, name := range cfg.ProfileNames() {
+				marker := ""
+				if name == active {
+					marker = " (active)"
+				}
+				fmt.Printf("\n%s%s\n", name, marker)
+
+				profile := cfg.Profile(name)
+				for _, key := range append(config.Keys, "headers") {
+					value, ok, _ := profile.Get(key)
+					if !ok {
+						continue
+					}
+					if key == "api_key" {
+						value = maskSecret(value)
+					}
+					if key == "headers" {
+						fmt.Printf("  headers:\n    %s\n", strings.ReplaceAll(value, "\n", "\n    "))
+						continue
+					}
+					fmt.Printf("  %s: %s\n", key, value)
+				}
+			}
+
+			if len(cfg.Plugins) > 0 {
+				fmt.Println("\nPlugins:")
+				for name, path := range cfg.Plugins {
+					fmt.Printf("  %s: %s\n", name, path)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newConfigUseProfileCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "use-profile [name]",
+		Short: "Make a profile the current one",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if cfg.Profile(args[0]) == nil {
+				return fmt.Errorf("profile %q does not exist (create it with: ai-cli config set --profile %s provider <provider>)", args[0], args[0])
+			}
+
+			cfg.CurrentProfile = args[0]
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+			return printStatus(cmd, "Now using profile: "+args[0], statusDocument{Status: "current", Profile: args[0]})
+		},
+	}
+}
+
+func newConfigExplainCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain [provider]",
+		Short: "Show the effective settings and where each comes from",
+		Long: `Show the effective settings for a provider, which defaults to the resolved
+provider setting, and the source of each value. Flags given to a chat command
+override everything shown here.`,
+		Example: `  ai-cli config explain
+  ai-cli config explain ollama --profile lab`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			layers, err := config.Layers(profileFlag(cmd))
+			if err != nil {
+				return err
+			}
+
+			providerSetting, ok := config.Resolve(layers, "").Get("provider")
+			if len(args) == 1 {
+				providerSetting, ok = config.Setting{Key: "provider", Value: args[0], Source: "argument"}, true
+			}
+			if !ok {
+				return fmt.Errorf("no provider configured; name one, e.g. ai-cli config explain ollama")
+			}
+
+			reg, found := provider.Lookup(provider.ProviderType(providerSetting.Value))
+			if !found {
+				return fmt.Errorf("invalid provider: %s", providerSetting.Value)
+			}
+			resolved, err := resolveSettings(cmd, reg)
+			if err != nil {
+				return err
+			}
+
+			// The provider's chat command knows the built-in defaults.
+			var chatCmd *cobra.Command
+			for _, c := range cmd.Root().Commands() {
+				if c.Name() == string(reg.Type) {
+					chatCmd = c
+				}
+			}
+
+			settings := []config.Setting{providerSetting}
+			for _, key := range config.Keys {
+				if key == "provider" {
+					continue
+				}
+				setting, ok := resolved.Get(key)
+				if !ok {
+					setting = config.Setting{Key: key, Source: "default"}
+					if chatCmd != nil {
+						if f := chatCmd.Flags().Lookup(settingFlags[key]); f != nil {
+							setting.Value = f.DefValue
+						}
+					}
+				}
+
+				if key == "api_key" {
+					setting.Value = maskSecret(setting.Value)
+				}
+				settings = append(settings, setting)
+			}
+			settings = append(settings, resolved.Headers()...)
+
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			if format != OutputText {
+				return writeList(format, settings)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+			fmt.Fprintln(w, "-------\t-----\t------")
+			for _, setting := range settings {
+				value := setting.Value
+				if value == "" {
+					value = "(not set)"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, truncate(value, 50), setting.Source)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// truncate shortens s to at most n runes for tabular output.
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-3]) + "..."
+	}
+	return s
+}
+
+// maskSecret hides all but the last four characters of a secret.
+func maskSecret(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
+	}
+	return strings.Repeat("*", len(secret)-4) + secret[len(secret)-4:]
+}
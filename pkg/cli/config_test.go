@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/ahr9n/ai-cli/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplySettingsFlagsWin(t *testing.T) {
+	resolved := config.Resolve([]config.Layer{
+		{Source: "user config (profile default)", Settings: &config.Profile{Model: "llama3", Preset: "code"}},
+		{Source: "env", Settings: &config.Profile{Model: "qwen", Timeout: "soon"}},
+	}, "ollama")
+
+	opts := &ChatOptions{}
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().StringVarP(&opts.Model, "model", "m", "", "")
+	cmd.Flags().StringVar(&opts.PresetPrompt, "preset", "", "")
+	require.NoError(t, cmd.Flags().Parse([]string{"-m", "mistral"}))
+
+	require.NoError(t, applySettingsTo(cmd, resolved, settingFlags))
+	assert.Equal(t, "mistral", opts.Model, "a flag given on the command line wins")
+	assert.Equal(t, "code", opts.PresetPrompt)
+
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 0, "")
+	assert.EqualError(t, applySettingsTo(cmd, resolved, settingFlags),
+		`invalid timeout from env: time: invalid duration "soon"`)
+}
@@ -1,52 +1,83 @@
 package cli
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
 
+	"github.com/ahr9n/ai-cli/pkg/config"
 	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/ahr9n/ai-cli/pkg/session"
 	"github.com/spf13/cobra"
 )
 
-type defaultConfig struct {
-	Provider    string `json:"provider"`
-	ProviderURL string `json:"provider_url,omitempty"`
-}
-
+// HandleDefaultProvider runs a prompt given without a provider command, such
+// as `ai-cli "hello"`, with the configured provider. The root
+// command does not parse flags itself, so args still holds every flag and is
+// handed to the provider command unchanged.
 func HandleDefaultProvider(rootCmd *cobra.Command, args []string) error {
-	if len(args) == 0 {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
 		return rootCmd.Help()
 	}
 
-	config, err := loadDefaultConfig()
-	if err != nil || config.Provider == "" {
+	layers, err := config.Layers(flagFromArgs(args, "profile"))
+	if err != nil {
+		return err
+	}
+	setting, ok := config.Resolve(layers, "").Get("provider")
+
+	// A resumed session continues with the provider it was created with.
+	if ref := flagFromArgs(args, "resume"); ref != "" {
+		store, err := session.NewStore()
+		if err != nil {
+			return err
+		}
+		sess, err := store.Load(ref)
+		if err != nil {
+			return err
+		}
+		setting, ok = config.Setting{Key: "provider", Value: sess.Provider, Source: "session " + sess.ID}, true
+	}
+	if !ok {
 		return rootCmd.Help()
 	}
 
-	var providerCmd *cobra.Command
-	switch config.Provider {
-	case string(provider.Ollama):
-		providerCmd = newOllamaCommand()
-	case string(provider.LocalAI):
-		providerCmd = newLocalAICommand()
-	default:
-		return fmt.Errorf("unknown default provider type: %s", config.Provider)
+	if _, ok := provider.Lookup(provider.ProviderType(setting.Value)); !ok {
+		return fmt.Errorf("unknown default provider type %q from %s", setting.Value, setting.Source)
 	}
 
-	if providerCmd != nil {
-		providerCmd.SetArgs(args)
-		return providerCmd.Execute()
-	}
+	rootCmd.SetArgs(append([]string{setting.Value}, args...))
+	return rootCmd.ExecuteContext(rootCmd.Context())
+}
 
-	return rootCmd.Help()
+// flagFromArgs finds the value of the long flag name in unparsed arguments.
+func flagFromArgs(args []string, name string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if value, ok := strings.CutPrefix(arg, "--"+name+"="); ok {
+			return value
+		}
+		if arg == "--"+name && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// defaultDocument is the structured form of the default provider setting.
+type defaultDocument struct {
+	Profile  string `json:"profile"`
+	Provider string `json:"provider"`
+	URL      string `json:"url,omitempty"`
 }
 
 func newDefaultCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "default",
 		Short: "Manage default provider settings",
+		Long: `Manage the provider used when no provider command is given. The setting is
+stored in the active profile; see "ai-cli config".`,
 	}
 
 	cmd.AddCommand(
@@ -65,24 +96,37 @@ func newDefaultSetCommand() *cobra.Command {
 		Use:   "set [provider]",
 		Short: "Set default provider",
 		Example: `  ai-cli default set ollama
-  ai-cli default set localai --url http://custom:8080`,
+  ai-cli default set localai --url http://custom:8080
+  ai-cli default set openai-compatible --url http://localhost:8000`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			providerType := provider.ProviderType(args[0])
 
-			if _, err := NewProvider(providerType, ""); err != nil {
+			if _, ok := provider.Lookup(providerType); !ok {
 				return fmt.Errorf("invalid provider: %s", args[0])
 			}
 
-			config := defaultConfig{
-				Provider:    args[0],
-				ProviderURL: providerURL,
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
 			}
+			name := cfg.ActiveProfileName(profileFlag(cmd))
+			profile := cfg.EnsureProfile(name)
+			profile.Provider = args[0]
+			profile.URL = providerURL
 
-			if err := saveDefaultConfig(config); err != nil {
+			if err := cfg.Save(); err != nil {
 				return fmt.Errorf("failed to save default config: %w", err)
 			}
 
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			if format != OutputText {
+				return writeOutput(format, defaultDocument{Profile: name, Provider: args[0], URL: providerURL})
+			}
+
 			fmt.Printf("Default provider set to: %s\n", args[0])
 			if providerURL != "" {
 				fmt.Printf("Provider URL: %s\n", providerURL)
@@ -101,19 +145,34 @@ func newDefaultShowCommand() *cobra.Command {
 		Use:   "show",
 		Short: "Show current default provider",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			config, err := loadDefaultConfig()
+			cfg, err := config.Load()
 			if err != nil {
-				return fmt.Errorf("failed to load default config: %w", err)
+				return fmt.Errorf("failed to load config: %w", err)
 			}
 
-			if config.Provider == "" {
+			name := cfg.ActiveProfileName(profileFlag(cmd))
+			profile := cfg.Profile(name)
+
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			if format != OutputText {
+				doc := defaultDocument{Profile: name}
+				if profile != nil {
+					doc.Provider, doc.URL = profile.Provider, profile.URL
+				}
+				return writeOutput(format, doc)
+			}
+
+			if profile == nil || profile.Provider == "" {
 				fmt.Println("No default provider set")
 				return nil
 			}
 
-			fmt.Printf("Default provider: %s\n", config.Provider)
-			if config.ProviderURL != "" {
-				fmt.Printf("Provider URL: %s\n", config.ProviderURL)
+			fmt.Printf("Default provider: %s\n", profile.Provider)
+			if profile.URL != "" {
+				fmt.Printf("Provider URL: %s\n", profile.URL)
 			}
 			return nil
 		},
@@ -125,68 +184,20 @@ func newDefaultClearCommand() *cobra.Command {
 		Use:   "clear",
 		Short: "Clear default provider setting",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := removeDefaultConfig(); err != nil {
+			cfg, err := config.Load()
+			if err != nil {
 				return fmt.Errorf("failed to clear default config: %w", err)
 			}
-			fmt.Println("Default provider cleared")
-			return nil
-		},
-	}
-}
-
-func getConfigPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
-	}
-	configDir := filepath.Join(home, ".config", "ai-cli")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return "", err
-	}
-	return filepath.Join(configDir, "default.json"), nil
-}
-
-func saveDefaultConfig(config defaultConfig) error {
-	path, err := getConfigPath()
-	if err != nil {
-		return err
-	}
-
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return err
-	}
 
-	return os.WriteFile(path, data, 0644)
-}
-
-func loadDefaultConfig() (defaultConfig, error) {
-	var config defaultConfig
-	path, err := getConfigPath()
-	if err != nil {
-		return config, err
-	}
-
-	data, err := os.ReadFile(path)
-	if os.IsNotExist(err) {
-		return config, nil
-	}
-	if err != nil {
-		return config, err
-	}
-
-	err = json.Unmarshal(data, &config)
-	return config, err
-}
-
-func removeDefaultConfig() error {
-	path, err := getConfigPath()
-	if err != nil {
-		return err
-	}
-	if err := os.Remove(path); os.IsNotExist(err) {
-		return nil
-	} else {
-		return err
+			name := cfg.ActiveProfileName(profileFlag(cmd))
+			if profile := cfg.Profile(name); profile != nil {
+				profile.Provider = ""
+				profile.URL = ""
+				if err := cfg.Save(); err != nil {
+					return fmt.Errorf("failed to clear default config: %w", err)
+				}
+			}
+			return printStatus(cmd, "Default provider cleared", statusDocument{Status: "cleared", Profile: name})
+		},
 	}
 }
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/utils"
+)
+
+// defaultEditor is used when neither $VISUAL nor $EDITOR is set.
+const defaultEditor = "vi"
+
+// editorCommand returns the user's editor and its arguments, e.g.
+// "code --wait".
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{defaultEditor}
+}
+
+// composeInEditor opens the user's editor on a temporary file holding
+// initial and returns what was saved, without trailing whitespace. The editor
+// talks to the terminal even when stdin is a pipe.
+func composeInEditor(initial string) (string, error) {
+	f, err := os.CreateTemp("", "ai-cli-*.md")
+	if err != nil {
+		return "", fmt.Errorf("failed to create a file to edit: %w", err)
+	}
+	path := f.Name()
+	defer os.Remove(path)
+
+	_, err = f.WriteString(initial)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create a file to edit: %w", err)
+	}
+
+	stdin := os.Stdin
+	if !utils.IsTerminal(stdin) {
+		tty, err := os.Open("/dev/tty")
+		if err != nil {
+			return "", fmt.Errorf("no terminal for the editor: %w", err)
+		}
+		defer tty.Close()
+		stdin = tty
+	}
+
+	editor := editorCommand()
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin = stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", editor[0], err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the edited file: %w", err)
+	}
+	return strings.TrimRight(string(data), " \t\r\n"), nil
+}
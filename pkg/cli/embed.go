@@ -0,0 +1,323 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/files"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/ahr9n/ai-cli/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// Formats of the embed command's output.
+const (
+	EmbedJSON   = "json"
+	EmbedJSONL  = "jsonl"
+	EmbedBinary = "binary"
+)
+
+// defaultEmbedBatch is how many inputs go in one request unless
+// --batch-size says otherwise.
+const defaultEmbedBatch = 32
+
+// embedSettingFlags maps settings to the embed command's flags. Its model
+// comes from embedding_model; the chat model is no embedding model.
+var embedSettingFlags = map[string]string{
+	"url":             "url",
+	"embedding_model": "model",
+	"timeout":         "timeout",
+	"api_key":         "api-key",
+	"base_path":       "base-path",
+}
+
+type embedOptions struct {
+	Files     []string
+	Lines     bool
+	BatchSize int
+	Format    string
+	FileLimit int64
+}
+
+// embedInput is a text to embed and where it came from.
+type embedInput struct {
+	Source string
+	Text   string
+}
+
+// embeddingDocument is one vector of the embed command's JSON output.
+type embeddingDocument struct {
+	Index     int       `json:"index"`
+	Source    string    `json:"source"`
+	Embedding []float32 `json:"embedding"`
+}
+
+type embeddingsDocument struct {
+	Provider   string              `json:"provider"`
+	Model      string              `json:"model,omitempty"`
+	Dimensions int                 `json:"dimensions"`
+	Embeddings []embeddingDocument `json:"embeddings"`
+}
+
+// newEmbedCommand builds the embed subcommand of a provider that supports
+// embeddings.
+func newEmbedCommand(reg provider.Registration) *cobra.Command {
+	opts := &ChatOptions{}
+	embed := &embedOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "embed [text...]",
+		Short: "Turn text into embedding vectors",
+		Long: `Embed text given as arguments, one input each, in files given with --file,
+or piped to stdin. With --lines every non-empty line is an input of its own.
+Inputs are sent in batches of --batch-size.
+
+Vectors are written as one JSON document, as one JSON object per input with
+-o jsonl, or with --format binary as raw little-endian float32 values, one
+vector after another.`,
+		Example: fmt.Sprintf(`  ai-cli %[1]s embed "The quick brown fox"
+  ai-cli %[1]s embed -f notes.md -f 'docs/**/*.md' -o jsonl
+  cut -f2 titles.tsv | ai-cli %[1]s embed --lines --format binary > titles.f32`, reg.Type),
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resolved, err := resolveSettings(cmd, reg)
+			if err != nil {
+				return err
+			}
+			if err := applySettingsTo(cmd, resolved, embedSettingFlags); err != nil {
+				return err
+			}
+			if err := applyOutputFlags(cmd, opts); err != nil {
+				return err
+			}
+			opts.Provider = string(reg.Type)
+
+			cfg, err := providerConfig(resolved, opts)
+			if err != nil {
+				return err
+			}
+			p, err := provider.NewProvider(reg.Type, cfg)
+			if err != nil {
+				return err
+			}
+			embedder, ok := p.(provider.Embedder)
+			if !ok {
+				return fmt.Errorf("%s does not support embeddings", reg.Name)
+			}
+			return runEmbed(cmd.Context(), embedder, args, embed, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	modelUsage := "Embedding model to use"
+	if reg.DefaultEmbeddingModel == "" {
+		modelUsage += " (default: chosen by the provider)"
+	}
+	flags.StringVarP(&opts.Model, "model", "m", reg.DefaultEmbeddingModel, modelUsage)
+	flags.StringVarP(&opts.ProviderURL, "url", "u", reg.DefaultURL, "Provider API URL (optional)")
+	if reg.Capabilities.APIKey {
+		flags.StringVar(&opts.APIKey, "api-key", "", "API key sent as a bearer token")
+	}
+	if reg.Capabilities.BasePath {
+		flags.StringVar(&opts.BasePath, "base-path", "", "API path prefix on the server (default \"v1\")")
+	}
+	flags.StringArrayVarP(&opts.Headers, "header", "H", nil, "Extra request header as 'Name: value' (repeatable)")
+	flags.DurationVar(&opts.Timeout, "timeout", 0, "Maximum time to wait for each batch, e.g. 90s or 5m (0 = no limit)")
+	flags.Int64Var(&opts.StdinLimit, "stdin-limit", defaultStdinLimit, "Maximum size of piped input in bytes (0 = unlimited)")
+	flags.StringArrayVarP(&embed.Files, "file", "f", nil, "Embed a file, directory or glob such as 'docs/**/*.md' (repeatable)")
+	flags.Int64Var(&embed.FileLimit, "file-limit", defaultFileLimit, "Maximum size of a file to embed in bytes (0 = unlimited)")
+	flags.BoolVar(&embed.Lines, "lines", false, "Embed every non-empty line of files and stdin separately")
+	flags.IntVar(&embed.BatchSize, "batch-size", defaultEmbedBatch, "Number of inputs sent in one request")
+	flags.StringVar(&embed.Format, "format", "", "Write \"binary\" little-endian float32 vectors instead of JSON")
+
+	return cmd
+}
+
+// embedFormat returns the format of the vectors: binary with --format
+// binary, or else the JSON -o asks for, one document unless it is jsonl.
+func embedFormat(format, output string) (string, error) {
+	switch format {
+	case "":
+		if output == OutputJSONL {
+			return EmbedJSONL, nil
+		}
+		return EmbedJSON, nil
+	case EmbedBinary:
+		if output != OutputText {
+			return "", fmt.Errorf("--format binary cannot be combined with -o %s", output)
+		}
+		return EmbedBinary, nil
+	default:
+		return "", fmt.Errorf("invalid format %q (use binary, or -o json or -o jsonl for JSON)", format)
+	}
+}
+
+func runEmbed(ctx context.Context, embedder provider.Embedder, args []string, embed *embedOptions, opts *ChatOptions) error {
+	format, err := embedFormat(embed.Format, opts.Output)
+	if err != nil {
+		return err
+	}
+	if format == EmbedBinary && utils.IsTerminal(os.Stdout) {
+		return fmt.Errorf("refusing to write binary vectors to a terminal; redirect the output")
+	}
+	if embed.BatchSize < 1 {
+		return fmt.Errorf("--batch-size must be at least 1")
+	}
+
+	inputs, err := embedInputs(ctx, args, embed, opts)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("nothing to embed: give text, --file or pipe input to stdin")
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	return writeEmbeddings(ctx, out, embedder, inputs, format, embed.BatchSize, opts)
+}
+
+// writeEmbeddings embeds inputs in batches of batchSize and writes the
+// vectors to out in format.
+func writeEmbeddings(ctx context.Context, out io.Writer, embedder provider.Embedder, inputs []embedInput, format string, batchSize int, opts *ChatOptions) error {
+	doc := embeddingsDocument{Provider: opts.Provider, Model: opts.Model}
+
+	for start := 0; start < len(inputs); start += batchSize {
+		batch := inputs[start:min(start+batchSize, len(inputs))]
+		vectors, err := embedBatch(ctx, embedder, batch, opts)
+		if err != nil {
+			return err
+		}
+
+		for i, vector := range vectors {
+			if doc.Dimensions == 0 {
+				doc.Dimensions = len(vector)
+			} else if len(vector) != doc.Dimensions {
+				return fmt.Errorf("the embedding of %s has %d dimensions, not %d like the others", batch[i].Source, len(vector), doc.Dimensions)
+			}
+			d := embeddingDocument{Index: start + i, Source: batch[i].Source, Embedding: vector}
+
+			switch format {
+			case EmbedJSON:
+				doc.Embeddings = append(doc.Embeddings, d)
+			case EmbedJSONL:
+				if err := json.NewEncoder(out).Encode(d); err != nil {
+					return err
+				}
+			case EmbedBinary:
+				if err := writeFloat32s(out, vector); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	switch format {
+	case EmbedJSON:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	case EmbedBinary:
+		if !opts.Quiet {
+			noun := "vectors"
+			if len(inputs) == 1 {
+				noun = "vector"
+			}
+			fmt.Fprintf(os.Stderr, "Wrote %d %s of %d float32 values\n", len(inputs), noun, doc.Dimensions)
+		}
+	}
+	return nil
+}
+
+// embedBatch embeds one batch within the --timeout.
+func embedBatch(ctx context.Context, embedder provider.Embedder, batch []embedInput, opts *ChatOptions) ([][]float32, error) {
+	ctx, cancel := withTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	texts := make([]string, len(batch))
+	for i, input := range batch {
+		texts[i] = input.Text
+	}
+	loader := startLoader(opts)
+	vectors, err := embedder.Embed(ctx, texts, &provider.EmbeddingOptions{Model: opts.Model})
+	loader.Stop()
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+	if len(vectors) != len(batch) {
+		return nil, fmt.Errorf("got %d embeddings for %d inputs", len(vectors), len(batch))
+	}
+	return vectors, nil
+}
+
+// embedInputs collects the inputs from the arguments, the files and stdin,
+// in that order. Stdin is read when it is not a terminal or a "-" argument
+// asks for it.
+func embedInputs(ctx context.Context, args []string, embed *embedOptions, opts *ChatOptions) ([]embedInput, error) {
+	var inputs []embedInput
+	add := func(source, text string) {
+		if !embed.Lines {
+			if strings.TrimSpace(text) != "" {
+				inputs = append(inputs, embedInput{Source: source, Text: text})
+			}
+			return
+		}
+		for i, line := range strings.Split(text, "\n") {
+			if strings.TrimSpace(line) != "" {
+				inputs = append(inputs, embedInput{Source: fmt.Sprintf("%s:%d", source, i+1), Text: line})
+			}
+		}
+	}
+
+	explicit := false
+	for i, arg := range args {
+		if arg == stdinArg {
+			explicit = true
+			continue
+		}
+		if strings.TrimSpace(arg) != "" {
+			inputs = append(inputs, embedInput{Source: fmt.Sprintf("arg %d", i+1), Text: arg})
+		}
+	}
+
+	if len(embed.Files) > 0 {
+		expansion, err := files.Expand(embed.Files, files.Limits{MaxFileSize: embed.FileLimit})
+		if err != nil {
+			return nil, fmt.Errorf("cannot read files: %w", err)
+		}
+		if !opts.Quiet {
+			for _, s := range expansion.Skipped {
+				fmt.Fprintf(os.Stderr, "Skipping %s: %s\n", s.Path, s.Reason)
+			}
+		}
+		for _, f := range expansion.Files {
+			add(f.Path, f.Content)
+		}
+	}
+
+	if explicit || !utils.IsTerminal(os.Stdin) {
+		text, err := readStdin(ctx, opts.StdinLimit)
+		if err != nil {
+			return nil, err
+		}
+		add("stdin", strings.TrimRight(text, "\n"))
+	}
+	return inputs, nil
+}
+
+// writeFloat32s writes v as little-endian IEEE 754 single-precision values.
+func writeFloat32s(w io.Writer, v []float32) error {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	_, err := w.Write(buf)
+	return err
+}
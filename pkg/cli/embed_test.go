@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchEmbedder embeds a text as its length and its position in the batch,
+// and keeps the batches it got. With dims set, the vector of the text "odd"
+// has that many dimensions instead.
+type batchEmbedder struct {
+	batches [][]string
+	dims    int
+}
+
+func (e *batchEmbedder) Embed(ctx context.Context, inputs []string, opts *provider.EmbeddingOptions) ([][]float32, error) {
+	e.batches = append(e.batches, inputs)
+	vectors := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		vectors[i] = []float32{float32(len(input)), float32(i)}
+		if input == "odd" && e.dims > 0 {
+			vectors[i] = make([]float32, e.dims)
+		}
+	}
+	return vectors, nil
+}
+
+func TestEmbedFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		output  string
+		want    string
+		wantErr string
+	}{
+		{format: "", output: OutputText, want: EmbedJSON},
+		{format: "", output: OutputJSON, want: EmbedJSON},
+		{format: "", output: OutputJSONL, want: EmbedJSONL},
+		{format: "binary", output: OutputText, want: EmbedBinary},
+		{format: "binary", output: OutputJSON, wantErr: "--format binary cannot be combined with -o json"},
+		{format: "binary", output: OutputJSONL, wantErr: "--format binary cannot be combined with -o jsonl"},
+		{format: "csv", output: OutputText, wantErr: `invalid format "csv" (use binary, or -o json or -o jsonl for JSON)`},
+	}
+	for _, tt := range tests {
+		got, err := embedFormat(tt.format, tt.output)
+		if tt.wantErr != "" {
+			assert.EqualError(t, err, tt.wantErr)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "--format %q -o %s", tt.format, tt.output)
+	}
+}
+
+func embedInputsOf(texts ...string) []embedInput {
+	inputs := make([]embedInput, len(texts))
+	for i, text := range texts {
+		inputs[i] = embedInput{Source: fmt.Sprintf("arg %d", i+1), Text: text}
+	}
+	return inputs
+}
+
+func TestWriteEmbeddingsBatches(t *testing.T) {
+	opts := &ChatOptions{Provider: "fake", Model: "m", Quiet: true}
+	inputs := embedInputsOf("a", "bb", "ccc", "dddd", "eeeee")
+
+	for _, tt := range []struct {
+		size int
+		want [][]string
+	}{
+		{1, [][]string{{"a"}, {"bb"}, {"ccc"}, {"dddd"}, {"eeeee"}}},
+		{2, [][]string{{"a", "bb"}, {"ccc", "dddd"}, {"eeeee"}}},
+		{5, [][]string{{"a", "bb", "ccc", "dddd", "eeeee"}}},
+		{32, [][]string{{"a", "bb", "ccc", "dddd", "eeeee"}}},
+	} {
+		e := &batchEmbedder{}
+		var out bytes.Buffer
+		require.NoError(t, writeEmbeddings(context.Background(), &out, e, inputs, EmbedJSON, tt.size, opts))
+		assert.Equal(t, tt.want, e.batches, "batch size %d", tt.size)
+
+		var doc embeddingsDocument
+		require.NoError(t, json.Unmarshal(out.Bytes(), &doc))
+		require.Len(t, doc.Embeddings, 5)
+		for i, d := range doc.Embeddings {
+			assert.Equal(t, i, d.Index, "indexes run across batches")
+			assert.Equal(t, inputs[i].Source, d.Source)
+			assert.Equal(t, float32(len(inputs[i].Text)), d.Embedding[0])
+		}
+	}
+}
+
+func TestWriteEmbeddingsFormats(t *testing.T) {
+	opts := &ChatOptions{Provider: "fake", Model: "m", Quiet: true}
+	inputs := embedInputsOf("a", "bb", "ccc")
+
+	var out bytes.Buffer
+	require.NoError(t, writeEmbeddings(context.Background(), &out, &batchEmbedder{}, inputs, EmbedJSON, 2, opts))
+	assert.JSONEq(t, `{
+		"provider": "fake",
+		"model": "m",
+		"dimensions": 2,
+		"embeddings": [
+			{"index": 0, "source": "arg 1", "embedding": [1, 0]},
+			{"index": 1, "source": "arg 2", "embedding": [2, 1]},
+			{"index": 2, "source": "arg 3", "embedding": [3, 0]}
+		]
+	}`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeEmbeddings(context.Background(), &out, &batchEmbedder{}, inputs, EmbedJSONL, 2, opts))
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	assert.JSONEq(t, `{"index": 2, "source": "arg 3", "embedding": [3, 0]}`, lines[2])
+
+	out.Reset()
+	require.NoError(t, writeEmbeddings(context.Background(), &out, &batchEmbedder{}, inputs, EmbedBinary, 2, opts))
+	data := out.Bytes()
+	require.Len(t, data, 3*2*4, "three vectors of two float32s")
+	var values []float32
+	for i := 0; i < len(data); i += 4 {
+		values = append(values, math.Float32frombits(binary.LittleEndian.Uint32(data[i:])))
+	}
+	assert.Equal(t, []float32{1, 0, 2, 1, 3, 0}, values)
+}
+
+func TestWriteEmbeddingsDimensions(t *testing.T) {
+	opts := &ChatOptions{Quiet: true}
+	var out bytes.Buffer
+	err := writeEmbeddings(context.Background(), &out, &batchEmbedder{dims: 3}, embedInputsOf("a", "b", "odd"), EmbedJSONL, 2, opts)
+	assert.EqualError(t, err, "the embedding of arg 3 has 3 dimensions, not 2 like the others")
+}
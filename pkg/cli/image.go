@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+// maxImageSize is the largest image accepted; vision models scale images
+// down anyway, and every byte goes over the wire base64-encoded.
+const maxImageSize = 20 << 20
+
+// loadImages reads the images at paths; "-" reads one from stdin.
+func loadImages(paths []string) ([]provider.Image, error) {
+	var images []provider.Image
+	for _, path := range paths {
+		image, err := loadImage(path)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+func loadImage(path string) (provider.Image, error) {
+	var r io.Reader
+	name := path
+	if path == stdinArg {
+		r = os.Stdin
+		name = "stdin"
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return provider.Image{}, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxImageSize+1))
+	if err != nil {
+		return provider.Image{}, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > maxImageSize {
+		return provider.Image{}, fmt.Errorf("%s is larger than %s", name, formatSize(maxImageSize))
+	}
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return provider.Image{}, fmt.Errorf("%s is not an image (%s)", name, mimeType)
+	}
+	return provider.Image{MIMEType: mimeType, Data: data}, nil
+}
+
+// imageFromStdin reports whether --image reads stdin, which then cannot
+// also hold the prompt.
+func imageFromStdin(opts *ChatOptions) bool {
+	for _, path := range opts.Images {
+		if path == stdinArg {
+			return true
+		}
+	}
+	return false
+}
+
+// describeImages summarizes the images of a message for transcripts.
+func describeImages(images []provider.Image) string {
+	parts := make([]string, len(images))
+	for i, image := range images {
+		parts[i] = fmt.Sprintf("[image: %s, %s]", image.MIMEType, formatSize(int64(len(image.Data))))
+	}
+	return strings.Join(parts, " ")
+}
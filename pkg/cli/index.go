@@ -0,0 +1,429 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ahr9n/ai-cli/pkg/config"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/ahr9n/ai-cli/pkg/rag"
+	"github.com/spf13/cobra"
+)
+
+// defaultIndexFileLimit leaves out files larger than this unless
+// --file-limit says otherwise; they are rarely prose or source.
+const defaultIndexFileLimit = 1 << 20
+
+// snippetLines is how much of an excerpt "index query" shows without --full.
+const snippetLines = 4
+
+type indexBuildOptions struct {
+	Provider     string
+	Name         string
+	ChunkSize    int
+	ChunkOverlap int
+	BatchSize    int
+	FileLimit    int64
+	Rebuild      bool
+}
+
+// indexSummary is the structured form of an index in listings.
+type indexSummary struct {
+	Name       string    `json:"name"`
+	Root       string    `json:"root"`
+	Provider   string    `json:"provider"`
+	Model      string    `json:"model,omitempty"`
+	Dimensions int       `json:"dimensions"`
+	Files      int       `json:"files"`
+	Chunks     int       `json:"chunks"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// buildDocument is the structured outcome of "index build".
+type buildDocument struct {
+	Index     indexSummary      `json:"index"`
+	Added     int               `json:"added"`
+	Updated   int               `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+	Removed   int               `json:"removed"`
+	Skipped   []skippedDocument `json:"skipped,omitempty"`
+}
+
+type skippedDocument struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// excerptDocument is one result of "index query".
+type excerptDocument struct {
+	Rank      int     `json:"rank"`
+	Path      string  `json:"path"`
+	StartLine int     `json:"start_line"`
+	EndLine   int     `json:"end_line"`
+	Score     float32 `json:"score"`
+	Text      string  `json:"text"`
+}
+
+func newIndexCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "index",
+		Short: "Build and search local document indexes for --rag",
+		Long: `An index holds the text, Markdown and source files of a directory, split
+into chunks and embedded with a provider's embedding model. It is a single
+file under the data directory, searched without any database. Chat commands
+given --rag <index> send the chunks that best match the prompt along with it,
+numbered so that the answer can cite them.
+
+Building an index again only embeds the files whose content changed, and
+drops the files that are gone. Indexes can be referred to by name or by the
+path of their file.`,
+	}
+
+	cmd.AddCommand(
+		newIndexBuildCommand(),
+		newIndexQueryCommand(),
+		newIndexListCommand(),
+	)
+
+	return cmd
+}
+
+func newIndexBuildCommand() *cobra.Command {
+	opts := &ChatOptions{}
+	build := &indexBuildOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "build <dir>",
+		Short: "Index the files of a directory, or bring an index up to date",
+		Long: `Chunk and embed the files under a directory, leaving out hidden files, what
+.gitignore excludes, binary files and files larger than --file-limit.
+
+The index is named after the directory unless --name says otherwise. When it
+exists already, only new and changed files are embedded, with the provider
+and model it was built with; giving another --provider, --model or chunk
+size embeds everything again.`,
+		Example: `  ai-cli index build ./docs
+  ai-cli index build . --name myproject --provider openai-compatible -u http://localhost:8000
+  ai-cli ollama --rag docs "How do I configure the proxy?"`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyOutputFlags(cmd, opts); err != nil {
+				return err
+			}
+			return runIndexBuild(cmd, args[0], build, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&build.Provider, "provider", "", "Provider to embed with (default: that of an existing index, else the configured provider, else ollama)")
+	flags.StringVarP(&opts.Model, "model", "m", "", "Embedding model to use (default: that of an existing index, else the provider's)")
+	flags.StringVarP(&opts.ProviderURL, "url", "u", "", "Provider API URL (default: that of an existing index, else the provider's)")
+	flags.StringVar(&opts.APIKey, "api-key", "", "API key sent as a bearer token, for providers that take one")
+	flags.StringVar(&opts.BasePath, "base-path", "", "API path prefix on the server, for providers that take one")
+	flags.StringArrayVarP(&opts.Headers, "header", "H", nil, "Extra request header as 'Name: value' (repeatable)")
+	flags.DurationVar(&opts.Timeout, "timeout", 0, "Maximum time to wait for each batch, e.g. 90s or 5m (0 = no limit)")
+	flags.StringVar(&build.Name, "name", "", "Name of the index (default: the name of the directory)")
+	flags.IntVar(&build.ChunkSize, "chunk-size", rag.DefaultChunkSize, "Size of a chunk in bytes")
+	flags.IntVar(&build.ChunkOverlap, "chunk-overlap", rag.DefaultChunkOverlap, "Bytes of a chunk repeated at the start of the next")
+	flags.IntVar(&build.BatchSize, "batch-size", rag.DefaultBatchSize, "Number of chunks sent in one request")
+	flags.Int64Var(&build.FileLimit, "file-limit", defaultIndexFileLimit, "Maximum size of a file to index in bytes (0 = unlimited)")
+	flags.BoolVar(&build.Rebuild, "rebuild", false, "Embed every file again, even unchanged ones")
+
+	return cmd
+}
+
+func runIndexBuild(cmd *cobra.Command, dir string, build *indexBuildOptions, opts *ChatOptions) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	name := build.Name
+	if name == "" {
+		name = filepath.Base(root)
+	}
+	path, err := rag.Path(name)
+	if err != nil {
+		return err
+	}
+	idx, err := rag.Load(path)
+	switch {
+	case errors.Is(err, rag.ErrNotFound):
+		idx = rag.New(strings.TrimSuffix(filepath.Base(path), rag.FileExt), root)
+	case err != nil:
+		return err
+	case idx.Root != root:
+		return fmt.Errorf("index %s holds %s, not %s; choose another --name", idx.Name, idx.Root, root)
+	}
+	existing := idx.Provider != ""
+
+	reg, err := indexProvider(cmd, idx, build.Provider)
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveSettings(cmd, reg)
+	if err != nil {
+		return err
+	}
+	if err := applySettingsTo(cmd, resolved, embedSettingFlags); err != nil {
+		return err
+	}
+	// An index keeps its model and URL, which the settings may no longer
+	// name, so that updating it does not embed everything again.
+	if existing && idx.Provider == string(reg.Type) {
+		if !cmd.Flags().Changed("model") {
+			opts.Model = idx.Model
+		}
+		if !cmd.Flags().Changed("url") {
+			opts.ProviderURL = idx.URL
+		}
+	}
+	if opts.Model == "" {
+		opts.Model = reg.DefaultEmbeddingModel
+	}
+	if opts.ProviderURL == "" {
+		opts.ProviderURL = reg.DefaultURL
+	}
+	if build.Rebuild {
+		idx.Files = make(map[string]*rag.File)
+	}
+
+	embed, err := newEmbedFunc(cmd, reg, opts)
+	if err != nil {
+		return err
+	}
+	stats, err := rag.Build(cmd.Context(), idx, embed, rag.BuildOptions{
+		Provider:     string(reg.Type),
+		URL:          opts.ProviderURL,
+		Model:        opts.Model,
+		ChunkSize:    build.ChunkSize,
+		ChunkOverlap: build.ChunkOverlap,
+		BatchSize:    build.BatchSize,
+		MaxFileSize:  build.FileLimit,
+		Progress: func(path string) {
+			if !opts.Quiet {
+				fmt.Fprintf(os.Stderr, "Embedding %s\n", path)
+			}
+		},
+	})
+	if err != nil {
+		// What was embedded before the failure is kept for the next build.
+		if saveErr := idx.Save(path); saveErr != nil {
+			return fmt.Errorf("%w (and saving the partial index failed: %v)", err, saveErr)
+		}
+		return err
+	}
+	if err := idx.Save(path); err != nil {
+		return err
+	}
+
+	if opts.Output != OutputText {
+		doc := buildDocument{
+			Index:     newIndexSummary(idx),
+			Added:     stats.Added,
+			Updated:   stats.Updated,
+			Unchanged: stats.Unchanged,
+			Removed:   stats.Removed,
+		}
+		for _, s := range stats.Skipped {
+			doc.Skipped = append(doc.Skipped, skippedDocument{Path: s.Path, Reason: s.Reason})
+		}
+		return writeOutput(opts.Output, doc)
+	}
+
+	if !opts.Quiet {
+		for _, s := range stats.Skipped {
+			reason := s.Reason
+			if s.Size > 0 {
+				reason += ", " + formatSize(s.Size)
+			}
+			fmt.Fprintf(os.Stderr, "Skipping %s: %s\n", s.Path, reason)
+		}
+	}
+	fmt.Printf("Index %s: %d files, %d chunks (%d added, %d updated, %d unchanged, %d removed)\n",
+		idx.Name, len(idx.Files), idx.Chunks(), stats.Added, stats.Updated, stats.Unchanged, stats.Removed)
+	fmt.Printf("Saved to %s\n", path)
+	return nil
+}
+
+// indexProvider chooses the provider to build an index with: the one named
+// with --provider, the one the index was built with, or the configured
+// default provider, falling back to Ollama.
+func indexProvider(cmd *cobra.Command, idx *rag.Index, name string) (provider.Registration, error) {
+	source := "--provider"
+	if name == "" && idx.Provider != "" {
+		name, source = idx.Provider, "index "+idx.Name
+	}
+	if name == "" {
+		layers, err := config.Layers(profileFlag(cmd))
+		if err != nil {
+			return provider.Registration{}, err
+		}
+		if setting, ok := config.Resolve(layers, "").Get("provider"); ok {
+			name, source = setting.Value, setting.Source
+		}
+	}
+	if name == "" {
+		name = string(provider.Ollama)
+	}
+
+	reg, ok := provider.Lookup(provider.ProviderType(name))
+	if !ok {
+		return reg, fmt.Errorf("unknown provider type %q from %s", name, source)
+	}
+	if !reg.Capabilities.Embeddings {
+		return reg, fmt.Errorf("%s does not support embeddings", reg.Name)
+	}
+	return reg, nil
+}
+
+func newIndexQueryCommand() *cobra.Command {
+	opts := &ChatOptions{}
+	var (
+		topK int
+		full bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "query <index> <question...>",
+		Short: "Show the excerpts an index returns for a question",
+		Long: `Show the chunks of an index that best match a question, with their cosine
+similarity, as --rag would send them. The question is embedded with the
+provider and model the index was built with.`,
+		Example: `  ai-cli index query docs "How do I configure the proxy?"
+  ai-cli index query docs -k 10 --full "proxy settings"`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyOutputFlags(cmd, opts); err != nil {
+				return err
+			}
+			if topK < 1 {
+				return fmt.Errorf("-k must be at least 1")
+			}
+			idx, err := rag.Load(args[0])
+			if err != nil {
+				return err
+			}
+			embed, err := indexEmbedFunc(cmd, idx, opts)
+			if err != nil {
+				return err
+			}
+			results, err := idx.Query(cmd.Context(), embed, strings.Join(args[1:], " "), topK)
+			if err != nil {
+				return err
+			}
+
+			if opts.Output != OutputText {
+				docs := make([]excerptDocument, len(results))
+				for i, res := range results {
+					docs[i] = excerptDocument{
+						Rank:      i + 1,
+						Path:      res.Path,
+						StartLine: res.StartLine,
+						EndLine:   res.EndLine,
+						Score:     res.Score,
+						Text:      res.Text,
+					}
+				}
+				return writeList(opts.Output, docs)
+			}
+
+			if len(results) == 0 {
+				fmt.Printf("No excerpts found in index %s\n", idx.Name)
+				return nil
+			}
+			for i, res := range results {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("[%d] %s:%d-%d  score %.3f\n", i+1, res.Path, res.StartLine, res.EndLine, res.Score)
+				lines := strings.Split(res.Text, "\n")
+				if !full && len(lines) > snippetLines {
+					lines = append(lines[:snippetLines], "...")
+				}
+				for _, line := range lines {
+					fmt.Printf("    %s\n", line)
+				}
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntVarP(&topK, "top-k", "k", defaultTopK, "Number of excerpts to show")
+	flags.BoolVar(&full, "full", false, "Show whole excerpts instead of their first lines")
+	flags.DurationVar(&opts.Timeout, "timeout", 0, "Maximum time to wait for the question's embedding, e.g. 90s (0 = no limit)")
+
+	return cmd
+}
+
+func newIndexListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the indexes in the data directory",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			indexes, err := rag.List()
+			if err != nil {
+				return err
+			}
+
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			if format != OutputText {
+				docs := make([]indexSummary, len(indexes))
+				for i, idx := range indexes {
+					docs[i] = newIndexSummary(idx)
+				}
+				return writeList(format, docs)
+			}
+
+			if len(indexes) == 0 {
+				fmt.Println("No indexes; create one with 'ai-cli index build <dir>'")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "NAME\tROOT\tPROVIDER\tMODEL\tFILES\tCHUNKS\tUPDATED")
+			fmt.Fprintln(w, "----\t----\t--------\t-----\t-----\t------\t-------")
+			for _, idx := range indexes {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+					idx.Name,
+					idx.Root,
+					idx.Provider,
+					idx.Model,
+					len(idx.Files),
+					idx.Chunks(),
+					idx.Updated.Local().Format("2006-01-02 15:04"),
+				)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newIndexSummary(idx *rag.Index) indexSummary {
+	return indexSummary{
+		Name:       idx.Name,
+		Root:       idx.Root,
+		Provider:   idx.Provider,
+		Model:      idx.Model,
+		Dimensions: idx.Dimensions,
+		Files:      len(idx.Files),
+		Chunks:     idx.Chunks(),
+		UpdatedAt:  idx.Updated,
+	}
+}
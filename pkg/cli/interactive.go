@@ -0,0 +1,484 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/lineedit"
+	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/ahr9n/ai-cli/pkg/session"
+	"github.com/ahr9n/ai-cli/pkg/tokens"
+)
+
+// A line holding only blockDelimiter starts a message of several lines,
+// ended by another such line.
+const blockDelimiter = `"""`
+
+// continuePrompt is shown for the lines after the first of a message.
+const continuePrompt = "... "
+
+// chatState is an interactive conversation: the history sent to the model,
+// the settings that can change mid-session, and where it is saved. Slash
+// commands act on it without needing a provider.
+type chatState struct {
+	opts     *ChatOptions
+	messages []provider.Message
+	session  *session.Session
+	// store saves the session after every answer; nil disables saving.
+	store *session.Store
+	out   io.Writer
+	// interrupts lets Ctrl+C stop an answer without ending the session.
+	interrupts *interrupter
+	// sizer asks the provider for context windows; nil if it cannot tell.
+	sizer provider.ContextSizer
+	// contextLengths caches the context window of each model used, zero
+	// when unknown.
+	contextLengths map[string]int
+	// lastUsage is the usage reported for the latest answer, if any.
+	lastUsage *provider.Usage
+	// files are attached to the next message, as given with --file.
+	files []string
+	// images go with the next message, from --image or /image.
+	images []provider.Image
+}
+
+// newChatState starts a conversation, or continues opts.Session.
+func newChatState(opts *ChatOptions, store *session.Store, out io.Writer, interrupts *interrupter) *chatState {
+	sess := opts.Session
+	if sess == nil {
+		sess = session.New(opts.Provider, opts.Model, opts.SystemPrompt)
+	}
+	return &chatState{
+		opts:     opts,
+		messages: withSystemPrompt(sess.Messages, opts.SystemPrompt),
+		session:  sess,
+		store:    store,
+		out:      out,
+
+		interrupts:     interrupts,
+		contextLengths: make(map[string]int),
+		files:          opts.Files,
+	}
+}
+
+// addUserMessage adds a message to the conversation with the files it
+// references attached, those given with --file if it is the first, the
+// images waiting to be sent, and the excerpts retrieved for it with --rag.
+func (s *chatState) addUserMessage(input string) error {
+	content, err := attachFiles(input, s.files, s.opts, s.out)
+	if err != nil {
+		return err
+	}
+	if s.opts.retriever != nil {
+		ctx, release := s.interrupts.Context(context.Background())
+		content, err = addRetrievedContext(ctx, content, input, s.opts, s.out)
+		release()
+		if err != nil {
+			return err
+		}
+	}
+	s.files = nil
+	s.messages = append(s.messages, provider.Message{
+		Role:    prompts.RoleUser,
+		Content: content,
+		Images:  s.images,
+	})
+	s.images = nil
+	return nil
+}
+
+// setSystemPrompt replaces the system prompt of the conversation.
+func (s *chatState) setSystemPrompt(prompt string) {
+	s.opts.SystemPrompt = prompt
+	s.messages = withSystemPrompt(s.messages, prompt)
+}
+
+// clear forgets the conversation but keeps the system prompt.
+func (s *chatState) clear() {
+	s.messages = withSystemPrompt(nil, s.opts.SystemPrompt)
+	s.lastUsage = nil
+}
+
+// lastIndex returns the index of the last message with the given role, or -1.
+func (s *chatState) lastIndex(role string) int {
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].Role == role {
+			return i
+		}
+	}
+	return -1
+}
+
+// trimHistory applies --max-history, or the window of the window strategy,
+// always keeping the system prompt and any summary.
+func (s *chatState) trimHistory() {
+	limit := s.historyLimit()
+	if limit <= 0 || len(s.messages) <= limit {
+		return
+	}
+	lead := leadingSystemMessages(s.messages)
+	keep := max(limit-lead, 1)
+	if len(s.messages)-lead <= keep {
+		return
+	}
+	trimmed := append([]provider.Message{}, s.messages[:lead]...)
+	s.messages = append(trimmed, s.messages[len(s.messages)-keep:]...)
+}
+
+// tokenizer returns the tokenizer for the current model.
+func (s *chatState) tokenizer() tokens.Tokenizer {
+	return tokens.ForModel(s.opts.Model)
+}
+
+// contextLength returns the context window of the current model and where it
+// comes from: --context-length or the context_length setting, or else the
+// provider, asked once per model. It is zero when unknown.
+func (s *chatState) contextLength(ctx context.Context) (int, string) {
+	if s.opts.ContextLength > 0 {
+		return s.opts.ContextLength, "configured"
+	}
+	if s.sizer == nil {
+		return 0, ""
+	}
+
+	length, ok := s.contextLengths[s.opts.Model]
+	if !ok {
+		ctx, cancel := context.WithTimeout(ctx, modelListTimeout)
+		defer cancel()
+		var err error
+		length, err = s.sizer.ContextLength(ctx, s.opts.Model)
+		if err != nil && !s.opts.Quiet {
+			fmt.Fprintf(os.Stderr, "Warning: could not get the context window of %s: %v\n", s.opts.Model, err)
+		}
+		s.contextLengths[s.opts.Model] = length
+	}
+	if length == 0 {
+		return 0, ""
+	}
+	return length, "reported by the provider"
+}
+
+// save writes the session to the store.
+func (s *chatState) save() error {
+	if s.store == nil {
+		return fmt.Errorf("sessions cannot be saved")
+	}
+	s.session.Messages = s.messages
+	s.session.Model = s.opts.Model
+	s.session.SystemPrompt = s.opts.SystemPrompt
+	return s.store.Save(s.session)
+}
+
+// autosave saves the session after an answer. A broken store only costs the
+// autosave, not the conversation, so failures turn saving off with a warning.
+func (s *chatState) autosave() {
+	if s.store == nil {
+		return
+	}
+	if err := s.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save session: %v\n", err)
+		s.store = nil
+	}
+}
+
+// respond streams the model's answer to the conversation and adds it to the
+// history. Ctrl+C stops the answer; what arrived until then is kept and
+// marked as interrupted.
+func (s *chatState) respond(ctx context.Context, p provider.Provider) error {
+	opts := s.opts
+	turnCtx, cancel := withTimeout(ctx, opts.Timeout)
+	defer cancel()
+	turnCtx, release := s.interrupts.Context(turnCtx)
+
+	s.fitContext(turnCtx, p)
+	loader := startLoader(opts)
+
+	var collector provider.Collector
+	printer := newResponsePrinter(opts)
+	started := false
+	err := p.StreamCompletion(turnCtx, s.messages, &provider.CompletionOptions{
+		Model:       opts.Model,
+		Temperature: opts.Temperature,
+	}, func(event provider.StreamEvent) {
+		collector.Handle(event)
+		visible := event.Type == provider.EventContent ||
+			(event.Type == provider.EventReasoning && opts.Reasoning != ReasoningHide)
+		if !visible {
+			return
+		}
+
+		if !started {
+			started = true
+			loader.Stop()
+			fmt.Fprint(s.out, "\nAssistant: ")
+		}
+		if event.Type == provider.EventReasoning {
+			printer.Reasoning(event.Content)
+		} else {
+			printer.Content(event.Content)
+		}
+	})
+	interrupted := release()
+	loader.Stop()
+
+	if interrupted {
+		if started {
+			printer.Finish()
+		}
+		return s.keepInterrupted(collector.Completion())
+	}
+	if err != nil {
+		if started {
+			printer.Finish()
+		}
+		return err
+	}
+	printer.Finish()
+
+	// Only the answer goes back into the history; reasoning is never sent
+	// to the model again.
+	response := collector.Completion()
+	reportCompletion(response, opts)
+	s.lastUsage = response.Usage
+
+	s.messages = append(s.messages, provider.Message{
+		Role:    prompts.RoleAssistant,
+		Content: response.Content,
+	})
+	s.trimHistory()
+	s.autosave()
+	return nil
+}
+
+// keepInterrupted adds the part of an answer received before Ctrl+C to the
+// history. Without any answer the question stays last, ready for /retry.
+func (s *chatState) keepInterrupted(partial *provider.Completion) error {
+	if partial.Content == "" {
+		fmt.Fprintln(s.out, "[interrupted before the answer started; /retry to ask again]")
+		return nil
+	}
+	fmt.Fprintln(s.out, "[interrupted]")
+
+	s.messages = append(s.messages, provider.Message{
+		Role:        prompts.RoleAssistant,
+		Content:     partial.Content,
+		Interrupted: true,
+	})
+	s.trimHistory()
+	s.autosave()
+	return nil
+}
+
+func runInteractiveMode(ctx context.Context, p provider.Provider, opts *ChatOptions) error {
+	fmt.Printf("Starting interactive chat mode with %s (type /help for commands, /exit to quit)\n", p.Name())
+	if opts.Model != "" {
+		fmt.Printf("Model: %s\n", opts.Model)
+	} else {
+		fmt.Println("Model: server default")
+	}
+	if opts.MaxHistory > 0 {
+		fmt.Printf("Message history limit: %d messages\n", opts.MaxHistory)
+	}
+	if opts.SystemPrompt != "" {
+		fmt.Printf("System prompt: %s\n", opts.SystemPrompt)
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: this session will not be saved: %v\n", err)
+	}
+	interrupts := newInterrupter()
+	defer interrupts.Stop()
+	state := newChatState(opts, store, os.Stdout, interrupts)
+	state.sizer, _ = p.(provider.ContextSizer)
+	if state.images, err = loadImages(opts.Images); err != nil {
+		return err
+	}
+	if opts.Session != nil {
+		fmt.Printf("Resuming session %s: %s\n", state.session.ID, state.session.Title)
+		printTranscript(state.messages)
+	}
+	commands := defaultSlashCommands()
+
+	complete := &completer{commands: commands, provider: p, store: store}
+	reader := lineedit.New(lineedit.Options{
+		HistoryFile:    historyFile(),
+		Complete:       complete.Complete,
+		ContinuePrompt: continuePrompt,
+	})
+	defer reader.Close()
+	in := &lineInput{reader: reader, interrupts: interrupts}
+
+	// exitPending is set by a Ctrl+C at an empty prompt; a second one in a
+	// row ends the session.
+	exitPending := false
+	for {
+		fmt.Println()
+		input, err := in.read(ctx, "You: ")
+		if err == lineedit.ErrInterrupted {
+			if exitPending {
+				return nil
+			}
+			exitPending = true
+			fmt.Println("(press Ctrl+C again or Ctrl+D to exit)")
+			continue
+		}
+		exitPending = false
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if ctx.Err() != nil {
+				fmt.Println()
+				return nil
+			}
+			return fmt.Errorf("input error: %w", err)
+		}
+
+		// Pasted text and blocks between """ lines are always messages,
+		// never commands.
+		multiline := strings.Contains(input, "\n")
+		if strings.TrimSpace(input) == blockDelimiter {
+			input, err = readBlock(ctx, in)
+			if err == lineedit.ErrInterrupted {
+				fmt.Println("Cancelled")
+				continue
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					fmt.Println()
+					return nil
+				}
+				return fmt.Errorf("input error: %w", err)
+			}
+			multiline = true
+		}
+
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+
+		// The plain words from before slash commands still work.
+		switch strings.ToLower(input) {
+		case "exit", "quit":
+			input = "/exit"
+		case "clear":
+			input = "/clear"
+		}
+
+		if multiline {
+			if err := state.addUserMessage(input); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+		} else if isSlashCommand(input) {
+			action, err := commands.Dispatch(state, input)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			switch action {
+			case slashExit:
+				return nil
+			case slashNone:
+				continue
+			}
+		} else if err := state.addUserMessage(strings.TrimPrefix(input, "/")); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+
+		if err := state.respond(ctx, p); err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// withSystemPrompt returns messages starting with the given system prompt,
+// replacing any system prompt already at the start; a summary of earlier
+// messages is not one. An empty prompt removes it.
+func withSystemPrompt(messages []provider.Message, prompt string) []provider.Message {
+	var rest []provider.Message
+	if len(messages) > 0 && messages[0].Role == prompts.RoleSystem && !messages[0].Summary {
+		rest = messages[1:]
+	} else {
+		rest = messages
+	}
+
+	result := make([]provider.Message, 0, len(rest)+1)
+	if prompt != "" {
+		result = append(result, provider.Message{Role: prompts.RoleSystem, Content: prompt})
+	}
+	return append(result, rest...)
+}
+
+// readBlock reads the lines of a multiline message up to the closing
+// delimiter or the end of input.
+func readBlock(ctx context.Context, in *lineInput) (string, error) {
+	var lines []string
+	for {
+		line, err := in.read(ctx, continuePrompt)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(line) == blockDelimiter {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// lineInput reads interactive input so that reading can be abandoned when
+// ctx is cancelled or on Ctrl+C, which only a plain reader receives as a
+// signal.
+type lineInput struct {
+	reader     lineedit.Reader
+	interrupts *interrupter
+	// pending is a read that was abandoned but is still waiting for a
+	// line; the next read takes its result.
+	pending chan lineResult
+}
+
+type lineResult struct {
+	line string
+	err  error
+}
+
+func (in *lineInput) read(ctx context.Context, prompt string) (string, error) {
+	if in.pending == nil {
+		done := make(chan lineResult, 1)
+		go func() {
+			line, err := in.reader.ReadLine(prompt)
+			done <- lineResult{line, err}
+		}()
+		in.pending = done
+	} else {
+		fmt.Print(prompt)
+	}
+
+	readCtx, release := in.interrupts.Context(ctx)
+	select {
+	case r := <-in.pending:
+		release()
+		in.pending = nil
+		return r.line, r.err
+	case <-readCtx.Done():
+		if release() {
+			fmt.Println()
+			return "", lineedit.ErrInterrupted
+		}
+		in.reader.Close()
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// ErrInterrupted is returned when the user stops a request with Ctrl+C.
+var ErrInterrupted = errors.New("interrupted")
+
+// ExitInterrupted is the exit status of a run stopped with Ctrl+C, the one
+// shells use for processes ended by SIGINT.
+const ExitInterrupted = 130
+
+// interrupter turns Ctrl+C into the cancellation of the current operation
+// instead of the end of the process.
+type interrupter struct {
+	signals chan os.Signal
+}
+
+func newInterrupter() *interrupter {
+	i := &interrupter{signals: make(chan os.Signal, 1)}
+	signal.Notify(i.signals, os.Interrupt)
+	return i
+}
+
+// Stop gives Ctrl+C its default behavior back.
+func (i *interrupter) Stop() {
+	signal.Stop(i.signals)
+}
+
+// Context returns a context cancelled by the next Ctrl+C. The release
+// function must be called when the operation is over; it reports whether the
+// operation was interrupted.
+func (i *interrupter) Context(ctx context.Context) (context.Context, func() bool) {
+	// Forget a Ctrl+C pressed while nothing was running, e.g. in an editor.
+	select {
+	case <-i.signals:
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var (
+		wg          sync.WaitGroup
+		interrupted bool
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-i.signals:
+			interrupted = true
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() bool {
+		cancel()
+		wg.Wait()
+		return interrupted
+	}
+}
@@ -0,0 +1,264 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/spf13/cobra"
+)
+
+// Output formats for the global --output flag.
+const (
+	// OutputText is the human-readable default.
+	OutputText = "text"
+	// OutputJSON prints one indented JSON document per command.
+	OutputJSON = "json"
+	// OutputJSONL prints one JSON object per line: a line per list item,
+	// and a line per stream event for completions.
+	OutputJSONL = "jsonl"
+)
+
+// outputFormat returns the validated value of the global --output flag.
+func outputFormat(cmd *cobra.Command) (string, error) {
+	f := cmd.Flag("output")
+	if f == nil {
+		return OutputText, nil
+	}
+	switch format := f.Value.String(); format {
+	case OutputText, OutputJSON, OutputJSONL:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid output format %q (use text, json or jsonl)", format)
+	}
+}
+
+// writeOutput prints v as JSON: indented for json, on one line for jsonl.
+func writeOutput(format string, v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	if format == OutputJSON {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
+}
+
+// writeList prints items as a JSON array for json, or one item per line for
+// jsonl.
+func writeList[T any](format string, items []T) error {
+	if format == OutputJSON {
+		if items == nil {
+			items = []T{}
+		}
+		return writeOutput(format, items)
+	}
+	for _, item := range items {
+		if err := writeOutput(format, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statusDocument reports the outcome of commands that change settings.
+type statusDocument struct {
+	Status  string `json:"status"`
+	Profile string `json:"profile,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Value   string `json:"value,omitempty"`
+}
+
+// printStatus reports the outcome of a command that changes settings: text
+// for people, or doc in the structured formats.
+func printStatus(cmd *cobra.Command, text string, doc statusDocument) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	if format != OutputText {
+		return writeOutput(format, doc)
+	}
+	fmt.Println(text)
+	return nil
+}
+
+type providerDocument struct {
+	Type                  string               `json:"type"`
+	Name                  string               `json:"name"`
+	Description           string               `json:"description"`
+	DefaultURL            string               `json:"default_url"`
+	DefaultModel          string               `json:"default_model,omitempty"`
+	DefaultEmbeddingModel string               `json:"default_embedding_model,omitempty"`
+	Capabilities          capabilitiesDocument `json:"capabilities"`
+}
+
+type capabilitiesDocument struct {
+	ListModels bool `json:"list_models"`
+	Reasoning  bool `json:"reasoning"`
+	APIKey     bool `json:"api_key"`
+	BasePath   bool `json:"base_path"`
+	Images     bool `json:"images"`
+	Embeddings bool `json:"embeddings"`
+}
+
+func newProviderDocument(reg provider.Registration) providerDocument {
+	return providerDocument{
+		Type:                  string(reg.Type),
+		Name:                  reg.Name,
+		Description:           reg.Description,
+		DefaultURL:            reg.DefaultURL,
+		DefaultModel:          reg.DefaultModel,
+		DefaultEmbeddingModel: reg.DefaultEmbeddingModel,
+		Capabilities: capabilitiesDocument{
+			ListModels: reg.Capabilities.ListModels,
+			Reasoning:  reg.Capabilities.Reasoning,
+			APIKey:     reg.Capabilities.APIKey,
+			BasePath:   reg.Capabilities.BasePath,
+			Images:     reg.Capabilities.Images,
+			Embeddings: reg.Capabilities.Embeddings,
+		},
+	}
+}
+
+// completionDocument is the structured form of a single-prompt answer.
+type completionDocument struct {
+	Provider     string         `json:"provider"`
+	Model        string         `json:"model,omitempty"`
+	Content      string         `json:"content"`
+	Reasoning    string         `json:"reasoning,omitempty"`
+	FinishReason string         `json:"finish_reason,omitempty"`
+	Usage        *usageDocument `json:"usage,omitempty"`
+	Timing       timingDocument `json:"timing"`
+}
+
+type usageDocument struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	PromptDurationMs float64 `json:"prompt_duration_ms,omitempty"`
+	EvalDurationMs   float64 `json:"eval_duration_ms,omitempty"`
+	TotalDurationMs  float64 `json:"total_duration_ms,omitempty"`
+	TokensPerSecond  float64 `json:"tokens_per_second,omitempty"`
+}
+
+// timingDocument holds the times measured by ai-cli itself, as opposed to
+// those reported by the backend in usage.
+type timingDocument struct {
+	StartedAt    time.Time `json:"started_at"`
+	FirstTokenMs float64   `json:"first_token_ms,omitempty"`
+	TotalMs      float64   `json:"total_ms"`
+}
+
+// eventDocument is one line of a jsonl completion stream. The last line has
+// type "done" and carries the whole completion, or type "error".
+type eventDocument struct {
+	Type       string              `json:"type"`
+	Content    string              `json:"content,omitempty"`
+	Completion *completionDocument `json:"completion,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func newUsageDocument(u *provider.Usage) *usageDocument {
+	if u == nil {
+		return nil
+	}
+	return &usageDocument{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		PromptDurationMs: milliseconds(u.PromptDuration),
+		EvalDurationMs:   milliseconds(u.EvalDuration),
+		TotalDurationMs:  milliseconds(u.TotalDuration),
+		TokensPerSecond:  u.TokensPerSecond(),
+	}
+}
+
+// writeCompletion streams a single-prompt answer and prints it in the
+// structured output format: as one document for json, or as a line per
+// stream event followed by the completion for jsonl. After Ctrl+C the part
+// received is written with the finish reason "interrupted".
+func writeCompletion(ctx context.Context, p provider.Provider, messages []provider.Message, opts *ChatOptions) error {
+	var (
+		collector  provider.Collector
+		start      = time.Now()
+		firstToken time.Duration
+		writeErr   error
+	)
+
+	interrupts := newInterrupter()
+	defer interrupts.Stop()
+	ctx, release := interrupts.Context(ctx)
+
+	loader := startLoader(opts)
+	err := p.StreamCompletion(ctx, messages, &provider.CompletionOptions{
+		Model:       opts.Model,
+		Temperature: opts.Temperature,
+	}, func(event provider.StreamEvent) {
+		collector.Handle(event)
+		if event.Type != provider.EventContent && event.Type != provider.EventReasoning || event.Content == "" {
+			return
+		}
+		if firstToken == 0 {
+			firstToken = time.Since(start)
+			loader.Stop()
+		}
+		if opts.Output == OutputJSONL && writeErr == nil {
+			writeErr = writeOutput(opts.Output, eventDocument{Type: string(event.Type), Content: event.Content})
+		}
+	})
+	interrupted := release()
+	loader.Stop()
+
+	if err != nil && !interrupted {
+		failed := fmt.Errorf("chat completion failed: %w", err)
+		if opts.Output == OutputJSONL {
+			if writeErr := writeOutput(opts.Output, eventDocument{Type: string(provider.EventError), Error: err.Error()}); writeErr != nil {
+				return errors.Join(failed, writeErr)
+			}
+		}
+		return failed
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+
+	// An interrupted answer is still written, with what arrived of it.
+	completion := collector.Completion()
+	if interrupted {
+		completion.FinishReason = provider.FinishInterrupted
+	}
+	doc := completionDocument{
+		Provider:     opts.Provider,
+		Model:        opts.Model,
+		Content:      completion.Content,
+		Reasoning:    completion.Reasoning,
+		FinishReason: completion.FinishReason,
+		Usage:        newUsageDocument(completion.Usage),
+		Timing: timingDocument{
+			StartedAt:    start,
+			FirstTokenMs: milliseconds(firstToken),
+			TotalMs:      milliseconds(time.Since(start)),
+		},
+	}
+	if !interrupted {
+		reportCompletion(completion, opts)
+	}
+
+	if opts.Output == OutputJSONL {
+		err = writeOutput(opts.Output, eventDocument{Type: "done", Completion: &doc})
+	} else {
+		err = writeOutput(opts.Output, doc)
+	}
+	if err == nil && interrupted {
+		return interruptedError(opts)
+	}
+	return err
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/config"
+	"github.com/ahr9n/ai-cli/pkg/provider/plugin"
+	"github.com/spf13/cobra"
+)
+
+// registerPlugins makes plugins declared in the config file and plugins found
+// on PATH available as providers. Declared plugins take precedence.
+func registerPlugins() {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: plugins from the config file are unavailable: %v\n", err)
+	} else {
+		for name, path := range cfg.Plugins {
+			plugin.Register(name, path)
+		}
+	}
+	plugin.RegisterDiscovered()
+}
+
+type pluginDocument struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type checkDocument struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func newPluginsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plugins",
+		Short: "Manage external provider plugins",
+		Long: `Provider plugins are programs named ` + plugin.ExecutablePrefix + `<name> found on PATH,
+or declared in the config file with "ai-cli config set plugins.<name> <path>".
+Each one is available as "ai-cli <name>" and speaks the protocol described in
+docs/plugin-protocol.md.`,
+	}
+
+	cmd.AddCommand(
+		newPluginsListCommand(),
+		newPluginsCheckCommand(),
+	)
+
+	return cmd
+}
+
+func newPluginsListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List provider plugins found on PATH",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plugins := plugin.Discover()
+
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			if format != OutputText {
+				docs := make([]pluginDocument, 0, len(plugins))
+				for name, path := range plugins {
+					docs = append(docs, pluginDocument{Name: name, Path: path})
+				}
+				sort.Slice(docs, func(i, j int) bool { return docs[i].Name < docs[j].Name })
+				return writeList(format, docs)
+			}
+
+			if len(plugins) == 0 {
+				fmt.Printf("No plugins found (looking for %s* on PATH)\n", plugin.ExecutablePrefix)
+				return nil
+			}
+
+			names := make([]string, 0, len(plugins))
+			for name := range plugins {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				fmt.Printf("%s\t%s\n", name, plugins[name])
+			}
+			return nil
+		},
+	}
+}
+
+func newPluginsCheckCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check [name|path]",
+		Short: "Run the protocol conformance checks against a plugin",
+		Example: `  ai-cli plugins check echo
+  ai-cli plugins check ./bin/ai-cli-provider-gateway`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := pluginPath(args[0])
+			if err != nil {
+				return err
+			}
+
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			failed := 0
+			results := plugin.Conformance(cmd.Context(), path)
+			docs := make([]checkDocument, len(results))
+			for i, result := range results {
+				docs[i] = checkDocument{Name: result.Name, OK: result.Err == nil}
+				if result.Err != nil {
+					failed++
+					docs[i].Error = result.Err.Error()
+				}
+			}
+
+			if format != OutputText {
+				if err := writeList(format, docs); err != nil {
+					return err
+				}
+			} else {
+				for _, doc := range docs {
+					if doc.OK {
+						fmt.Printf("ok    %s\n", doc.Name)
+					} else {
+						fmt.Printf("FAIL  %s: %s\n", doc.Name, doc.Error)
+					}
+				}
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d conformance check(s) failed", failed)
+			}
+			return nil
+		},
+	}
+}
+
+// pluginPath resolves a plugin given by name or by path.
+func pluginPath(nameOrPath string) (string, error) {
+	if strings.ContainsRune(nameOrPath, os.PathSeparator) {
+		return nameOrPath, nil
+	}
+	if path, ok := plugin.Discover()[nameOrPath]; ok {
+		return path, nil
+	}
+	if path, err := exec.LookPath(nameOrPath); err == nil {
+		return path, nil
+	}
+	return "", fmt.Errorf("plugin %q not found (looking for %s%s on PATH)", nameOrPath, plugin.ExecutablePrefix, nameOrPath)
+}
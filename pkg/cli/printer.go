@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/markdown"
+	"github.com/ahr9n/ai-cli/pkg/utils"
+)
+
+// Reasoning display modes for the --reasoning flag.
+const (
+	ReasoningAuto   = "auto"
+	ReasoningHide   = "hide"
+	ReasoningDim    = "dim"
+	ReasoningStderr = "stderr"
+)
+
+const (
+	dimColor   = "\033[2m"
+	resetColor = "\033[0m"
+)
+
+// resolveReasoningMode validates the --reasoning flag and picks a concrete mode
+// for "auto": dimmed on a terminal, hidden when stdout is redirected.
+func resolveReasoningMode(mode string) (string, error) {
+	switch mode {
+	case ReasoningHide, ReasoningDim, ReasoningStderr:
+		return mode, nil
+	case ReasoningAuto, "":
+		if utils.IsTerminal(os.Stdout) {
+			return ReasoningDim, nil
+		}
+		return ReasoningHide, nil
+	default:
+		return "", fmt.Errorf("invalid reasoning mode %q (use auto, hide, dim or stderr)", mode)
+	}
+}
+
+// responsePrinter writes an assistant response, keeping the model's reasoning
+// apart from the answer according to the reasoning mode. On a terminal with
+// colors the answer is rendered from Markdown.
+type responsePrinter struct {
+	out         io.Writer
+	errOut      io.Writer
+	mode        string
+	color       bool
+	inReasoning bool
+	// trailing holds reasoning whitespace that is only printed if more
+	// reasoning follows, so the answer does not start after blank lines.
+	trailing string
+	// md renders the answer; nil prints it as it is.
+	md *markdown.Renderer
+}
+
+func newResponsePrinter(opts *ChatOptions) *responsePrinter {
+	p := &responsePrinter{
+		out:    os.Stdout,
+		errOut: os.Stderr,
+		mode:   opts.Reasoning,
+		color:  !opts.NoColor,
+	}
+	if p.color && utils.IsTerminal(os.Stdout) {
+		p.md = markdown.NewRenderer(p.out)
+	}
+	return p
+}
+
+func (p *responsePrinter) Reasoning(text string) {
+	switch p.mode {
+	case ReasoningDim:
+		trimmed := strings.TrimRight(text, " \t\r\n")
+		if trimmed == "" {
+			p.trailing += text
+			return
+		}
+		if !p.inReasoning {
+			if p.color {
+				fmt.Fprint(p.out, dimColor)
+			}
+			p.inReasoning = true
+		}
+		fmt.Fprint(p.out, p.trailing+trimmed)
+		p.trailing = text[len(trimmed):]
+	case ReasoningStderr:
+		fmt.Fprint(p.errOut, text)
+		p.inReasoning = true
+	}
+}
+
+func (p *responsePrinter) Content(text string) {
+	p.endReasoning()
+	if p.md != nil {
+		p.md.Write([]byte(text))
+		return
+	}
+	fmt.Fprint(p.out, text)
+}
+
+// Finish ends the response, resetting any style still in effect.
+func (p *responsePrinter) Finish() {
+	p.endReasoning()
+	if p.md != nil {
+		p.md.Flush()
+	}
+	fmt.Fprintln(p.out)
+}
+
+func (p *responsePrinter) endReasoning() {
+	if !p.inReasoning {
+		return
+	}
+	p.inReasoning = false
+	p.trailing = ""
+	switch p.mode {
+	case ReasoningDim:
+		if p.color {
+			fmt.Fprint(p.out, resetColor)
+		}
+		fmt.Fprint(p.out, "\n\n")
+	case ReasoningStderr:
+		fmt.Fprintln(p.errOut)
+	}
+}
@@ -1,37 +1,27 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"text/tabwriter"
 
+	"github.com/ahr9n/ai-cli/pkg/config"
 	"github.com/ahr9n/ai-cli/pkg/provider"
-	"github.com/ahr9n/ai-cli/pkg/provider/localai"
-	"github.com/ahr9n/ai-cli/pkg/provider/ollama"
 	"github.com/spf13/cobra"
+
+	// Built-in providers register themselves with the provider registry.
+	_ "github.com/ahr9n/ai-cli/pkg/provider/localai"
+	_ "github.com/ahr9n/ai-cli/pkg/provider/ollama"
+	_ "github.com/ahr9n/ai-cli/pkg/provider/openai"
 )
 
-func AvailableProvidersList() []struct {
-	Type        provider.ProviderType
-	Name        string
-	Description string
-} {
-	return []struct {
-		Type        provider.ProviderType
-		Name        string
-		Description string
-	}{
-		{
-			Type:        provider.Ollama,
-			Name:        "Ollama",
-			Description: "Run large language models locally",
-		},
-		{
-			Type:        provider.LocalAI,
-			Name:        "LocalAI",
-			Description: "Self-hosted AI model server compatible with OpenAI's API",
-		},
-	}
+// AvailableProvidersList returns every registered provider, including ones
+// registered by code embedding ai-cli.
+func AvailableProvidersList() []provider.Registration {
+	return provider.Registered()
 }
 
 func listProvidersCommand() *cobra.Command {
@@ -41,12 +31,30 @@ func listProvidersCommand() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			providers := AvailableProvidersList()
 
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			if format != OutputText {
+				docs := make([]providerDocument, len(providers))
+				for i, reg := range providers {
+					docs[i] = newProviderDocument(reg)
+				}
+				return writeList(format, docs)
+			}
+
 			fmt.Println("Available Providers:")
 			fmt.Println("--------------------")
 			for _, p := range providers {
 				fmt.Printf("\n%s\n", p.Name)
 				fmt.Printf("Description: %s\n", p.Description)
-				fmt.Printf("Default URL: %s\n", provider.DefaultURLs[p.Type])
+				fmt.Printf("Default URL: %s\n", p.DefaultURL)
+				if p.DefaultModel != "" {
+					fmt.Printf("Default model: %s\n", p.DefaultModel)
+				}
+				if p.DefaultEmbeddingModel != "" {
+					fmt.Printf("Default embedding model: %s\n", p.DefaultEmbeddingModel)
+				}
 				fmt.Printf("Type: %s\n", p.Type)
 			}
 
@@ -55,102 +63,198 @@ func listProvidersCommand() *cobra.Command {
 	}
 }
 
-func NewProvider(providerType provider.ProviderType, baseURL string) (provider.Provider, error) {
-	if baseURL == "" {
-		baseURL = provider.DefaultURLs[providerType]
-	}
-
-	switch providerType {
-	case provider.Ollama:
-		return ollama.NewClient(baseURL), nil
-	case provider.LocalAI:
-		return localai.NewClient(baseURL), nil
-	default:
-		return nil, fmt.Errorf("unknown provider type: %s", providerType)
-	}
+func NewProvider(providerType provider.ProviderType, cfg provider.Config) (provider.Provider, error) {
+	return provider.NewProvider(providerType, cfg)
 }
 
 func addCommonFlags(cmd *cobra.Command, opts *ChatOptions) {
 	flags := cmd.Flags()
 	flags.BoolVarP(&opts.Interactive, "interactive", "i", false, "Start interactive chat mode")
 	flags.Float32VarP(&opts.Temperature, "temperature", "t", 0.7, "Sampling temperature (0.0-2.0)")
-	flags.BoolVar(&opts.ListModels, "list-models", false, "List available models")
 	flags.StringVarP(&opts.SystemPrompt, "system", "s", "", "System prompt to set the assistant's behavior")
-	flags.IntVarP(&opts.MaxHistory, "max-history", "", 20, "Maximum conversation history to keep (0 = unlimited)")
+	flags.IntVarP(&opts.MaxHistory, "max-history", "", 0, "Maximum number of messages to keep (0 = as many as fit the context window)")
+	flags.IntVar(&opts.ContextLength, "context-length", 0, "Context window of the model in tokens (0 = ask the provider)")
+	flags.StringVar(&opts.Compaction, "compaction", CompactDrop, "What to do with earlier messages that outgrow the context window: drop, summarize or window")
 	flags.StringVarP(&opts.PresetPrompt, "preset", "p", "", "Use a preset system prompt (creative, concise, code)")
+	flags.DurationVar(&opts.Timeout, "timeout", 0, "Maximum time to wait for each response, e.g. 90s or 5m (0 = no limit)")
+	flags.BoolVar(&opts.Stats, "stats", false, "Print token usage and speed after each response")
+	flags.StringArrayVarP(&opts.Headers, "header", "H", nil, "Extra request header as 'Name: value' (repeatable)")
+	flags.StringVar(&opts.StdinRole, "stdin-role", StdinContext, "How piped input joins the prompt: context (fenced block), user (as is) or system (added to the system prompt)")
+	flags.StringVar(&opts.Resume, "resume", "", "Resume a saved session in interactive mode: its ID, an ID prefix or 'last'")
+	flags.Int64Var(&opts.StdinLimit, "stdin-limit", defaultStdinLimit, "Maximum size of piped input in bytes (0 = unlimited)")
+	flags.BoolVar(&opts.Editor, "editor", false, "Write the prompt in $EDITOR, starting from the prompt arguments")
+	flags.StringArrayVarP(&opts.Files, "file", "f", nil, "Attach a file, directory or glob such as 'src/**/*.go' to the prompt (repeatable)")
+	flags.Int64Var(&opts.FileLimit, "file-limit", defaultFileLimit, "Maximum size of an attached file in bytes (0 = unlimited)")
+	flags.Int64Var(&opts.FilesTotalLimit, "files-total-limit", defaultFilesTotalLimit, "Maximum size of all attached files together in bytes (0 = unlimited)")
+	flags.BoolVar(&opts.NoStream, "no-stream", false, "Print a single answer only once it is complete instead of as it arrives")
+	flags.StringVar(&opts.RAG, "rag", "", "Send the excerpts of an index built with 'ai-cli index build' that best match the prompt, for the model to cite")
+	flags.IntVar(&opts.RAGTopK, "rag-top-k", defaultTopK, "Number of excerpts retrieved with --rag")
 }
 
-func newOllamaCommand() *cobra.Command {
-	opts := &ChatOptions{}
+// addProviderFlags adds the flags that depend on what a provider supports.
+func addProviderFlags(cmd *cobra.Command, reg provider.Registration, opts *ChatOptions) {
+	flags := cmd.Flags()
 
-	cmd := &cobra.Command{
-		Use:   "ollama [prompt]",
-		Short: "Use Ollama provider",
-		Long:  `Use Ollama to run large language models locally`,
-		Example: `  ai-cli ollama "What is the capital of Palestine?"
-  ai-cli ollama -i  # Start interactive mode
-  ai-cli ollama --model mistral "Write a story"
-  ai-cli ollama -p creative "Tell me a short story"`,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			p, err := NewProvider(provider.Ollama, opts.ProviderURL)
-			if err != nil {
-				return err
-			}
-			if opts.ListModels {
-				return displayModels(p)
-			}
-			resolveSystemPrompt(opts)
+	modelUsage := "Model to use"
+	if reg.DefaultModel == "" {
+		modelUsage += " (default: chosen by the provider)"
+	}
+	flags.StringVarP(&opts.Model, "model", "m", reg.DefaultModel, modelUsage)
+	flags.StringVarP(&opts.ProviderURL, "url", "u", reg.DefaultURL, "Provider API URL (optional)")
 
-			return runChat(p, opts, args)
-		},
+	if reg.Capabilities.ListModels {
+		flags.BoolVar(&opts.ListModels, "list-models", false, "List available models")
 	}
+	if reg.Capabilities.Reasoning {
+		flags.StringVar(&opts.Reasoning, "reasoning", ReasoningAuto, "How to show model reasoning: auto, hide, dim or stderr")
+	}
+	if reg.Capabilities.APIKey {
+		apiKeyUsage := "API key sent as a bearer token (default $AI_CLI_API_KEY"
+		if reg.APIKeyEnv != "" {
+			apiKeyUsage += " or $" + reg.APIKeyEnv
+		}
+		flags.StringVar(&opts.APIKey, "api-key", "", apiKeyUsage+")")
+	}
+	if reg.Capabilities.BasePath {
+		flags.StringVar(&opts.BasePath, "base-path", "", "API path prefix on the server (default \"v1\")")
+	}
+	if reg.Capabilities.Images {
+		flags.StringArrayVar(&opts.Images, "image", nil, "Send an image file with the prompt to a vision model; - reads it from stdin (repeatable)")
+	}
+}
 
-	addCommonFlags(cmd, opts)
-	cmd.Flags().StringVarP(&opts.Model, "model", "m", "deepseek-r1:1.5b", "Model to use")
-	cmd.Flags().StringVarP(&opts.ProviderURL, "url", "u", provider.DefaultURLs[provider.Ollama], "Provider API URL (optional)")
+// providerConfig builds the connection settings for a provider command.
+// Headers given with --header are added to the configured ones, replacing
+// those of the same name.
+func providerConfig(resolved *config.Resolved, opts *ChatOptions) (provider.Config, error) {
+	cfg := provider.Config{
+		BaseURL:  opts.ProviderURL,
+		BasePath: opts.BasePath,
+		APIKey:   opts.APIKey,
+	}
 
-	return cmd
+	// Providers without an --api-key or --base-path flag still receive the
+	// configured values.
+	if s, ok := resolved.Get("api_key"); ok && cfg.APIKey == "" {
+		cfg.APIKey = s.Value
+	}
+	if s, ok := resolved.Get("base_path"); ok && cfg.BasePath == "" {
+		cfg.BasePath = s.Value
+	}
+
+	for _, header := range resolved.Headers() {
+		if cfg.Headers == nil {
+			cfg.Headers = make(map[string]string)
+		}
+		cfg.Headers[strings.TrimPrefix(header.Key, "headers.")] = header.Value
+	}
+	for _, header := range opts.Headers {
+		name, value, err := config.ParseHeader(header)
+		if err != nil {
+			return cfg, err
+		}
+		if cfg.Headers == nil {
+			cfg.Headers = make(map[string]string)
+		}
+		cfg.Headers[name] = value
+	}
+
+	return cfg, nil
 }
 
-func newLocalAICommand() *cobra.Command {
+// newProviderCommand builds the chat command of a registered provider.
+func newProviderCommand(reg provider.Registration) *cobra.Command {
 	opts := &ChatOptions{}
 
+	examples := []string{
+		fmt.Sprintf(`ai-cli %s "What is the capital of Palestine?"`, reg.Type),
+		fmt.Sprintf(`ai-cli %s -i  # Start interactive mode`, reg.Type),
+		fmt.Sprintf(`ai-cli %s -i --resume last  # Continue the most recent session`, reg.Type),
+		fmt.Sprintf(`ai-cli %s -p creative "Tell me a short story"`, reg.Type),
+		fmt.Sprintf(`cat error.log | ai-cli %s "Explain this error"`, reg.Type),
+	}
+	examples = append(examples, reg.Examples...)
+
 	cmd := &cobra.Command{
-		Use:   "localai [prompt]",
-		Short: "Use LocalAI provider",
-		Long:  `Use LocalAI self-hosted model server`,
-		Example: `  ai-cli localai "What is the capital of Palestine?"
-  ai-cli localai -i  # Start interactive mode
-  ai-cli localai --model gpt-3.5-turbo "Write a story"
-  ai-cli localai -p code "Explain binary search"`,
-		Args: cobra.ArbitraryArgs,
+		Use:     fmt.Sprintf("%s [prompt]", reg.Type),
+		Short:   fmt.Sprintf("Use %s provider", reg.Name),
+		Long:    reg.Description,
+		Example: "  " + strings.Join(examples, "\n  "),
+		Args:    cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			p, err := NewProvider(provider.LocalAI, opts.ProviderURL)
+			resolved, err := resolveSettings(cmd, reg)
+			if err != nil {
+				return err
+			}
+			if err := applySettings(cmd, resolved); err != nil {
+				return err
+			}
+			if err := applyOutputFlags(cmd, opts); err != nil {
+				return err
+			}
+			opts.Provider = string(reg.Type)
+			if err := resumeSession(cmd, opts); err != nil {
+				return err
+			}
+			if err := openRetriever(cmd, opts); err != nil {
+				return err
+			}
+
+			cfg, err := providerConfig(resolved, opts)
+			if err != nil {
+				return err
+			}
+			p, err := provider.NewProvider(reg.Type, cfg)
 			if err != nil {
 				return err
 			}
 			if opts.ListModels {
-				return displayModels(p)
+				return displayModels(cmd.Context(), p, opts)
 			}
 			resolveSystemPrompt(opts)
 
-			return runChat(p, opts, args)
+			err = runChat(cmd.Context(), p, opts, args)
+			if errors.Is(err, ErrInterrupted) {
+				// The user knows; only the exit status reports it.
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+			}
+			return err
 		},
 	}
 
 	addCommonFlags(cmd, opts)
-	cmd.Flags().StringVarP(&opts.Model, "model", "m", "gpt-3.5-turbo", "Model to use")
-	cmd.Flags().StringVarP(&opts.ProviderURL, "url", "u", provider.DefaultURLs[provider.LocalAI], "Provider API URL (optional)")
+	addProviderFlags(cmd, reg, opts)
+
+	if reg.Capabilities.Embeddings {
+		cmd.AddCommand(newEmbedCommand(reg))
+	}
 
 	return cmd
 }
 
-func displayModels(p provider.Provider) error {
-	models, err := p.ListModels()
+// providerCommands returns a chat command for every registered provider.
+func providerCommands() []*cobra.Command {
+	var cmds []*cobra.Command
+	for _, reg := range provider.Registered() {
+		cmds = append(cmds, newProviderCommand(reg))
+	}
+	return cmds
+}
+
+func displayModels(ctx context.Context, p provider.Provider, opts *ChatOptions) error {
+	ctx, cancel := withTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	models, err := p.ListModels(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list models: %w", err)
 	}
 
+	if opts.Output != OutputText {
+		return writeList(opts.Output, models)
+	}
+
 	if len(models) == 0 {
 		fmt.Printf("No models found for %s\n", p.Name())
 		return nil
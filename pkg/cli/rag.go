@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/ahr9n/ai-cli/pkg/rag"
+	"github.com/spf13/cobra"
+)
+
+// defaultTopK is how many excerpts are retrieved unless --rag-top-k or -k
+// say otherwise.
+const defaultTopK = 4
+
+// retriever finds the excerpts of an index that go with a question.
+type retriever struct {
+	index *rag.Index
+	embed rag.EmbedFunc
+	topK  int
+}
+
+// newEmbedFunc connects to a provider for embedding with opts.Model. Each
+// call is bounded by opts.Timeout and shows the progress spinner.
+func newEmbedFunc(cmd *cobra.Command, reg provider.Registration, opts *ChatOptions) (rag.EmbedFunc, error) {
+	resolved, err := resolveSettings(cmd, reg)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := providerConfig(resolved, opts)
+	if err != nil {
+		return nil, err
+	}
+	p, err := provider.NewProvider(reg.Type, cfg)
+	if err != nil {
+		return nil, err
+	}
+	embedder, ok := p.(provider.Embedder)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support embeddings", reg.Name)
+	}
+
+	return func(ctx context.Context, texts []string) ([][]float32, error) {
+		ctx, cancel := withTimeout(ctx, opts.Timeout)
+		defer cancel()
+		loader := startLoader(opts)
+		vectors, err := embedder.Embed(ctx, texts, &provider.EmbeddingOptions{Model: opts.Model})
+		loader.Stop()
+		if err != nil {
+			return nil, fmt.Errorf("embedding failed: %w", err)
+		}
+		return vectors, nil
+	}, nil
+}
+
+// indexEmbedFunc embeds questions for idx with the provider, URL and model
+// its chunks were embedded with. The connection settings of opts are used
+// when it is for the same provider.
+func indexEmbedFunc(cmd *cobra.Command, idx *rag.Index, opts *ChatOptions) (rag.EmbedFunc, error) {
+	reg, ok := provider.Lookup(provider.ProviderType(idx.Provider))
+	if !ok {
+		return nil, fmt.Errorf("index %s was built with the unknown provider %q", idx.Name, idx.Provider)
+	}
+	embedOpts := &ChatOptions{
+		ProviderURL: idx.URL,
+		Model:       idx.Model,
+		Timeout:     opts.Timeout,
+		Quiet:       opts.Quiet,
+		NoColor:     opts.NoColor,
+		Spinner:     opts.Spinner,
+	}
+	if opts.Provider == idx.Provider {
+		embedOpts.APIKey = opts.APIKey
+		embedOpts.BasePath = opts.BasePath
+		embedOpts.Headers = opts.Headers
+	}
+	return newEmbedFunc(cmd, reg, embedOpts)
+}
+
+// openRetriever loads the index named with --rag, if any.
+func openRetriever(cmd *cobra.Command, opts *ChatOptions) error {
+	if opts.RAG == "" {
+		return nil
+	}
+	if opts.RAGTopK < 1 {
+		return fmt.Errorf("--rag-top-k must be at least 1")
+	}
+	idx, err := rag.Load(opts.RAG)
+	if err != nil {
+		return err
+	}
+	embed, err := indexEmbedFunc(cmd, idx, opts)
+	if err != nil {
+		return err
+	}
+	opts.retriever = &retriever{index: idx, embed: embed, topK: opts.RAGTopK}
+	return nil
+}
+
+// addRetrievedContext appends to prompt the excerpts of the --rag index
+// closest to question, numbered for citing, and lists them on out.
+func addRetrievedContext(ctx context.Context, prompt, question string, opts *ChatOptions, out io.Writer) (string, error) {
+	r := opts.retriever
+	if r == nil || strings.TrimSpace(question) == "" {
+		return prompt, nil
+	}
+	results, err := r.index.Query(ctx, r.embed, question, r.topK)
+	if err != nil {
+		return "", fmt.Errorf("retrieval from index %s failed: %w", r.index.Name, err)
+	}
+	if !opts.Quiet {
+		listExcerpts(out, r.index, results)
+	}
+	if len(results) == 0 {
+		return prompt, nil
+	}
+
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\nAnswer using the numbered excerpts below where they are relevant, and cite the ones you use by number, as in [1]. If they do not hold the answer, say so.")
+	for i, res := range results {
+		lang := strings.TrimPrefix(path.Ext(res.Path), ".")
+		fmt.Fprintf(&b, "\n\n[%d] %s, lines %d-%d\n%s", i+1, res.Path, res.StartLine, res.EndLine, fence(lang, res.Text))
+	}
+	return b.String(), nil
+}
+
+// listExcerpts tells the user which excerpts go with the prompt.
+func listExcerpts(out io.Writer, idx *rag.Index, results []rag.Result) {
+	if len(results) == 0 {
+		fmt.Fprintf(out, "No excerpts found in index %s\n", idx.Name)
+		return
+	}
+	noun := "excerpts"
+	if len(results) == 1 {
+		noun = "excerpt"
+	}
+	fmt.Fprintf(out, "Retrieved %d %s from index %s:\n", len(results), noun, idx.Name)
+	for i, res := range results {
+		fmt.Fprintf(out, "  [%d] %s:%d-%d (%.3f)\n", i+1, res.Path, res.StartLine, res.EndLine, res.Score)
+	}
+}
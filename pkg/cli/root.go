@@ -1,7 +1,14 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
 	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/session"
+	"github.com/ahr9n/ai-cli/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -14,6 +21,45 @@ type ChatOptions struct {
 	SystemPrompt string
 	MaxHistory   int
 	PresetPrompt string
+	Timeout      time.Duration
+	Stats        bool
+	Reasoning    string
+	APIKey       string
+	Headers      []string
+	BasePath     string
+	StdinRole    string
+	StdinLimit   int64
+	Editor       bool
+	NoColor      bool
+	Quiet        bool
+	Spinner      utils.SpinnerStyle
+	Output       string
+	// Provider is the type of the provider being run, for output.
+	Provider string
+	Resume   string
+	// Session is the saved session being resumed, if any.
+	Session *session.Session
+	// ContextLength overrides the context window reported by the provider.
+	ContextLength int
+	// Compaction is the strategy for history that outgrows the context.
+	Compaction string
+	// NoStream waits for the whole answer of a single prompt before
+	// printing it.
+	NoStream bool
+	// Files are attached to the prompt, or to the first message in
+	// interactive mode.
+	Files           []string
+	FileLimit       int64
+	FilesTotalLimit int64
+	// Images are sent with the prompt to vision models; "-" reads one from
+	// stdin.
+	Images []string
+	// RAG names an index whose excerpts closest to each prompt are sent
+	// with it; RAGTopK is how many.
+	RAG     string
+	RAGTopK int
+	// retriever searches the RAG index once it is open.
+	retriever *retriever
 }
 
 func NewRootCommand() *cobra.Command {
@@ -22,22 +68,63 @@ func NewRootCommand() *cobra.Command {
 		Short: "A CLI tool to interact with various AI models",
 		Long: `A command-line interface for interacting with various AI providers and models.
 Supports both single prompts and interactive conversations.`,
+		// Prompts given without a provider command are passed on to the
+		// default provider together with their flags, so the root command
+		// leaves flag parsing to it.
+		Args:               cobra.ArbitraryArgs,
+		DisableFlagParsing: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return HandleDefaultProvider(cmd, args)
 		},
 	}
+	flags := cmd.PersistentFlags()
+	flags.String("profile", "", "Configuration profile to use")
+	flags.Bool("no-color", false, "Disable colors (also set by the NO_COLOR environment variable)")
+	flags.BoolP("quiet", "q", false, "Hide the progress spinner and notices")
+	flags.StringP("output", "o", OutputText, "Output format: text, json or jsonl")
+	flags.String("spinner", utils.Dots.Name, "Progress spinner style: "+strings.Join(utils.StyleNames(), ", "))
+
+	registerPlugins()
 
 	cmd.AddCommand(
 		versionCommand(),
 		listProvidersCommand(),
-		newOllamaCommand(),
-		newLocalAICommand(),
 		newDefaultCommand(),
+		newPluginsCommand(),
+		newConfigCommand(),
+		newSessionsCommand(),
+		newIndexCommand(),
 	)
+	cmd.AddCommand(providerCommands()...)
 
 	return cmd
 }
 
+// applyOutputFlags copies the global output flags into opts.
+func applyOutputFlags(cmd *cobra.Command, opts *ChatOptions) error {
+	opts.NoColor = os.Getenv("NO_COLOR") != ""
+	if f := cmd.Flag("no-color"); f != nil && f.Value.String() == "true" {
+		opts.NoColor = true
+	}
+	if f := cmd.Flag("quiet"); f != nil {
+		opts.Quiet = f.Value.String() == "true"
+	}
+
+	name := utils.Dots.Name
+	if f := cmd.Flag("spinner"); f != nil {
+		name = f.Value.String()
+	}
+	var err error
+	style, ok := utils.LookupStyle(name)
+	if !ok {
+		return fmt.Errorf("unknown spinner style %q (available: %s)", name, strings.Join(utils.StyleNames(), ", "))
+	}
+	opts.Spinner = style
+
+	opts.Output, err = outputFormat(cmd)
+	return err
+}
+
 func resolveSystemPrompt(opts *ChatOptions) {
 	if opts.SystemPrompt != "" {
 		return
@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/ahr9n/ai-cli/pkg/session"
+	"github.com/spf13/cobra"
+)
+
+func newSessionsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Manage saved interactive sessions",
+		Long: `Interactive conversations are saved after every answer. Resume one with
+"ai-cli <provider> -i --resume <id|last>". Sessions can be referred to by ID,
+by a unique ID prefix, or as "last" for the most recent one.`,
+	}
+
+	cmd.AddCommand(
+		newSessionsListCommand(),
+		newSessionsShowCommand(),
+		newSessionsDeleteCommand(),
+		newSessionsRenameCommand(),
+	)
+
+	return cmd
+}
+
+// sessionSummary is the structured form of a session in listings.
+type sessionSummary struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model,omitempty"`
+	Turns     int       `json:"turns"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newSessionsListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List saved sessions, most recent first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := session.NewStore()
+			if err != nil {
+				return err
+			}
+			sessions, err := store.List()
+			if err != nil {
+				return err
+			}
+
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			if format != OutputText {
+				docs := make([]sessionSummary, len(sessions))
+				for i, sess := range sessions {
+					docs[i] = sessionSummary{
+						ID:        sess.ID,
+						Title:     sess.Title,
+						Provider:  sess.Provider,
+						Model:     sess.Model,
+						Turns:     sess.Turns(),
+						CreatedAt: sess.CreatedAt,
+						UpdatedAt: sess.UpdatedAt,
+					}
+				}
+				return writeList(format, docs)
+			}
+
+			if len(sessions) == 0 {
+				fmt.Println("No saved sessions")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "ID\tTITLE\tPROVIDER\tMODEL\tTURNS\tUPDATED")
+			fmt.Fprintln(w, "--\t-----\t--------\t-----\t-----\t-------")
+			for _, sess := range sessions {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n",
+					sess.ID,
+					truncate(sess.Title, 40),
+					sess.Provider,
+					sess.Model,
+					sess.Turns(),
+					sess.UpdatedAt.Local().Format("2006-01-02 15:04"),
+				)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newSessionsShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show [id|last]",
+		Short: "Print a saved session",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := session.NewStore()
+			if err != nil {
+				return err
+			}
+			sess, err := store.Load(args[0])
+			if err != nil {
+				return err
+			}
+
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			if format != OutputText {
+				return writeOutput(format, sess)
+			}
+
+			fmt.Printf("Session: %s\n", sess.ID)
+			fmt.Printf("Title: %s\n", sess.Title)
+			fmt.Printf("Provider: %s\n", sess.Provider)
+			if sess.Model != "" {
+				fmt.Printf("Model: %s\n", sess.Model)
+			}
+			fmt.Printf("Updated: %s\n", sess.UpdatedAt.Local().Format(time.RFC1123))
+			printTranscript(sess.Messages)
+			return nil
+		},
+	}
+}
+
+func newSessionsDeleteCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete [id|last]...",
+		Short: "Delete saved sessions",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := session.NewStore()
+			if err != nil {
+				return err
+			}
+			for _, ref := range args {
+				sess, err := store.Delete(ref)
+				if err != nil {
+					return err
+				}
+				if err := printStatus(cmd, "Deleted session "+sess.ID, statusDocument{Status: "deleted", Key: sess.ID}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newSessionsRenameCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename [id|last] [title]",
+		Short: "Change the title of a saved session",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := session.NewStore()
+			if err != nil {
+				return err
+			}
+			title := strings.Join(args[1:], " ")
+			sess, err := store.Rename(args[0], title)
+			if err != nil {
+				return err
+			}
+			return printStatus(cmd, fmt.Sprintf("Renamed session %s to %q", sess.ID, title),
+				statusDocument{Status: "renamed", Key: sess.ID, Value: title})
+		},
+	}
+}
+
+// resumeSession loads the session named by --resume, which implies
+// interactive mode. The session's model and system prompt apply unless they
+// are given as flags.
+func resumeSession(cmd *cobra.Command, opts *ChatOptions) error {
+	if opts.Resume == "" {
+		return nil
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return err
+	}
+	sess, err := store.Load(opts.Resume)
+	if err != nil {
+		return err
+	}
+	if sess.Provider != opts.Provider {
+		return fmt.Errorf("session %s was created with %s; resume it with: ai-cli %s -i --resume %s",
+			sess.ID, sess.Provider, sess.Provider, sess.ID)
+	}
+
+	if !cmd.Flags().Changed("model") {
+		opts.Model = sess.Model
+	}
+	if !cmd.Flags().Changed("system") && !cmd.Flags().Changed("preset") {
+		opts.SystemPrompt = sess.SystemPrompt
+	}
+	opts.Interactive = true
+	opts.Session = sess
+	return nil
+}
+
+// printTranscript prints the user and assistant turns of a conversation.
+func printTranscript(messages []provider.Message) {
+	printTranscriptTo(os.Stdout, messages)
+}
+
+func printTranscriptTo(w io.Writer, messages []provider.Message) {
+	for _, msg := range messages {
+		switch msg.Role {
+		case prompts.RoleSystem:
+			if msg.Summary {
+				fmt.Fprintf(w, "\n%s\n", msg.Content)
+			}
+		case prompts.RoleUser:
+			fmt.Fprintf(w, "\nYou: %s\n", msg.Content)
+			if len(msg.Images) > 0 {
+				fmt.Fprintln(w, describeImages(msg.Images))
+			}
+		case prompts.RoleAssistant:
+			fmt.Fprintf(w, "\nAssistant: %s\n", msg.Content)
+			if msg.Interrupted {
+				fmt.Fprintln(w, "[interrupted]")
+			}
+		}
+	}
+}
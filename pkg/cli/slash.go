@@ -0,0 +1,418 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/ahr9n/ai-cli/pkg/session"
+	"github.com/ahr9n/ai-cli/pkg/tokens"
+)
+
+// slashAction tells the interactive loop what to do after a slash command.
+type slashAction int
+
+const (
+	// slashNone waits for the next input.
+	slashNone slashAction = iota
+	// slashRespond asks the model to answer the conversation as it now is.
+	slashRespond
+	// slashExit ends the session.
+	slashExit
+)
+
+// slashCommand is an interactive command such as /model.
+type slashCommand struct {
+	Name string
+	// Args describes the arguments for /help, e.g. "[name]".
+	Args string
+	Help string
+	Run  func(s *chatState, args string) (slashAction, error)
+}
+
+// slashRegistry holds the slash commands available in interactive mode.
+type slashRegistry struct {
+	commands map[string]*slashCommand
+}
+
+func newSlashRegistry(commands ...*slashCommand) *slashRegistry {
+	r := &slashRegistry{commands: make(map[string]*slashCommand)}
+	for _, c := range commands {
+		r.Register(c)
+	}
+	return r
+}
+
+// Register adds a command, replacing any command of the same name.
+func (r *slashRegistry) Register(c *slashCommand) {
+	r.commands[c.Name] = c
+}
+
+// Lookup finds a command by name, with or without the leading slash.
+func (r *slashRegistry) Lookup(name string) (*slashCommand, bool) {
+	c, ok := r.commands[strings.TrimPrefix(name, "/")]
+	return c, ok
+}
+
+// Commands returns all commands sorted by name.
+func (r *slashRegistry) Commands() []*slashCommand {
+	commands := make([]*slashCommand, 0, len(r.commands))
+	for _, c := range r.commands {
+		commands = append(commands, c)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// isSlashCommand reports whether an input line is a command. A line starting
+// with "//" is a message that starts with "/".
+func isSlashCommand(line string) bool {
+	return strings.HasPrefix(line, "/") && !strings.HasPrefix(line, "//")
+}
+
+// Dispatch runs the command on an input line such as "/temp 0.2".
+func (r *slashRegistry) Dispatch(s *chatState, line string) (slashAction, error) {
+	name, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+	c, ok := r.Lookup(name)
+	if !ok {
+		return slashNone, fmt.Errorf("unknown command %s (type /help for a list)", name)
+	}
+	return c.Run(s, strings.TrimSpace(args))
+}
+
+// defaultSlashCommands returns the registry used by interactive mode.
+func defaultSlashCommands() *slashRegistry {
+	r := newSlashRegistry(
+		&slashCommand{Name: "exit", Help: "End the session", Run: slashExitCommand},
+		&slashCommand{Name: "quit", Help: "End the session", Run: slashExitCommand},
+		&slashCommand{Name: "clear", Help: "Forget the conversation, keeping the system prompt", Run: slashClear},
+		&slashCommand{Name: "model", Args: "[name]", Help: "Show or switch the model", Run: slashModel},
+		&slashCommand{Name: "temp", Args: "[value]", Help: "Show or set the sampling temperature (0.0-2.0)", Run: slashTemp},
+		&slashCommand{Name: "system", Args: "[prompt]", Help: "Show or replace the system prompt", Run: slashSystem},
+		&slashCommand{Name: "preset", Args: "[name]", Help: "Use a preset system prompt (creative, concise, code, default)", Run: slashPreset},
+		&slashCommand{Name: "edit", Args: "[text]", Help: "Write the next message in $EDITOR, starting from text", Run: slashEdit},
+		&slashCommand{Name: "image", Args: "[file] [message]", Help: "Attach an image to the next message, or send it with message; without a file, list attached images", Run: slashImage},
+		&slashCommand{Name: "retry", Help: "Regenerate the last answer", Run: slashRetry},
+		&slashCommand{Name: "undo", Help: "Drop the last question and its answer", Run: slashUndo},
+		&slashCommand{Name: "history", Help: "Show the conversation so far", Run: slashHistory},
+		&slashCommand{Name: "save", Args: "[title]", Help: "Save the session now, optionally renaming it", Run: slashSave},
+		&slashCommand{Name: "load", Args: "<id|last>", Help: "Switch to a saved session", Run: slashLoad},
+		&slashCommand{Name: "export", Args: "<file>", Help: "Write the conversation to a Markdown or .json file", Run: slashExport},
+		&slashCommand{Name: "tokens", Help: "Estimate the size of the conversation against the context window", Run: slashTokens},
+	)
+	r.Register(&slashCommand{
+		Name: "help",
+		Args: "[command]",
+		Help: "List commands or describe one",
+		Run: func(s *chatState, args string) (slashAction, error) {
+			return slashHelp(r, s, args)
+		},
+	})
+	return r
+}
+
+func slashHelp(r *slashRegistry, s *chatState, args string) (slashAction, error) {
+	if args != "" {
+		c, ok := r.Lookup(args)
+		if !ok {
+			return slashNone, fmt.Errorf("unknown command /%s", strings.TrimPrefix(args, "/"))
+		}
+		fmt.Fprintf(s.out, "/%s %s\n  %s\n", c.Name, c.Args, c.Help)
+		return slashNone, nil
+	}
+
+	fmt.Fprintln(s.out, "Commands:")
+	for _, c := range r.Commands() {
+		fmt.Fprintf(s.out, "  %-18s %s\n", strings.TrimSpace("/"+c.Name+" "+c.Args), c.Help)
+	}
+	fmt.Fprintln(s.out, "Start a message with // to send text beginning with /.")
+	return slashNone, nil
+}
+
+func slashExitCommand(s *chatState, args string) (slashAction, error) {
+	return slashExit, nil
+}
+
+func slashClear(s *chatState, args string) (slashAction, error) {
+	s.clear()
+	fmt.Fprintln(s.out, "Conversation history cleared")
+	return slashNone, nil
+}
+
+func slashModel(s *chatState, args string) (slashAction, error) {
+	if args == "" {
+		model := s.opts.Model
+		if model == "" {
+			model = "server default"
+		}
+		fmt.Fprintf(s.out, "Model: %s\n", model)
+		return slashNone, nil
+	}
+	s.opts.Model = args
+	fmt.Fprintf(s.out, "Switched to model %s\n", args)
+	return slashNone, nil
+}
+
+func slashTemp(s *chatState, args string) (slashAction, error) {
+	if args == "" {
+		fmt.Fprintf(s.out, "Temperature: %g\n", s.opts.Temperature)
+		return slashNone, nil
+	}
+	t, err := strconv.ParseFloat(args, 32)
+	if err != nil || t < 0 || t > 2 {
+		return slashNone, fmt.Errorf("temperature must be a number between 0.0 and 2.0")
+	}
+	s.opts.Temperature = float32(t)
+	fmt.Fprintf(s.out, "Temperature set to %g\n", s.opts.Temperature)
+	return slashNone, nil
+}
+
+func slashSystem(s *chatState, args string) (slashAction, error) {
+	if args == "" {
+		if s.opts.SystemPrompt == "" {
+			fmt.Fprintln(s.out, "No system prompt")
+		} else {
+			fmt.Fprintf(s.out, "System prompt: %s\n", s.opts.SystemPrompt)
+		}
+		return slashNone, nil
+	}
+	s.setSystemPrompt(args)
+	fmt.Fprintln(s.out, "System prompt updated")
+	return slashNone, nil
+}
+
+func slashPreset(s *chatState, args string) (slashAction, error) {
+	presets := map[string]func() string{
+		"creative": prompts.CreativeSystem,
+		"concise":  prompts.ConciseSystem,
+		"code":     prompts.CodeSystem,
+		"default":  prompts.DefaultSystem,
+	}
+	if args == "" {
+		current := s.opts.PresetPrompt
+		if current == "" {
+			current = "none"
+		}
+		fmt.Fprintf(s.out, "Preset: %s (available: creative, concise, code, default)\n", current)
+		return slashNone, nil
+	}
+
+	preset, ok := presets[args]
+	if !ok {
+		return slashNone, fmt.Errorf("unknown preset %q (available: creative, concise, code, default)", args)
+	}
+	s.opts.PresetPrompt = args
+	s.setSystemPrompt(preset())
+	fmt.Fprintf(s.out, "Using the %s preset\n", args)
+	return slashNone, nil
+}
+
+func slashEdit(s *chatState, args string) (slashAction, error) {
+	message, err := composeInEditor(args)
+	if err != nil {
+		return slashNone, err
+	}
+	if message == "" {
+		fmt.Fprintln(s.out, "Nothing to send")
+		return slashNone, nil
+	}
+	fmt.Fprintln(s.out, message)
+	if err := s.addUserMessage(message); err != nil {
+		return slashNone, err
+	}
+	return slashRespond, nil
+}
+
+func slashImage(s *chatState, args string) (slashAction, error) {
+	if args == "" {
+		if len(s.images) == 0 {
+			fmt.Fprintln(s.out, "No images attached")
+		} else {
+			fmt.Fprintf(s.out, "Attached to the next message: %s\n", describeImages(s.images))
+		}
+		return slashNone, nil
+	}
+	if reg, ok := provider.Lookup(provider.ProviderType(s.opts.Provider)); ok && !reg.Capabilities.Images {
+		return slashNone, fmt.Errorf("%s does not accept images", reg.Name)
+	}
+
+	path, message, _ := strings.Cut(args, " ")
+	if path == stdinArg {
+		return slashNone, fmt.Errorf("stdin holds the conversation; give the path of the image")
+	}
+	image, err := loadImage(path)
+	if err != nil {
+		return slashNone, err
+	}
+	s.images = append(s.images, image)
+	message = strings.TrimSpace(message)
+	if message == "" {
+		fmt.Fprintf(s.out, "Attached %s %s to the next message\n", path, describeImages([]provider.Image{image}))
+		return slashNone, nil
+	}
+	if err := s.addUserMessage(message); err != nil {
+		return slashNone, err
+	}
+	return slashRespond, nil
+}
+
+func slashRetry(s *chatState, args string) (slashAction, error) {
+	user := s.lastIndex(prompts.RoleUser)
+	if user < 0 {
+		return slashNone, fmt.Errorf("nothing to retry yet")
+	}
+	// Drop the answer, if any, and ask again.
+	s.messages = s.messages[:user+1]
+	return slashRespond, nil
+}
+
+func slashUndo(s *chatState, args string) (slashAction, error) {
+	user := s.lastIndex(prompts.RoleUser)
+	if user < 0 {
+		return slashNone, fmt.Errorf("nothing to undo")
+	}
+	s.messages = s.messages[:user]
+	fmt.Fprintln(s.out, "Removed the last exchange")
+	return slashNone, nil
+}
+
+func slashHistory(s *chatState, args string) (slashAction, error) {
+	if s.lastIndex(prompts.RoleUser) < 0 {
+		fmt.Fprintln(s.out, "No messages yet")
+		return slashNone, nil
+	}
+	printTranscriptTo(s.out, s.messages)
+	return slashNone, nil
+}
+
+func slashSave(s *chatState, args string) (slashAction, error) {
+	if args != "" {
+		s.session.Title = args
+	}
+	if err := s.save(); err != nil {
+		return slashNone, err
+	}
+	fmt.Fprintf(s.out, "Saved session %s: %s\n", s.session.ID, s.session.Title)
+	return slashNone, nil
+}
+
+func slashLoad(s *chatState, args string) (slashAction, error) {
+	if args == "" {
+		return slashNone, fmt.Errorf("usage: /load <id|last>")
+	}
+	if s.store == nil {
+		return slashNone, fmt.Errorf("sessions cannot be loaded")
+	}
+	sess, err := s.store.Load(args)
+	if err != nil {
+		return slashNone, err
+	}
+	if sess.Provider != s.opts.Provider {
+		return slashNone, fmt.Errorf("session %s was created with %s; resume it with: ai-cli %s -i --resume %s",
+			sess.ID, sess.Provider, sess.Provider, sess.ID)
+	}
+
+	s.session = sess
+	s.opts.Model = sess.Model
+	s.opts.SystemPrompt = sess.SystemPrompt
+	s.messages = withSystemPrompt(sess.Messages, sess.SystemPrompt)
+	s.lastUsage = nil
+	fmt.Fprintf(s.out, "Loaded session %s: %s\n", sess.ID, sess.Title)
+	printTranscriptTo(s.out, s.messages)
+	return slashNone, nil
+}
+
+func slashExport(s *chatState, args string) (slashAction, error) {
+	if args == "" {
+		return slashNone, fmt.Errorf("usage: /export <file.md|file.json>")
+	}
+
+	var data []byte
+	if strings.EqualFold(filepath.Ext(args), ".json") {
+		snapshot := *s.session
+		snapshot.Messages = s.messages
+		snapshot.Model = s.opts.Model
+		snapshot.SystemPrompt = s.opts.SystemPrompt
+		if snapshot.Title == "" {
+			snapshot.Title = snapshot.DefaultTitle()
+		}
+		encoded, err := json.MarshalIndent(&snapshot, "", "  ")
+		if err != nil {
+			return slashNone, err
+		}
+		data = append(encoded, '\n')
+	} else {
+		data = []byte(markdownTranscript(s))
+	}
+
+	if err := os.WriteFile(args, data, 0644); err != nil {
+		return slashNone, err
+	}
+	fmt.Fprintf(s.out, "Exported %d messages to %s\n", len(s.messages), args)
+	return slashNone, nil
+}
+
+// markdownTranscript renders the conversation as a Markdown document.
+func markdownTranscript(s *chatState) string {
+	var b strings.Builder
+	title := s.session.Title
+	if title == "" {
+		snapshot := session.Session{Messages: s.messages}
+		title = snapshot.DefaultTitle()
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "- Provider: %s\n", s.opts.Provider)
+	if s.opts.Model != "" {
+		fmt.Fprintf(&b, "- Model: %s\n", s.opts.Model)
+	}
+
+	for _, msg := range s.messages {
+		switch msg.Role {
+		case prompts.RoleSystem:
+			if msg.Summary {
+				fmt.Fprintf(&b, "\n## Summary of earlier messages\n\n%s\n", strings.TrimPrefix(msg.Content, summaryPrefix))
+			} else {
+				fmt.Fprintf(&b, "\n## System\n\n%s\n", msg.Content)
+			}
+		case prompts.RoleUser:
+			fmt.Fprintf(&b, "\n## You\n\n%s\n", msg.Content)
+			if len(msg.Images) > 0 {
+				fmt.Fprintf(&b, "\n%s\n", describeImages(msg.Images))
+			}
+		case prompts.RoleAssistant:
+			heading := "Assistant"
+			if msg.Interrupted {
+				heading += " (interrupted)"
+			}
+			fmt.Fprintf(&b, "\n## %s\n\n%s\n", heading, msg.Content)
+		}
+	}
+	return b.String()
+}
+
+func slashTokens(s *chatState, args string) (slashAction, error) {
+	tokenizer := s.tokenizer()
+	used := tokens.CountMessages(tokenizer, s.messages)
+	fmt.Fprintf(s.out, "About %d tokens in %d messages (%s estimate)\n", used, len(s.messages), tokenizer.Name())
+
+	length, source := s.contextLength(context.Background())
+	if length > 0 {
+		budget := tokens.Budget(length)
+		fmt.Fprintf(s.out, "Context window: %d tokens (%s), %d for the conversation, %d%% used\n",
+			length, source, budget, used*100/budget)
+	} else {
+		fmt.Fprintln(s.out, "Context window: unknown (set it with --context-length or the context_length setting)")
+	}
+	if u := s.lastUsage; u != nil {
+		fmt.Fprintf(s.out, "Last answer: %d prompt + %d completion tokens (reported by the provider)\n",
+			u.PromptTokens, u.CompletionTokens)
+	}
+	return slashNone, nil
+}
@@ -0,0 +1,291 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/ahr9n/ai-cli/pkg/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider answers every question with the number of the answer and
+// the question, so that a repeated question gets a different answer.
+type fakeProvider struct {
+	answers int
+}
+
+func (f *fakeProvider) CreateCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions) (*provider.Completion, error) {
+	var collector provider.Collector
+	err := f.StreamCompletion(ctx, messages, opts, collector.Handle)
+	return collector.Completion(), err
+}
+
+func (f *fakeProvider) StreamCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions, onEvent provider.StreamHandler) error {
+	f.answers++
+	question := messages[len(messages)-1].Content
+	onEvent(provider.StreamEvent{Type: provider.EventContent, Content: question + " #"})
+	onEvent(provider.StreamEvent{Type: provider.EventContent, Content: string(rune('0' + f.answers))})
+	onEvent(provider.StreamEvent{Type: provider.EventFinish, FinishReason: provider.FinishStop})
+	return nil
+}
+
+func (f *fakeProvider) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	return []provider.ModelInfo{{Name: "fake"}}, nil
+}
+
+func (*fakeProvider) GetDefaultModel() string { return "fake" }
+func (*fakeProvider) Name() string            { return "Fake" }
+func (*fakeProvider) Description() string     { return "A fake provider" }
+
+// newTestChat starts a conversation without a session store that prints
+// to the returned buffer.
+func newTestChat(t *testing.T) (*chatState, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	opts := &ChatOptions{
+		Provider:     "fake",
+		Model:        "m",
+		SystemPrompt: "Be brief.",
+		Temperature:  0.7,
+		Quiet:        true,
+		NoColor:      true,
+	}
+	return newChatState(opts, nil, &out, nil), &out
+}
+
+// ask adds question to the conversation and lets p answer it.
+func ask(t *testing.T, s *chatState, p provider.Provider, question string) {
+	t.Helper()
+	require.NoError(t, s.addUserMessage(question))
+	require.NoError(t, s.respond(context.Background(), p))
+}
+
+// contents returns the role and content of each message.
+func contents(messages []provider.Message) []string {
+	var out []string
+	for _, msg := range messages {
+		out = append(out, msg.Role+": "+msg.Content)
+	}
+	return out
+}
+
+func TestSlashDispatch(t *testing.T) {
+	commands := defaultSlashCommands()
+	s, out := newTestChat(t)
+
+	_, err := commands.Dispatch(s, "/nope")
+	assert.EqualError(t, err, "unknown command /nope (type /help for a list)")
+
+	action, err := commands.Dispatch(s, "/help")
+	require.NoError(t, err)
+	assert.Equal(t, slashNone, action)
+	assert.Contains(t, out.String(), "Commands:\n")
+	for _, c := range commands.Commands() {
+		assert.Contains(t, out.String(), "/"+c.Name)
+	}
+
+	out.Reset()
+	_, err = commands.Dispatch(s, "/help temp")
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "/temp ")
+	assert.NotContains(t, out.String(), "Commands:")
+
+	_, err = commands.Dispatch(s, "/help nope")
+	assert.EqualError(t, err, "unknown command /nope")
+
+	action, err = commands.Dispatch(s, "/exit")
+	require.NoError(t, err)
+	assert.Equal(t, slashExit, action)
+}
+
+func TestSlashRetry(t *testing.T) {
+	interrupts := newInterrupter()
+	defer interrupts.Stop()
+	commands := defaultSlashCommands()
+	s, _ := newTestChat(t)
+	s.interrupts = interrupts
+	p := &fakeProvider{}
+
+	_, err := commands.Dispatch(s, "/retry")
+	assert.EqualError(t, err, "nothing to retry yet")
+
+	ask(t, s, p, "Hi")
+	action, err := commands.Dispatch(s, "/retry")
+	require.NoError(t, err)
+	assert.Equal(t, slashRespond, action)
+	assert.Equal(t, []string{"system: Be brief.", "user: Hi"}, contents(s.messages))
+
+	require.NoError(t, s.respond(context.Background(), p))
+	assert.Equal(t, []string{"system: Be brief.", "user: Hi", "assistant: Hi #2"}, contents(s.messages))
+}
+
+func TestSlashUndo(t *testing.T) {
+	interrupts := newInterrupter()
+	defer interrupts.Stop()
+	commands := defaultSlashCommands()
+	s, out := newTestChat(t)
+	s.interrupts = interrupts
+	p := &fakeProvider{}
+
+	_, err := commands.Dispatch(s, "/undo")
+	assert.EqualError(t, err, "nothing to undo")
+
+	ask(t, s, p, "One")
+	ask(t, s, p, "Two")
+	out.Reset()
+	_, err = commands.Dispatch(s, "/undo")
+	require.NoError(t, err)
+	assert.Equal(t, "Removed the last exchange\n", out.String())
+	assert.Equal(t, []string{"system: Be brief.", "user: One", "assistant: One #1"}, contents(s.messages))
+
+	_, err = commands.Dispatch(s, "/undo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"system: Be brief."}, contents(s.messages))
+}
+
+func TestSlashModel(t *testing.T) {
+	commands := defaultSlashCommands()
+	s, out := newTestChat(t)
+
+	_, err := commands.Dispatch(s, "/model")
+	require.NoError(t, err)
+	assert.Equal(t, "Model: m\n", out.String())
+
+	out.Reset()
+	_, err = commands.Dispatch(s, "/model llama3")
+	require.NoError(t, err)
+	assert.Equal(t, "Switched to model llama3\n", out.String())
+	assert.Equal(t, "llama3", s.opts.Model)
+
+	out.Reset()
+	s.opts.Model = ""
+	_, err = commands.Dispatch(s, "/model")
+	require.NoError(t, err)
+	assert.Equal(t, "Model: server default\n", out.String())
+}
+
+func TestSlashTemp(t *testing.T) {
+	tests := []struct {
+		args    string
+		want    float32
+		wantErr bool
+	}{
+		{args: "0", want: 0},
+		{args: "1.5", want: 1.5},
+		{args: "2", want: 2},
+		{args: "2.1", wantErr: true},
+		{args: "-0.1", wantErr: true},
+		{args: "hot", wantErr: true},
+	}
+
+	commands := defaultSlashCommands()
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			s, out := newTestChat(t)
+			_, err := commands.Dispatch(s, "/temp "+tt.args)
+			if tt.wantErr {
+				assert.EqualError(t, err, "temperature must be a number between 0.0 and 2.0")
+				assert.Equal(t, float32(0.7), s.opts.Temperature, "the temperature is kept")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s.opts.Temperature)
+			assert.Contains(t, out.String(), "Temperature set to ")
+		})
+	}
+
+	s, out := newTestChat(t)
+	_, err := commands.Dispatch(s, "/temp")
+	require.NoError(t, err)
+	assert.Equal(t, "Temperature: 0.7\n", out.String())
+}
+
+func TestSlashSystem(t *testing.T) {
+	commands := defaultSlashCommands()
+	s, out := newTestChat(t)
+	require.NoError(t, s.addUserMessage("Hi"))
+
+	_, err := commands.Dispatch(s, "/system")
+	require.NoError(t, err)
+	assert.Equal(t, "System prompt: Be brief.\n", out.String())
+
+	out.Reset()
+	_, err = commands.Dispatch(s, "/system Answer in French.")
+	require.NoError(t, err)
+	assert.Equal(t, "System prompt updated\n", out.String())
+	assert.Equal(t, "Answer in French.", s.opts.SystemPrompt)
+	assert.Equal(t, []string{"system: Answer in French.", "user: Hi"}, contents(s.messages))
+
+	out.Reset()
+	s.setSystemPrompt("")
+	_, err = commands.Dispatch(s, "/system")
+	require.NoError(t, err)
+	assert.Equal(t, "No system prompt\n", out.String())
+	assert.Equal(t, []string{"user: Hi"}, contents(s.messages))
+}
+
+func TestSlashPreset(t *testing.T) {
+	commands := defaultSlashCommands()
+	s, out := newTestChat(t)
+
+	_, err := commands.Dispatch(s, "/preset")
+	require.NoError(t, err)
+	assert.Equal(t, "Preset: none (available: creative, concise, code, default)\n", out.String())
+
+	out.Reset()
+	_, err = commands.Dispatch(s, "/preset code")
+	require.NoError(t, err)
+	assert.Equal(t, "Using the code preset\n", out.String())
+	assert.Equal(t, "code", s.opts.PresetPrompt)
+	assert.Equal(t, prompts.CodeSystem(), s.opts.SystemPrompt)
+	assert.Equal(t, []string{"system: " + prompts.CodeSystem()}, contents(s.messages))
+
+	_, err = commands.Dispatch(s, "/preset poetic")
+	assert.EqualError(t, err, `unknown preset "poetic" (available: creative, concise, code, default)`)
+	assert.Equal(t, "code", s.opts.PresetPrompt)
+}
+
+func TestSlashExport(t *testing.T) {
+	interrupts := newInterrupter()
+	defer interrupts.Stop()
+	commands := defaultSlashCommands()
+	s, out := newTestChat(t)
+	s.interrupts = interrupts
+	ask(t, s, &fakeProvider{}, "Hi")
+	out.Reset()
+	dir := t.TempDir()
+
+	_, err := commands.Dispatch(s, "/export")
+	assert.EqualError(t, err, "usage: /export <file.md|file.json>")
+
+	md := filepath.Join(dir, "chat.md")
+	_, err = commands.Dispatch(s, "/export "+md)
+	require.NoError(t, err)
+	assert.Equal(t, "Exported 3 messages to "+md+"\n", out.String())
+	data, err := os.ReadFile(md)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "- Provider: fake\n- Model: m\n")
+	assert.Contains(t, string(data), "\n## System\n\nBe brief.\n")
+	assert.Contains(t, string(data), "\n## You\n\nHi\n")
+	assert.Contains(t, string(data), "\nHi #1\n")
+
+	js := filepath.Join(dir, "chat.json")
+	_, err = commands.Dispatch(s, "/export "+js)
+	require.NoError(t, err)
+	data, err = os.ReadFile(js)
+	require.NoError(t, err)
+	var exported session.Session
+	require.NoError(t, json.Unmarshal(data, &exported))
+	assert.Equal(t, "fake", exported.Provider)
+	assert.Equal(t, "m", exported.Model)
+	assert.Equal(t, "Be brief.", exported.SystemPrompt)
+	assert.NotEmpty(t, exported.Title)
+	assert.Equal(t, contents(s.messages), contents(exported.Messages))
+}
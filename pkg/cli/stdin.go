@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ahr9n/ai-cli/pkg/utils"
+)
+
+// How piped stdin is framed in the conversation, chosen with --stdin-role.
+const (
+	// StdinContext puts the input in a fenced block after the prompt.
+	StdinContext = "context"
+	// StdinUser sends the input as it is, following the prompt.
+	StdinUser = "user"
+	// StdinSystem appends the input to the system prompt.
+	StdinSystem = "system"
+)
+
+// stdinArg is the prompt argument that asks for stdin explicitly, even when
+// it is a terminal.
+const stdinArg = "-"
+
+// defaultStdinLimit is the largest piped input accepted unless --stdin-limit
+// says otherwise.
+const defaultStdinLimit = 1 << 20
+
+// promptFromArgs builds the prompt of a single-prompt run. Input piped to
+// stdin, or typed after a "-" argument, is combined with the prompt words
+// according to opts.StdinRole; without prompt words it is the whole prompt.
+// With --editor the prompt words are first edited in $EDITOR. Files given
+// with --file or referenced as @path are attached to the prompt words, and
+// with --rag the excerpts of the index that match them are added last.
+func promptFromArgs(ctx context.Context, opts *ChatOptions, args []string) (string, error) {
+	switch opts.StdinRole {
+	case StdinContext, StdinUser, StdinSystem:
+	default:
+		return "", fmt.Errorf("invalid --stdin-role %q (valid: %s, %s, %s)", opts.StdinRole, StdinContext, StdinUser, StdinSystem)
+	}
+
+	var words []string
+	explicit := false
+	for _, arg := range args {
+		if arg == stdinArg {
+			explicit = true
+			continue
+		}
+		words = append(words, arg)
+	}
+	// An image read from stdin leaves nothing there for the prompt.
+	stdinTaken := imageFromStdin(opts)
+	if explicit && stdinTaken {
+		return "", fmt.Errorf("stdin cannot hold both the prompt and an image")
+	}
+	prompt := strings.Join(words, " ")
+
+	if opts.Editor {
+		edited, err := composeInEditor(prompt)
+		if err != nil {
+			return "", err
+		}
+		if edited == "" && !explicit && (stdinTaken || utils.IsTerminal(os.Stdin)) {
+			return "", fmt.Errorf("empty prompt, nothing sent")
+		}
+		prompt = edited
+	}
+
+	// The prompt words are the question for --rag, unless there are none
+	// and stdin holds it.
+	question := prompt
+	prompt, err := attachFiles(prompt, opts.Files, opts, os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	if !explicit && (stdinTaken || utils.IsTerminal(os.Stdin)) {
+		if prompt == "" {
+			return "", fmt.Errorf("please provide a prompt or use -i for interactive mode")
+		}
+		return addRetrievedContext(ctx, prompt, question, opts, os.Stderr)
+	}
+
+	input, err := readStdin(ctx, opts.StdinLimit)
+	if err != nil {
+		return "", err
+	}
+	input = strings.TrimRight(input, "\n")
+
+	switch {
+	case strings.TrimSpace(input) == "":
+		if prompt == "" {
+			return "", fmt.Errorf("please provide a prompt or use -i for interactive mode")
+		}
+	case prompt == "":
+		prompt, question = input, input
+	case opts.StdinRole == StdinContext:
+		prompt += "\n\n" + fence("", input)
+	case opts.StdinRole == StdinUser:
+		prompt += "\n\n" + input
+	default:
+		opts.SystemPrompt = strings.TrimSpace(opts.SystemPrompt + "\n\n" + input)
+	}
+	return addRetrievedContext(ctx, prompt, question, opts, os.Stderr)
+}
+
+// readStdin reads all of stdin, refusing input larger than limit bytes
+// (0 = unlimited) or input that is not text. It gives up when ctx is
+// cancelled, so that Ctrl+C works while waiting on a slow pipe.
+func readStdin(ctx context.Context, limit int64) (string, error) {
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r io.Reader = os.Stdin
+		if limit > 0 {
+			r = io.LimitReader(r, limit+1)
+		}
+		data, err := io.ReadAll(r)
+		done <- result{data, err}
+	}()
+
+	var res result
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res = <-done:
+	}
+
+	if res.err != nil {
+		return "", fmt.Errorf("failed to read stdin: %w", res.err)
+	}
+	if limit > 0 && int64(len(res.data)) > limit {
+		return "", fmt.Errorf("stdin is larger than %d bytes; raise the limit with --stdin-limit", limit)
+	}
+	if !utf8.Valid(res.data) {
+		return "", fmt.Errorf("stdin does not look like text")
+	}
+	return string(res.data), nil
+}
+
+// fence wraps s in a Markdown code fence longer than any backtick run it
+// contains, with info, such as a language, after the opening fence.
+func fence(info, s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	marker := strings.Repeat("`", max(3, longest+1))
+	return marker + info + "\n" + strings.TrimSuffix(s, "\n") + "\n" + marker
+}
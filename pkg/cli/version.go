@@ -14,8 +14,17 @@ func versionCommand() *cobra.Command {
 	return &cobra.Command{
 		Use:   "version",
 		Short: "Print version information",
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			if format != OutputText {
+				return writeOutput(format, map[string]string{"version": Version})
+			}
+
 			fmt.Printf("ai-cli version %s\n", Version)
+			return nil
 		},
 	}
 }
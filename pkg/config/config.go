@@ -0,0 +1,211 @@
+// Package config stores ai-cli's settings in a YAML file with named profiles.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile is the profile used when none has been selected.
+const DefaultProfile = "default"
+
+// Config is the content of the configuration file.
+type Config struct {
+	CurrentProfile string              `yaml:"current_profile,omitempty"`
+	Profiles       map[string]*Profile `yaml:"profiles,omitempty"`
+	// Plugins maps provider names to plugin executables, for plugins that
+	// are not on PATH.
+	Plugins map[string]string `yaml:"plugins,omitempty"`
+}
+
+// Profile is a named set of defaults for the chat commands. Empty fields
+// leave the built-in defaults in place.
+type Profile struct {
+	Provider     string            `yaml:"provider,omitempty" json:"provider,omitempty"`
+	URL          string            `yaml:"url,omitempty" json:"url,omitempty"`
+	Model        string            `yaml:"model,omitempty" json:"model,omitempty"`
+	Temperature  *float32          `yaml:"temperature,omitempty" json:"temperature,omitempty"`
+	SystemPrompt string            `yaml:"system_prompt,omitempty" json:"system_prompt,omitempty"`
+	Preset       string            `yaml:"preset,omitempty" json:"preset,omitempty"`
+	MaxHistory   *int              `yaml:"max_history,omitempty" json:"max_history,omitempty"`
+	Timeout      string            `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Reasoning    string            `yaml:"reasoning,omitempty" json:"reasoning,omitempty"`
+	APIKey       string            `yaml:"api_key,omitempty" json:"api_key,omitempty"`
+	BasePath     string            `yaml:"base_path,omitempty" json:"base_path,omitempty"`
+	Headers      map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// ContextLength overrides the context window the provider reports.
+	ContextLength *int `yaml:"context_length,omitempty" json:"context_length,omitempty"`
+	// Compaction is what happens to history that outgrows the context.
+	Compaction string `yaml:"compaction,omitempty" json:"compaction,omitempty"`
+	// EmbeddingModel is the model of the embed command.
+	EmbeddingModel string `yaml:"embedding_model,omitempty" json:"embedding_model,omitempty"`
+}
+
+// Dir returns the directory holding ai-cli's configuration, honoring
+// XDG_CONFIG_HOME.
+func Dir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "ai-cli"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "ai-cli"), nil
+}
+
+// Path returns the location of the configuration file. AI_CLI_CONFIG
+// overrides the default location.
+func Path() (string, error) {
+	if path := os.Getenv("AI_CLI_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// Load reads the configuration file. A missing file yields an empty
+// configuration.
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Save writes the configuration file, creating its directory if needed. The
+// file is private to the user because profiles may hold API keys.
+func (c *Config) Save() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// ActiveProfileName returns override if set, then the current profile, then
+// DefaultProfile.
+func (c *Config) ActiveProfileName(override string) string {
+	if override != "" {
+		return override
+	}
+	if c.CurrentProfile != "" {
+		return c.CurrentProfile
+	}
+	return DefaultProfile
+}
+
+// Profile returns the named profile, or nil if it does not exist.
+func (c *Config) Profile(name string) *Profile {
+	return c.Profiles[name]
+}
+
+// EnsureProfile returns the named profile, creating it if needed.
+func (c *Config) EnsureProfile(name string) *Profile {
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]*Profile)
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		p = &Profile{}
+		c.Profiles[name] = p
+	}
+	return p
+}
+
+// ProfileNames returns the names of all profiles in sorted order.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// legacyConfig is the default.json written by ai-cli before profiles existed.
+type legacyConfig struct {
+	Provider    string `json:"provider"`
+	ProviderURL string `json:"provider_url,omitempty"`
+}
+
+// legacyPath returns the default.json of older versions, which ignored
+// XDG_CONFIG_HOME.
+func legacyPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "ai-cli", "default.json"), nil
+}
+
+// MigrateLegacy turns the default.json of older versions into the default
+// profile of a new configuration file, keeping the old file as
+// default.json.bak. It does nothing once the configuration file exists. It
+// returns the path of the migrated file, or "" if there was none.
+func MigrateLegacy() (string, error) {
+	path, err := Path()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	legacy, err := legacyPath()
+	if err != nil {
+		return "", nil
+	}
+	data, err := os.ReadFile(legacy)
+	if err != nil {
+		return "", nil
+	}
+
+	var old legacyConfig
+	if err := json.Unmarshal(data, &old); err != nil {
+		return "", fmt.Errorf("failed to migrate %s: %w", legacy, err)
+	}
+
+	cfg := &Config{CurrentProfile: DefaultProfile}
+	profile := cfg.EnsureProfile(DefaultProfile)
+	profile.Provider = old.Provider
+	profile.URL = old.ProviderURL
+
+	if err := cfg.Save(); err != nil {
+		return "", fmt.Errorf("failed to migrate %s: %w", legacy, err)
+	}
+	if err := os.Rename(legacy, legacy+".bak"); err != nil {
+		return "", fmt.Errorf("migrated %s to %s but could not rename it: %w", legacy, path, err)
+	}
+	return legacy, nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateLegacy(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AI_CLI_CONFIG", "")
+	// Older versions ignored XDG_CONFIG_HOME.
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+
+	legacy := filepath.Join(home, ".config", "ai-cli", "default.json")
+	require.NoError(t, os.MkdirAll(filepath.Dir(legacy), 0755))
+	require.NoError(t, os.WriteFile(legacy, []byte(`{"provider":"localai","provider_url":"http://host:8080"}`), 0644))
+
+	// Loading does not migrate.
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Profiles)
+	assert.FileExists(t, legacy)
+
+	migrated, err := MigrateLegacy()
+	require.NoError(t, err)
+	assert.Equal(t, legacy, migrated)
+	assert.NoFileExists(t, legacy)
+	assert.FileExists(t, legacy+".bak")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultProfile, cfg.CurrentProfile)
+	assert.Equal(t, &Profile{Provider: "localai", URL: "http://host:8080"}, cfg.Profile(DefaultProfile))
+
+	// Once the configuration exists, a default.json is left alone.
+	require.NoError(t, os.WriteFile(legacy, []byte(`{"provider":"ollama"}`), 0644))
+	migrated, err = MigrateLegacy()
+	require.NoError(t, err)
+	assert.Empty(t, migrated)
+	assert.FileExists(t, legacy)
+}
+
+func TestMigrateLegacyWithoutLegacyFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AI_CLI_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	migrated, err := MigrateLegacy()
+	require.NoError(t, err)
+	assert.Empty(t, migrated)
+	assert.NoFileExists(t, filepath.Join(home, ".config", "ai-cli", "config.yaml"))
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keys lists the profile settings accepted by Get and Set. Headers are set
+// with keys of the form "headers.<Name>".
+var Keys = []string{
+	"provider",
+	"url",
+	"model",
+	"embedding_model",
+	"temperature",
+	"system_prompt",
+	"preset",
+	"max_history",
+	"context_length",
+	"compaction",
+	"timeout",
+	"reasoning",
+	"api_key",
+	"base_path",
+}
+
+// ProviderSpecific reports whether key only makes sense for one provider, so
+// that a layer naming a different provider must not supply it.
+func ProviderSpecific(key string) bool {
+	switch key {
+	case "url", "model", "embedding_model", "context_length", "api_key", "base_path", "headers":
+		return true
+	}
+	return strings.HasPrefix(key, headerKeyPrefix)
+}
+
+const headerKeyPrefix = "headers."
+
+// Get returns the value of key as a string, and whether it is set.
+func (p *Profile) Get(key string) (string, bool, error) {
+	if name, ok := strings.CutPrefix(key, headerKeyPrefix); ok {
+		value, set := p.Headers[name]
+		return value, set, nil
+	}
+
+	switch key {
+	case "provider":
+		return p.Provider, p.Provider != "", nil
+	case "url":
+		return p.URL, p.URL != "", nil
+	case "model":
+		return p.Model, p.Model != "", nil
+	case "embedding_model":
+		return p.EmbeddingModel, p.EmbeddingModel != "", nil
+	case "temperature":
+		if p.Temperature == nil {
+			return "", false, nil
+		}
+		return strconv.FormatFloat(float64(*p.Temperature), 'g', -1, 32), true, nil
+	case "system_prompt":
+		return p.SystemPrompt, p.SystemPrompt != "", nil
+	case "preset":
+		return p.Preset, p.Preset != "", nil
+	case "max_history":
+		if p.MaxHistory == nil {
+			return "", false, nil
+		}
+		return strconv.Itoa(*p.MaxHistory), true, nil
+	case "context_length":
+		if p.ContextLength == nil {
+			return "", false, nil
+		}
+		return strconv.Itoa(*p.ContextLength), true, nil
+	case "compaction":
+		return p.Compaction, p.Compaction != "", nil
+	case "timeout":
+		return p.Timeout, p.Timeout != "", nil
+	case "reasoning":
+		return p.Reasoning, p.Reasoning != "", nil
+	case "api_key":
+		return p.APIKey, p.APIKey != "", nil
+	case "base_path":
+		return p.BasePath, p.BasePath != "", nil
+	case "headers":
+		names := make([]string, 0, len(p.Headers))
+		for name := range p.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		lines := make([]string, len(names))
+		for i, name := range names {
+			lines[i] = name + ": " + p.Headers[name]
+		}
+		return strings.Join(lines, "\n"), len(lines) > 0, nil
+	default:
+		return "", false, unknownKeyError(key)
+	}
+}
+
+// Set parses value and stores it under key. An empty value removes the
+// setting.
+func (p *Profile) Set(key, value string) error {
+	if name, ok := strings.CutPrefix(key, headerKeyPrefix); ok {
+		if name == "" {
+			return fmt.Errorf("header name missing in %q", key)
+		}
+		if value == "" {
+			delete(p.Headers, name)
+			return nil
+		}
+		if p.Headers == nil {
+			p.Headers = make(map[string]string)
+		}
+		p.Headers[name] = value
+		return nil
+	}
+
+	switch key {
+	case "provider":
+		p.Provider = value
+	case "url":
+		p.URL = value
+	case "model":
+		p.Model = value
+	case "embedding_model":
+		p.EmbeddingModel = value
+	case "temperature":
+		if value == "" {
+			p.Temperature = nil
+			return nil
+		}
+		t, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return fmt.Errorf("invalid temperature %q: %w", value, err)
+		}
+		temperature := float32(t)
+		p.Temperature = &temperature
+	case "system_prompt":
+		p.SystemPrompt = value
+	case "preset":
+		p.Preset = value
+	case "max_history":
+		if value == "" {
+			p.MaxHistory = nil
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid max_history %q: %w", value, err)
+		}
+		p.MaxHistory = &n
+	case "context_length":
+		if value == "" {
+			p.ContextLength = nil
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid context_length %q: expected a number of tokens", value)
+		}
+		p.ContextLength = &n
+	case "compaction":
+		p.Compaction = value
+	case "timeout":
+		if value != "" {
+			if _, err := time.ParseDuration(value); err != nil {
+				return fmt.Errorf("invalid timeout %q: %w", value, err)
+			}
+		}
+		p.Timeout = value
+	case "reasoning":
+		p.Reasoning = value
+	case "api_key":
+		p.APIKey = value
+	case "base_path":
+		p.BasePath = value
+	default:
+		return unknownKeyError(key)
+	}
+	return nil
+}
+
+func unknownKeyError(key string) error {
+	return fmt.Errorf("unknown setting %q (valid: %s, headers.<Name>)", key, strings.Join(Keys, ", "))
+}
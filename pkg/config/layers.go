@@ -0,0 +1,244 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFile is the name of the per-project configuration file. It is
+// looked up in the working directory and its parents.
+const ProjectFile = ".ai-cli.yaml"
+
+// EnvPrefix starts the names of the environment variables that override
+// settings, as in AI_CLI_MODEL or AI_CLI_SYSTEM_PROMPT.
+const EnvPrefix = "AI_CLI_"
+
+// Layer is one source of settings. Layers are resolved in order, later ones
+// overriding earlier ones.
+type Layer struct {
+	// Source describes where the settings come from, e.g. "project
+	// /src/app/.ai-cli.yaml".
+	Source string
+	// Provider, when set, limits the provider-specific settings of the
+	// layer to that provider.
+	Provider string
+	Settings *Profile
+	// Vars names the environment variable behind each key, for layers read
+	// from the environment.
+	Vars map[string]string
+}
+
+func (l Layer) sourceOf(key string) string {
+	if name, ok := l.Vars[key]; ok {
+		return l.Source + " " + name
+	}
+	return l.Source
+}
+
+// Setting is an effective value and the layer it came from.
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Resolved holds the effective settings for one provider.
+type Resolved struct {
+	settings map[string]Setting
+	headers  map[string]Setting
+}
+
+// Resolve merges layers for the given provider type. Provider-specific
+// settings are skipped in layers meant for a different provider.
+func Resolve(layers []Layer, providerType string) *Resolved {
+	r := &Resolved{
+		settings: make(map[string]Setting),
+		headers:  make(map[string]Setting),
+	}
+
+	for _, layer := range layers {
+		if layer.Settings == nil {
+			continue
+		}
+		applies := layer.Provider == "" || providerType == "" || layer.Provider == providerType
+
+		for _, key := range Keys {
+			if ProviderSpecific(key) && !applies {
+				continue
+			}
+			if value, ok, _ := layer.Settings.Get(key); ok {
+				r.settings[key] = Setting{Key: key, Value: value, Source: layer.sourceOf(key)}
+			}
+		}
+		if !applies {
+			continue
+		}
+		for name, value := range layer.Settings.Headers {
+			r.headers[name] = Setting{Key: headerKeyPrefix + name, Value: value, Source: layer.sourceOf("headers")}
+		}
+	}
+	return r
+}
+
+// Get returns the effective value of key, if any layer set it.
+func (r *Resolved) Get(key string) (Setting, bool) {
+	s, ok := r.settings[key]
+	return s, ok
+}
+
+// Headers returns the effective extra request headers sorted by name.
+func (r *Resolved) Headers() []Setting {
+	headers := make([]Setting, 0, len(r.headers))
+	for _, h := range r.headers {
+		headers = append(headers, h)
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Key < headers[j].Key })
+	return headers
+}
+
+// Layers returns the configured layers in increasing precedence: the active
+// profile of the user config, the nearest project file, the given extra
+// layers and the AI_CLI_* environment variables. profile selects a profile
+// other than the current one; AI_CLI_PROFILE does the same when profile is
+// empty. Naming a profile that does not exist is an error.
+func Layers(profile string, extra ...Layer) ([]Layer, error) {
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if profile == "" {
+		profile = os.Getenv(EnvPrefix + "PROFILE")
+	}
+	name := cfg.ActiveProfileName(profile)
+	settings := cfg.Profile(name)
+	if settings == nil && profile != "" {
+		return nil, fmt.Errorf("profile %q does not exist", name)
+	}
+
+	var layers []Layer
+	if settings != nil {
+		layers = append(layers, Layer{
+			Source:   fmt.Sprintf("user config (profile %s)", name),
+			Provider: settings.Provider,
+			Settings: settings,
+		})
+	}
+
+	wd, err := os.Getwd()
+	if err == nil {
+		if path, ok := FindProjectFile(wd); ok {
+			project, err := LoadProjectFile(path)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, Layer{
+				Source:   "project " + path,
+				Provider: project.Provider,
+				Settings: project,
+			})
+		}
+	}
+
+	layers = append(layers, extra...)
+
+	env, err := EnvLayer()
+	if err != nil {
+		return nil, err
+	}
+	return append(layers, env), nil
+}
+
+// FindProjectFile looks for ProjectFile in dir and its parents.
+func FindProjectFile(dir string) (string, bool) {
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectFile reads a project file. It holds the same settings as a
+// profile, except for API keys: project files are usually committed, and a
+// key found there is rejected rather than sent to a server.
+func LoadProjectFile(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &Profile{}
+	if err := yaml.Unmarshal(data, project); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if project.APIKey != "" {
+		return nil, fmt.Errorf("%s: api_key is not allowed in project files, use the user config or %sAPI_KEY", path, EnvPrefix)
+	}
+	return project, nil
+}
+
+// EnvLayer reads the AI_CLI_* variables named after the settings, such as
+// AI_CLI_MODEL, and AI_CLI_HEADERS holding "Name: value" pairs separated by
+// semicolons.
+func EnvLayer() (Layer, error) {
+	layer := Layer{
+		Source:   "env",
+		Settings: &Profile{},
+		Vars:     make(map[string]string),
+	}
+
+	for _, key := range Keys {
+		name := EnvPrefix + strings.ToUpper(key)
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if err := layer.Settings.Set(key, value); err != nil {
+			return layer, fmt.Errorf("%s: %w", name, err)
+		}
+		layer.Vars[key] = name
+	}
+	layer.Provider = layer.Settings.Provider
+
+	name := EnvPrefix + "HEADERS"
+	if env := os.Getenv(name); env != "" {
+		for _, header := range strings.Split(env, ";") {
+			if strings.TrimSpace(header) == "" {
+				continue
+			}
+			key, value, err := ParseHeader(header)
+			if err != nil {
+				return layer, fmt.Errorf("%s: %w", name, err)
+			}
+			if err := layer.Settings.Set(headerKeyPrefix+key, value); err != nil {
+				return layer, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		layer.Vars["headers"] = name
+	}
+	return layer, nil
+}
+
+// ErrInvalidHeader is returned for headers not of the form "Name: value".
+var ErrInvalidHeader = errors.New("expected 'Name: value'")
+
+// ParseHeader splits a "Name: value" header.
+func ParseHeader(header string) (string, string, error) {
+	name, value, ok := strings.Cut(header, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return "", "", fmt.Errorf("invalid header %q, %w", header, ErrInvalidHeader)
+	}
+	return strings.TrimSpace(name), strings.TrimSpace(value), nil
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearEnv unsets every AI_CLI_* variable for the test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); strings.HasPrefix(name, EnvPrefix) {
+			t.Setenv(name, "")
+		}
+	}
+}
+
+func float32Ptr(f float32) *float32 { return &f }
+
+func TestResolve(t *testing.T) {
+	layers := []Layer{
+		{Source: "user config (profile work)", Provider: "ollama", Settings: &Profile{
+			Provider:    "ollama",
+			URL:         "http://gpu:11434",
+			Model:       "llama3",
+			Temperature: float32Ptr(0.2),
+			Headers:     map[string]string{"X-Team": "a", "X-Trace": "1"},
+		}},
+		{Source: "project /src/.ai-cli.yaml", Settings: &Profile{
+			Model:   "codellama",
+			Preset:  "code",
+			Headers: map[string]string{"X-Team": "b"},
+		}},
+		{Source: "env", Settings: &Profile{Model: "qwen"}, Vars: map[string]string{"model": "AI_CLI_MODEL"}},
+	}
+
+	r := Resolve(layers, "ollama")
+	get := func(key string) Setting {
+		s, ok := r.Get(key)
+		require.True(t, ok, key)
+		return s
+	}
+	assert.Equal(t, Setting{Key: "model", Value: "qwen", Source: "env AI_CLI_MODEL"}, get("model"))
+	assert.Equal(t, Setting{Key: "url", Value: "http://gpu:11434", Source: "user config (profile work)"}, get("url"))
+	assert.Equal(t, Setting{Key: "preset", Value: "code", Source: "project /src/.ai-cli.yaml"}, get("preset"))
+	assert.Equal(t, "0.2", get("temperature").Value)
+	_, ok := r.Get("system_prompt")
+	assert.False(t, ok)
+	assert.Equal(t, []Setting{
+		{Key: "headers.X-Team", Value: "b", Source: "project /src/.ai-cli.yaml"},
+		{Key: "headers.X-Trace", Value: "1", Source: "user config (profile work)"},
+	}, r.Headers())
+}
+
+func TestResolveOtherProvider(t *testing.T) {
+	layers := []Layer{
+		{Source: "user config (profile default)", Provider: "localai", Settings: &Profile{
+			Provider:       "localai",
+			URL:            "http://localai:8080",
+			Model:          "mistral",
+			EmbeddingModel: "bert",
+			APIKey:         "secret",
+			Temperature:    float32Ptr(0.5),
+			Headers:        map[string]string{"X-Team": "a"},
+		}},
+	}
+
+	r := Resolve(layers, "ollama")
+	for _, key := range []string{"url", "model", "embedding_model", "api_key"} {
+		_, ok := r.Get(key)
+		assert.False(t, ok, "%s of another provider is skipped", key)
+	}
+	assert.Empty(t, r.Headers())
+	temp, ok := r.Get("temperature")
+	require.True(t, ok, "settings for any provider apply")
+	assert.Equal(t, "0.5", temp.Value)
+
+	// Without a provider type, as for "config explain" of no command,
+	// everything applies.
+	r = Resolve(layers, "")
+	url, ok := r.Get("url")
+	require.True(t, ok)
+	assert.Equal(t, "http://localai:8080", url.Value)
+}
+
+func TestFindProjectFile(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b", "c")
+	require.NoError(t, os.MkdirAll(nested, 0755))
+
+	_, ok := FindProjectFile(nested)
+	assert.False(t, ok)
+
+	// A directory of that name is not a project file.
+	require.NoError(t, os.Mkdir(filepath.Join(root, "a", "b", ProjectFile), 0755))
+	_, ok = FindProjectFile(nested)
+	assert.False(t, ok)
+
+	project := filepath.Join(root, "a", ProjectFile)
+	require.NoError(t, os.WriteFile(project, []byte("model: llama3\n"), 0644))
+	path, ok := FindProjectFile(nested)
+	require.True(t, ok)
+	assert.Equal(t, project, path)
+
+	// The nearest file wins.
+	closer := filepath.Join(nested, ProjectFile)
+	require.NoError(t, os.WriteFile(closer, []byte("model: qwen\n"), 0644))
+	path, ok = FindProjectFile(nested)
+	require.True(t, ok)
+	assert.Equal(t, closer, path)
+}
+
+func TestLoadProjectFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ProjectFile)
+
+	require.NoError(t, os.WriteFile(path, []byte("provider: ollama\nmodel: llama3\nheaders:\n  X-Team: a\n"), 0644))
+	project, err := LoadProjectFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, &Profile{Provider: "ollama", Model: "llama3", Headers: map[string]string{"X-Team": "a"}}, project)
+
+	require.NoError(t, os.WriteFile(path, []byte("api_key: secret\n"), 0644))
+	_, err = LoadProjectFile(path)
+	assert.ErrorContains(t, err, "api_key is not allowed in project files")
+
+	require.NoError(t, os.WriteFile(path, []byte("model: [\n"), 0644))
+	_, err = LoadProjectFile(path)
+	assert.ErrorContains(t, err, "failed to parse")
+}
+
+func TestEnvLayer(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("AI_CLI_PROVIDER", "openai-compatible")
+	t.Setenv("AI_CLI_MODEL", "gpt-4o")
+	t.Setenv("AI_CLI_TEMPERATURE", "0.3")
+	t.Setenv("AI_CLI_MAX_HISTORY", "10")
+	t.Setenv("AI_CLI_HEADERS", "X-Team: a; ;Authorization: Bearer t:1")
+
+	layer, err := EnvLayer()
+	require.NoError(t, err)
+	assert.Equal(t, "env", layer.Source)
+	assert.Equal(t, "openai-compatible", layer.Provider)
+	assert.Equal(t, "gpt-4o", layer.Settings.Model)
+	assert.Equal(t, float32Ptr(0.3), layer.Settings.Temperature)
+	require.NotNil(t, layer.Settings.MaxHistory)
+	assert.Equal(t, 10, *layer.Settings.MaxHistory)
+	assert.Equal(t, map[string]string{"X-Team": "a", "Authorization": "Bearer t:1"}, layer.Settings.Headers)
+	assert.Equal(t, "env AI_CLI_MODEL", layer.sourceOf("model"))
+	assert.Equal(t, "env AI_CLI_HEADERS", layer.sourceOf("headers"))
+
+	t.Setenv("AI_CLI_TEMPERATURE", "warm")
+	_, err = EnvLayer()
+	assert.ErrorContains(t, err, "AI_CLI_TEMPERATURE: ")
+
+	t.Setenv("AI_CLI_TEMPERATURE", "")
+	t.Setenv("AI_CLI_HEADERS", "no colon")
+	_, err = EnvLayer()
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
+
+func TestLayers(t *testing.T) {
+	clearEnv(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("AI_CLI_CONFIG", "")
+
+	cfg := &Config{Profiles: map[string]*Profile{
+		DefaultProfile: {Provider: "ollama", Model: "llama3", Preset: "concise"},
+		"work":         {Provider: "ollama", Model: "mistral"},
+	}}
+	require.NoError(t, cfg.Save())
+
+	work := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(work, ProjectFile), []byte("model: codellama\npreset: code\n"), 0644))
+	sub := filepath.Join(work, "sub")
+	require.NoError(t, os.Mkdir(sub, 0755))
+	t.Chdir(sub)
+	t.Setenv("AI_CLI_MODEL", "qwen")
+
+	extra := Layer{Source: "env", Provider: "ollama", Settings: &Profile{URL: "http://host:11434"}}
+	layers, err := Layers("", extra)
+	require.NoError(t, err)
+	require.Len(t, layers, 4)
+	assert.Equal(t, "user config (profile default)", layers[0].Source)
+	assert.Equal(t, "project "+filepath.Join(work, ProjectFile), layers[1].Source)
+	assert.Equal(t, extra, layers[2])
+	assert.Equal(t, "env", layers[3].Source)
+
+	r := Resolve(layers, "ollama")
+	model, _ := r.Get("model")
+	assert.Equal(t, "qwen", model.Value, "the environment wins")
+	preset, _ := r.Get("preset")
+	assert.Equal(t, "code", preset.Value, "the project file wins over the profile")
+	url, _ := r.Get("url")
+	assert.Equal(t, "http://host:11434", url.Value)
+
+	layers, err = Layers("work")
+	require.NoError(t, err)
+	assert.Equal(t, "mistral", layers[0].Settings.Model)
+
+	t.Setenv("AI_CLI_PROFILE", "work")
+	layers, err = Layers("")
+	require.NoError(t, err)
+	assert.Equal(t, "user config (profile work)", layers[0].Source)
+
+	_, err = Layers("missing")
+	assert.EqualError(t, err, `profile "missing" does not exist`)
+}
@@ -0,0 +1,261 @@
+// Package files finds and reads the local files a prompt refers to. A
+// reference is a file, a directory or a glob that may use "**" to cross
+// directories. Directories and globs leave out hidden files and whatever
+// .gitignore files exclude, and are refused when they would walk the whole
+// filesystem or home directory; files that are not text or are too large
+// are skipped with a reason.
+package files
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Reasons for skipping a file.
+const (
+	ReasonBinary   = "not a text file"
+	ReasonTooLarge = "too large"
+	ReasonTotal    = "over the total size limit"
+)
+
+// ErrTooBroad is returned for a directory or glob that would walk the whole
+// filesystem or home directory.
+var ErrTooBroad = errors.New("too broad to read every file in; name the files or a narrower directory")
+
+// sniffLen is how much of a file is checked for NUL bytes, like git does.
+const sniffLen = 8000
+
+// Limits bound how much is read. Zero means no limit.
+type Limits struct {
+	// MaxFileSize is the largest file read, in bytes.
+	MaxFileSize int64
+	// MaxTotalSize is the most read over all files, in bytes.
+	MaxTotalSize int64
+}
+
+// File is a file that was read.
+type File struct {
+	Path    string
+	Content string
+}
+
+// Skipped is a file that was left out, and why.
+type Skipped struct {
+	Path   string
+	Reason string
+	Size   int64
+}
+
+// Expansion is the result of reading the files of some references.
+type Expansion struct {
+	Files   []File
+	Skipped []Skipped
+}
+
+// Size returns the number of bytes read.
+func (e *Expansion) Size() int64 {
+	var n int64
+	for _, f := range e.Files {
+		n += int64(len(f.Content))
+	}
+	return n
+}
+
+// Expand reads the files the references stand for, each at most once.
+func Expand(refs []string, limits Limits) (*Expansion, error) {
+	var paths []string
+	for _, ref := range refs {
+		matches, err := Match(ref)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	return Read(paths, limits), nil
+}
+
+// Match returns the files a reference stands for, in lexical order. A file
+// named explicitly is returned even when .gitignore excludes it.
+func Match(ref string) ([]string, error) {
+	ref = expandHome(ref)
+	if !hasMeta(ref) {
+		info, err := os.Stat(ref)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return []string{ref}, nil
+		}
+		if tooBroad(ref) {
+			return nil, &fs.PathError{Op: "read", Path: ref, Err: ErrTooBroad}
+		}
+		return walk(ref, nil, false)
+	}
+
+	pattern := filepath.ToSlash(filepath.Clean(ref))
+	re, err := globRegexp(pattern)
+	if err != nil {
+		return nil, &fs.PathError{Op: "glob", Path: ref, Err: err}
+	}
+	base := filepath.FromSlash(globBase(pattern))
+	if tooBroad(base) {
+		return nil, &fs.PathError{Op: "glob", Path: ref, Err: ErrTooBroad}
+	}
+	matches, err := walk(base, func(path string) bool {
+		return re.MatchString(filepath.ToSlash(path))
+	}, namesHidden(pattern))
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(matches) == 0) {
+		return nil, fmt.Errorf("no files match %s", ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// Walk returns the files under dir, leaving out hidden files and what
+// .gitignore files exclude.
+func Walk(dir string) ([]string, error) {
+	return walk(expandHome(dir), nil, false)
+}
+
+// walk lists the regular files under base that match accepts, or all of
+// them when it is nil.
+func walk(base string, accept func(path string) bool, hidden bool) ([]string, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return nil, err
+	}
+	ig := newIgnorer()
+	ig.loadParents(absBase)
+
+	var matches []string
+	err = filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == base {
+				return err
+			}
+			// Unreadable entries below the base are passed over.
+			return nil
+		}
+		rel, _ := filepath.Rel(base, path)
+		abs := filepath.Join(absBase, rel)
+		name := d.Name()
+
+		if d.IsDir() {
+			if path == base {
+				return nil
+			}
+			if name == ".git" || (!hidden && strings.HasPrefix(name, ".")) || ig.ignored(abs, true) {
+				return filepath.SkipDir
+			}
+			ig.load(abs)
+			return nil
+		}
+		if !d.Type().IsRegular() || (!hidden && strings.HasPrefix(name, ".")) || ig.ignored(abs, false) {
+			return nil
+		}
+		if accept == nil || accept(path) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// Read reads the files at paths in order, skipping repeats, files that are
+// not text and files beyond the limits.
+func Read(paths []string, limits Limits) *Expansion {
+	result := &Expansion{}
+	seen := make(map[string]bool)
+	var total int64
+	for _, path := range paths {
+		key := filepath.Clean(path)
+		if abs, err := filepath.Abs(path); err == nil {
+			key = abs
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		info, err := os.Stat(path)
+		if err != nil {
+			result.Skipped = append(result.Skipped, Skipped{Path: path, Reason: err.Error()})
+			continue
+		}
+		size := info.Size()
+		if limits.MaxFileSize > 0 && size > limits.MaxFileSize {
+			result.Skipped = append(result.Skipped, Skipped{Path: path, Reason: ReasonTooLarge, Size: size})
+			continue
+		}
+		if limits.MaxTotalSize > 0 && total+size > limits.MaxTotalSize {
+			result.Skipped = append(result.Skipped, Skipped{Path: path, Reason: ReasonTotal, Size: size})
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			result.Skipped = append(result.Skipped, Skipped{Path: path, Reason: err.Error()})
+			continue
+		}
+		if IsBinary(data) {
+			result.Skipped = append(result.Skipped, Skipped{Path: path, Reason: ReasonBinary, Size: size})
+			continue
+		}
+		total += int64(len(data))
+		result.Files = append(result.Files, File{Path: path, Content: string(data)})
+	}
+	return result
+}
+
+// IsBinary reports whether data does not look like text: it has a NUL byte
+// near the start or is not valid UTF-8.
+func IsBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), sniffLen)], 0) >= 0 || !utf8.Valid(data)
+}
+
+// namesHidden reports whether a glob asks for hidden files by naming an
+// element that starts with a dot.
+func namesHidden(pattern string) bool {
+	for _, part := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(part, ".") && part != "." && part != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// tooBroad reports whether dir is the root of the filesystem or the home
+// directory.
+func tooBroad(dir string) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	if abs == filepath.VolumeName(abs)+string(filepath.Separator) {
+		return true
+	}
+	home, err := os.UserHomeDir()
+	return err == nil && abs == filepath.Clean(home)
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,67 @@
+package files
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTooBroad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	for _, ref := range []string{"/", "/*", "/**/*.md", "~", "~/", "~/*.md", home} {
+		t.Run(ref, func(t *testing.T) {
+			_, err := Match(ref)
+			assert.ErrorIs(t, err, ErrTooBroad)
+			var pathErr *fs.PathError
+			assert.ErrorAs(t, err, &pathErr)
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	notes := filepath.Join(home, "notes")
+	for _, name := range []string{"a.md", "b.txt", "sub/c.md", ".hidden.md", "skipped.log"} {
+		path := filepath.Join(notes, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(name), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(notes, ".gitignore"), []byte("*.log\n"), 0644))
+
+	tests := []struct {
+		ref     string
+		want    []string
+		wantErr string
+	}{
+		{ref: "~/notes", want: []string{"a.md", "b.txt", "sub/c.md"}},
+		{ref: "~/notes/**/*.md", want: []string{"a.md", "sub/c.md"}},
+		{ref: "~/notes/*.md", want: []string{"a.md"}},
+		{ref: "~/notes/.*.md", want: []string{".hidden.md"}},
+		{ref: "~/notes/skipped.log", want: []string{"skipped.log"}},
+		{ref: "~/notes/*.go", wantErr: "no files match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			matches, err := Match(tt.ref)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			var got []string
+			for _, m := range matches {
+				rel, err := filepath.Rel(notes, m)
+				require.NoError(t, err)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package files
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is one pattern of a .gitignore file.
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreFile holds the rules of the .gitignore file in dir.
+type ignoreFile struct {
+	dir   string
+	rules []ignoreRule
+}
+
+// ignorer decides which paths .gitignore files exclude. Rules of deeper
+// directories come later and take precedence, as in git.
+type ignorer struct {
+	files  []ignoreFile
+	loaded map[string]bool
+}
+
+func newIgnorer() *ignorer {
+	return &ignorer{loaded: make(map[string]bool)}
+}
+
+// loadParents loads the .gitignore files from the root of the git
+// repository containing dir down to dir itself. Outside a repository only
+// the one in dir applies.
+func (ig *ignorer) loadParents(dir string) {
+	var dirs []string
+	for d := dir; ; d = filepath.Dir(d) {
+		dirs = append(dirs, d)
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			break
+		}
+		if filepath.Dir(d) == d {
+			// Not in a repository.
+			dirs = dirs[:1]
+			break
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		ig.load(dirs[i])
+	}
+}
+
+// load reads the .gitignore file in dir, if there is one.
+func (ig *ignorer) load(dir string) {
+	if ig.loaded[dir] {
+		return
+	}
+	ig.loaded[dir] = true
+
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	file := ignoreFile{dir: dir}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			file.rules = append(file.rules, rule)
+		}
+	}
+	if len(file.rules) > 0 {
+		ig.files = append(ig.files, file)
+	}
+}
+
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A pattern with a slash before its end is relative to the directory
+	// of the .gitignore file; otherwise it matches a name at any depth.
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	re, err := globRegexp(line)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// ignored reports whether the .gitignore files loaded so far exclude path,
+// an absolute path.
+func (ig *ignorer) ignored(path string, isDir bool) bool {
+	ignored := false
+	for _, file := range ig.files {
+		rel, err := filepath.Rel(file.dir, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, rule := range file.rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.re.MatchString(rel) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,100 @@
+package files
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIgnoreRule(t *testing.T) {
+	tests := []struct {
+		line        string
+		ok          bool
+		negate      bool
+		dirOnly     bool
+		wantPattern string
+	}{
+		{line: "", ok: false},
+		{line: "   ", ok: false},
+		{line: "# comment", ok: false},
+		{line: "!", ok: false},
+		{line: "/", ok: false},
+		{line: "*.log", ok: true, wantPattern: `^(?:.*/)?[^/]*\.log$`},
+		{line: "*.log  \r", ok: true, wantPattern: `^(?:.*/)?[^/]*\.log$`},
+		{line: "!keep.log", ok: true, negate: true, wantPattern: `^(?:.*/)?keep\.log$`},
+		{line: "cache/", ok: true, dirOnly: true, wantPattern: `^(?:.*/)?cache$`},
+		{line: "/build", ok: true, wantPattern: `^build$`},
+		{line: "docs/*.tmp", ok: true, wantPattern: `^docs/[^/]*\.tmp$`},
+		{line: `\#notes`, ok: true, wantPattern: `^(?:.*/)?#notes$`},
+		{line: `\!important`, ok: true, wantPattern: `^(?:.*/)?!important$`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			rule, ok := parseIgnoreRule(tt.line)
+			require.Equal(t, tt.ok, ok)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tt.negate, rule.negate)
+			assert.Equal(t, tt.dirOnly, rule.dirOnly)
+			assert.Equal(t, tt.wantPattern, rule.re.String())
+		})
+	}
+}
+
+// ignoreFileOf parses a .gitignore file in dir.
+func ignoreFileOf(dir, content string) ignoreFile {
+	file := ignoreFile{dir: dir}
+	for _, line := range strings.Split(content, "\n") {
+		if rule, ok := parseIgnoreRule(line); ok {
+			file.rules = append(file.rules, rule)
+		}
+	}
+	return file
+}
+
+func TestIgnored(t *testing.T) {
+	ig := newIgnorer()
+	ig.files = []ignoreFile{
+		ignoreFileOf("/repo", "# build output\n*.log\n!keep.log\n/build\ndocs/*.tmp\ncache/\nlogs/**/*.txt\n"),
+		// Deeper files come later and win.
+		ignoreFileOf("/repo/sub", "!important.log\n"),
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{path: "/repo/a.log", want: true},
+		{path: "/repo/deep/down/a.log", want: true},
+		{path: "/repo/keep.log", want: false},
+		{path: "/repo/deep/keep.log", want: false},
+		{path: "/repo/a.txt", want: false},
+
+		// Anchored by a leading slash or one inside the pattern.
+		{path: "/repo/build", isDir: true, want: true},
+		{path: "/repo/src/build", isDir: true, want: false},
+		{path: "/repo/docs/a.tmp", want: true},
+		{path: "/repo/src/docs/a.tmp", want: false},
+
+		// Directory-only rules.
+		{path: "/repo/cache", isDir: true, want: true},
+		{path: "/repo/src/cache", isDir: true, want: true},
+		{path: "/repo/cache", want: false},
+
+		{path: "/repo/logs/a.txt", want: true},
+		{path: "/repo/logs/2024/01/a.txt", want: true},
+		{path: "/repo/src/logs/a.txt", want: false},
+
+		{path: "/repo/sub/important.log", want: false},
+		{path: "/repo/sub/other.log", want: true},
+		{path: "/repo", isDir: true, want: false},
+		{path: "/elsewhere/a.log", want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ig.ignored(tt.path, tt.isDir), "%s", tt.path)
+	}
+}
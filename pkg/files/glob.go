@@ -0,0 +1,83 @@
+package files
+
+import (
+	"regexp"
+	"strings"
+)
+
+// hasMeta reports whether a pattern contains glob syntax.
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// globRegexp translates a slash-separated glob into a regular expression
+// matching whole paths. "*" and "?" stay within one path element, "**"
+// crosses any number of them and "[...]" is a character class.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**") {
+				i++
+				if strings.HasPrefix(pattern[i+1:], "/") {
+					// "**/" also matches no directory at all.
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// globBase returns the leading path elements of a pattern that contain no
+// glob syntax, where a walk for its matches starts.
+func globBase(pattern string) string {
+	parts := strings.Split(pattern, "/")
+	var base []string
+	for _, part := range parts[:len(parts)-1] {
+		if hasMeta(part) {
+			break
+		}
+		base = append(base, part)
+	}
+	if len(base) == 0 {
+		if strings.HasPrefix(pattern, "/") {
+			return "/"
+		}
+		return "."
+	}
+	if len(base) == 1 && base[0] == "" {
+		return "/"
+	}
+	return strings.Join(base, "/")
+}
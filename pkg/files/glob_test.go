@@ -0,0 +1,60 @@
+package files
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{"*.go", []string{"main.go", ".go"}, []string{"pkg/main.go", "main.go.orig"}},
+		{"pkg/*.go", []string{"pkg/main.go"}, []string{"pkg/cli/main.go", "main.go"}},
+		{"**/*.go", []string{"main.go", "pkg/cli/main.go"}, []string{"main.goo"}},
+		{"pkg/**", []string{"pkg/a", "pkg/a/b.go"}, []string{"pkgs/a", "src/pkg/a"}},
+		{"a/**/b", []string{"a/b", "a/x/b", "a/x/y/b"}, []string{"a/xb", "b"}},
+		{"?.txt", []string{"a.txt"}, []string{"ab.txt", "/.txt"}},
+		{"[ab].txt", []string{"a.txt", "b.txt"}, []string{"c.txt"}},
+		{"[!ab].txt", []string{"c.txt"}, []string{"a.txt"}},
+		{"[a-c]*", []string{"b", "cat"}, []string{"dog"}},
+		{"[unclosed", []string{"[unclosed"}, []string{"u"}},
+		{`\*.txt`, []string{"*.txt"}, []string{"a.txt"}},
+		{"a.b+c", []string{"a.b+c"}, []string{"axb+c", "a.bbc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			re, err := globRegexp(tt.pattern)
+			require.NoError(t, err)
+			for _, path := range tt.match {
+				assert.True(t, re.MatchString(path), "%q should match %q", tt.pattern, path)
+			}
+			for _, path := range tt.noMatch {
+				assert.False(t, re.MatchString(path), "%q should not match %q", tt.pattern, path)
+			}
+		})
+	}
+}
+
+func TestGlobBase(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"*.go", "."},
+		{"**/*.go", "."},
+		{"pkg/**/*.go", "pkg"},
+		{"a/b/c*", "a/b"},
+		{"a/*/c", "a"},
+		{"/etc/*.conf", "/etc"},
+		{"/*", "/"},
+		{"/**/*.md", "/"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, globBase(tt.pattern), "%q", tt.pattern)
+	}
+}
@@ -0,0 +1,83 @@
+package lineedit
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// CompletePath returns the files and directories whose names start with
+// word, which may begin with "~/". Directories end with a slash.
+func CompletePath(word string) []string {
+	expanded := word
+	home := ""
+	if rest, ok := strings.CutPrefix(word, "~/"); ok {
+		if h, err := os.UserHomeDir(); err == nil {
+			home = h
+			expanded = filepath.Join(h, rest)
+			if strings.HasSuffix(word, "/") {
+				expanded += "/"
+			}
+		}
+	}
+
+	dir, base := filepath.Split(expanded)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		match := dir + name
+		if entry.IsDir() {
+			match += "/"
+		}
+		if home != "" {
+			match = "~/" + strings.TrimPrefix(match, home+"/")
+		}
+		matches = append(matches, match)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// CompleteWords returns the words starting with prefix, sorted.
+func CompleteWords(prefix string, words []string) []string {
+	var matches []string
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			matches = append(matches, w)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// commonPrefix returns the longest prefix shared by all candidates. It never
+// ends in the middle of a UTF-8 sequence.
+func commonPrefix(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for len(prefix) < len(candidates[0]) && !utf8.RuneStart(candidates[0][len(prefix)]) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
@@ -0,0 +1,132 @@
+package lineedit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// DefaultHistorySize is the number of entries kept when Options.HistorySize
+// is not set.
+const DefaultHistorySize = 1000
+
+// history keeps entered lines in memory and appends them to a file. Each
+// line of the file is a JSON string, so entries may span several lines.
+//
+// The terminal adds every line it reads; the reader then either commits the
+// latest entry to the file or discards it, so pasted text stays out of the
+// history.
+type history struct {
+	entries []string
+	path    string
+	size    int
+	// pending is true while the latest entry is not yet committed.
+	pending bool
+}
+
+func loadHistory(path string, size int) *history {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	h := &history{path: path, size: size}
+	if path == "" {
+		return h
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry string
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry != "" {
+			h.entries = append(h.entries, entry)
+		}
+	}
+
+	if len(h.entries) > size {
+		h.entries = h.entries[len(h.entries)-size:]
+		h.rewrite()
+	}
+	return h
+}
+
+// Add records an entry, skipping blanks and immediate repeats.
+func (h *history) Add(entry string) {
+	h.pending = false
+	if entry == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > h.size {
+		h.entries = h.entries[1:]
+	}
+	h.pending = true
+}
+
+// commit saves the latest entry to the file if it has not been yet.
+func (h *history) commit() {
+	if h.pending {
+		h.pending = false
+		h.append(h.entries[len(h.entries)-1])
+	}
+}
+
+// discard forgets the latest entry if it has not been committed.
+func (h *history) discard() {
+	if h.pending {
+		h.pending = false
+		h.entries = h.entries[:len(h.entries)-1]
+	}
+}
+
+// Len returns the number of entries.
+func (h *history) Len() int {
+	return len(h.entries)
+}
+
+// At returns an entry; index 0 is the most recent one.
+func (h *history) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
+
+func (h *history) append(entry string) {
+	if h.path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	data, _ := json.Marshal(entry)
+	f.Write(append(data, '\n'))
+}
+
+// rewrite replaces the file with the entries in memory, dropping old ones.
+func (h *history) rewrite() {
+	tmp := h.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(f)
+	for _, entry := range h.entries {
+		data, _ := json.Marshal(entry)
+		w.Write(append(data, '\n'))
+	}
+	if w.Flush() != nil || f.Close() != nil {
+		os.Remove(tmp)
+		return
+	}
+	os.Rename(tmp, h.path)
+}
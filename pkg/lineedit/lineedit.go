@@ -0,0 +1,80 @@
+// Package lineedit reads lines of input for interactive mode: with line
+// editing, history and completion on a terminal, and plainly otherwise.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl+C on an
+// empty line. On a terminal, Ctrl+C on a line with text discards the text
+// instead.
+var ErrInterrupted = errors.New("interrupted")
+
+// Completer returns candidates for the input line up to the cursor. Each
+// candidate replaces the whole of line.
+type Completer func(line string) []string
+
+// Reader reads lines of input.
+type Reader interface {
+	// ReadLine shows prompt and returns the next line without its line
+	// ending. Text pasted into a terminal is returned whole, even if it
+	// spans several lines. It returns io.EOF at the end of input and
+	// ErrInterrupted on Ctrl+C.
+	ReadLine(prompt string) (string, error)
+	// Close restores the terminal and saves nothing further to history.
+	Close() error
+}
+
+// Options configures New.
+type Options struct {
+	// HistoryFile keeps entered lines across sessions; empty disables it.
+	HistoryFile string
+	// HistorySize caps the number of lines kept in the history file.
+	HistorySize int
+	Complete    Completer
+	// ContinuePrompt is shown for each line after the first of a paste.
+	ContinuePrompt string
+}
+
+// New returns a line editor when stdin and stdout are terminals, and a plain
+// line reader otherwise.
+func New(opts Options) Reader {
+	if r, ok := newTerminalReader(opts); ok {
+		return r
+	}
+	return NewPlain(os.Stdin, os.Stdout)
+}
+
+// plainReader reads lines without editing, for pipes and files.
+type plainReader struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewPlain returns a Reader without editing features. Lines may be of any
+// length.
+func NewPlain(in io.Reader, out io.Writer) Reader {
+	return &plainReader{in: bufio.NewReader(in), out: out}
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	line, err := r.in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (r *plainReader) Close() error {
+	return nil
+}
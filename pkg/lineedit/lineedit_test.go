@@ -0,0 +1,210 @@
+package lineedit
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// entries returns the entries of h, oldest first.
+func entries(h *history) []string {
+	var out []string
+	for i := h.Len() - 1; i >= 0; i-- {
+		out = append(out, h.At(i))
+	}
+	return out
+}
+
+func TestHistoryCommitAndDiscard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h := loadHistory(path, 10)
+
+	h.Add("first")
+	h.commit()
+	h.Add("pasted\ntext")
+	h.discard()
+	h.Add("")
+	h.Add("second")
+	h.commit()
+	h.Add("second")
+	h.commit()
+	h.commit()
+	// Discarding after a commit keeps the entry.
+	h.discard()
+
+	assert.Equal(t, []string{"first", "second"}, entries(h))
+	assert.Equal(t, "second", h.At(0))
+
+	reloaded := loadHistory(path, 10)
+	assert.Equal(t, []string{"first", "second"}, entries(reloaded))
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "history")
+	h := loadHistory(path, 0)
+	assert.Equal(t, DefaultHistorySize, h.size)
+
+	lines := []string{"one", "a block\nof \"quoted\" lines", "ünïcode ✓", `back\slash`}
+	for _, line := range lines {
+		h.Add(line)
+		h.commit()
+	}
+	assert.Equal(t, lines, entries(loadHistory(path, 0)))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestHistorySizeLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	require.NoError(t, os.WriteFile(path, []byte("\"1\"\n\"2\"\nnot json\n\"\"\n\"3\"\n\"4\"\n"), 0600))
+
+	h := loadHistory(path, 3)
+	assert.Equal(t, []string{"2", "3", "4"}, entries(h))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "\"2\"\n\"3\"\n\"4\"\n", string(data), "the file is rewritten without old entries")
+	assert.NoFileExists(t, path+".tmp")
+
+	h.Add("5")
+	h.commit()
+	assert.Equal(t, []string{"3", "4", "5"}, entries(h))
+}
+
+func TestHistoryWithoutFile(t *testing.T) {
+	h := loadHistory("", 2)
+	h.Add("a")
+	h.commit()
+	h.Add("b")
+	h.commit()
+	h.Add("c")
+	h.commit()
+	assert.Equal(t, []string{"b", "c"}, entries(h))
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		candidates []string
+		want       string
+	}{
+		{nil, ""},
+		{[]string{"alone"}, "alone"},
+		{[]string{"/model", "/models"}, "/model"},
+		{[]string{"pkg/cli/", "pkg/config/"}, "pkg/c"},
+		{[]string{"abc", "xyz"}, ""},
+		// "é" and "è" share their first byte; the prefix stops before it.
+		{[]string{"café", "cafè"}, "caf"},
+	}
+	for _, tt := range tests {
+		got := commonPrefix(tt.candidates)
+		assert.Equal(t, tt.want, got, "%q", tt.candidates)
+		assert.True(t, utf8.ValidString(got))
+	}
+}
+
+func TestCompleteWords(t *testing.T) {
+	words := []string{"/temp", "/help", "/tokens", "/history"}
+	assert.Equal(t, []string{"/temp", "/tokens"}, CompleteWords("/t", words))
+	assert.Empty(t, CompleteWords("/x", words))
+}
+
+func TestCompletePath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main.go", "main_test.go", "make.sh", ".hidden"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "manual"), 0755))
+	t.Chdir(dir)
+
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"ma", []string{"main.go", "main_test.go", "make.sh", "manual/"}},
+		{"main", []string{"main.go", "main_test.go"}},
+		{"manual", []string{"manual/"}},
+		{"manual/", nil},
+		{".h", []string{".hidden"}},
+		{"missing/x", nil},
+		{dir + "/mak", []string{dir + "/make.sh"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, CompletePath(tt.word), "%q", tt.word)
+	}
+	assert.NotContains(t, CompletePath(""), ".hidden", "hidden files need a leading dot")
+}
+
+func TestCompletePathHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	require.NoError(t, os.Mkdir(filepath.Join(home, "notes"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "notes", "todo.md"), nil, 0644))
+
+	assert.Equal(t, []string{"~/notes/"}, CompletePath("~/no"))
+	assert.Equal(t, []string{"~/notes/todo.md"}, CompletePath("~/notes/"))
+}
+
+func TestReverseSearch(t *testing.T) {
+	r := &terminalReader{history: loadHistory("", 10)}
+	for _, entry := range []string{"git status", "go test ./...", "git log", "ls"} {
+		r.history.Add(entry)
+	}
+
+	line, pos, ok := r.reverseSearch("git")
+	assert.True(t, ok)
+	assert.Equal(t, "git log", line)
+	assert.Equal(t, len(line), pos)
+
+	line, _, _ = r.reverseSearch(line)
+	assert.Equal(t, "git status", line, "Ctrl+R again finds older matches")
+	line, _, _ = r.reverseSearch(line)
+	assert.Equal(t, "git status", line, "the oldest match stays")
+
+	// Any other key ends the search.
+	r.onKey(line, len(line), 'x')
+	line, _, _ = r.reverseSearch("test")
+	assert.Equal(t, "go test ./...", line)
+}
+
+func TestInterruptReader(t *testing.T) {
+	input := &interruptReader{r: strings.NewReader("ab\x03c\x07d\x03")}
+	data, err := io.ReadAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, "ab\uffff\rc\x07d\uffff\r", string(data), "only Ctrl+C becomes the sentinel")
+	assert.Equal(t, 2, input.interrupts)
+
+	r := &terminalReader{input: input}
+	_, _, ok := r.onKey("abc", 3, keyInterrupt)
+	assert.True(t, ok)
+	assert.True(t, r.interrupted)
+	assert.Equal(t, "abc", r.interruptedLine)
+	assert.Equal(t, 1, input.interrupts)
+
+	// Without a Ctrl+C waiting, the sentinel is not an interrupt.
+	r = &terminalReader{input: &interruptReader{}}
+	_, _, ok = r.onKey("abc", 3, keyInterrupt)
+	assert.False(t, ok)
+	assert.False(t, r.interrupted)
+}
+
+func TestInterruptReaderSmallBuffer(t *testing.T) {
+	input := &interruptReader{r: strings.NewReader("\x03x")}
+	var out []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := input.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "\uffff\rx", string(out), "the sentinel survives reads smaller than it")
+}
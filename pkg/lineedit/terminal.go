@@ -0,0 +1,267 @@
+package lineedit
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Keys handled on top of those the terminal line editor knows.
+const (
+	keyCtrlC = 3
+	keyTab   = 9
+	keyEnter = 13
+	keyCtrlR = 18
+)
+
+// keyInterrupt stands for Ctrl+C on its way to onKey. It is a noncharacter,
+// which no keyboard sends.
+const keyInterrupt = '\uffff'
+
+// terminalReader edits lines in raw mode with emacs-style keys, history
+// recall, reverse search on Ctrl+R and completion on Tab. The terminal is
+// only in raw mode while a line is being read, so output in between behaves
+// normally.
+type terminalReader struct {
+	fd       int
+	terminal *term.Terminal
+	input    *interruptReader
+	history  *history
+	complete Completer
+	// continuePrompt is shown while a paste of several lines is read.
+	continuePrompt string
+
+	mu    sync.Mutex
+	state *term.State
+
+	// interrupted is set when Ctrl+C ends the line being read, and
+	// interruptedLine holds what had been typed.
+	interrupted     bool
+	interruptedLine string
+
+	// Reverse search state: the text searched for and the history index of
+	// the last match.
+	searching   bool
+	searchQuery string
+	searchIndex int
+}
+
+func newTerminalReader(opts Options) (*terminalReader, bool) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil, false
+	}
+
+	r := &terminalReader{
+		fd:       fd,
+		input:    &interruptReader{r: os.Stdin},
+		history:  loadHistory(opts.HistoryFile, opts.HistorySize),
+		complete: opts.Complete,
+
+		continuePrompt: opts.ContinuePrompt,
+	}
+	r.terminal = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{r.input, os.Stdout}, "")
+	r.terminal.History = r.history
+	r.terminal.AutoCompleteCallback = r.onKey
+	return r, true
+}
+
+func (r *terminalReader) ReadLine(prompt string) (string, error) {
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.state = state
+	r.mu.Unlock()
+	defer r.restore()
+
+	if width, height, err := term.GetSize(r.fd); err == nil && width > 0 {
+		r.terminal.SetSize(width, height)
+	}
+	r.terminal.SetBracketedPasteMode(true)
+	defer r.terminal.SetBracketedPasteMode(false)
+
+	// A paste of several lines is read one line at a time and returned
+	// as a whole once the user presses Enter after it.
+	var pasted []string
+	first := prompt
+	for {
+		r.terminal.SetPrompt(prompt)
+		r.interrupted = false
+		r.searching = false
+
+		line, err := r.terminal.ReadLine()
+		if r.interrupted && err == nil {
+			if r.interruptedLine != "" || pasted != nil {
+				// Ctrl+C discards the text typed so far and starts over.
+				pasted = nil
+				prompt = first
+				continue
+			}
+			return "", ErrInterrupted
+		}
+		if err == term.ErrPasteIndicator {
+			r.history.discard()
+			pasted = append(pasted, line)
+			prompt = r.continuePrompt
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if pasted == nil {
+			r.history.commit()
+			return line, nil
+		}
+		r.history.discard()
+		if line != "" {
+			pasted = append(pasted, line)
+		}
+		return strings.Join(pasted, "\n"), nil
+	}
+}
+
+func (r *terminalReader) restore() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state != nil {
+		term.Restore(r.fd, r.state)
+		r.state = nil
+	}
+}
+
+func (r *terminalReader) Close() error {
+	r.restore()
+	return nil
+}
+
+// onKey is called by the terminal for every key it does not handle itself.
+func (r *terminalReader) onKey(line string, pos int, key rune) (string, int, bool) {
+	if key != keyCtrlR {
+		r.searching = false
+	}
+
+	switch key {
+	case keyInterrupt:
+		if r.input.interrupts == 0 {
+			// Not from Ctrl+C, so not ours to handle.
+			return "", 0, false
+		}
+		r.input.interrupts--
+		r.interrupted = true
+		r.interruptedLine = line
+		return "", 0, true
+	case keyCtrlR:
+		return r.reverseSearch(line)
+	case keyTab:
+		return r.completeLine(line, pos)
+	}
+	return "", 0, false
+}
+
+// reverseSearch replaces the line with the most recent history entry
+// containing the text typed before the first Ctrl+R; pressing Ctrl+R again
+// moves to older matches.
+func (r *terminalReader) reverseSearch(line string) (string, int, bool) {
+	start := 0
+	if r.searching {
+		start = r.searchIndex + 1
+	} else {
+		r.searching = true
+		r.searchQuery = line
+	}
+
+	for i := start; i < r.history.Len(); i++ {
+		if entry := r.history.At(i); strings.Contains(entry, r.searchQuery) {
+			r.searchIndex = i
+			return entry, len(entry), true
+		}
+	}
+	// No older match: keep the line as it is.
+	return line, len(line), true
+}
+
+func (r *terminalReader) completeLine(line string, pos int) (string, int, bool) {
+	if r.complete == nil {
+		return "", 0, false
+	}
+	head, tail := line[:pos], line[pos:]
+
+	candidates := r.complete(head)
+	switch len(candidates) {
+	case 0:
+		return line, pos, true
+	case 1:
+		completed := candidates[0]
+		if !strings.HasSuffix(completed, "/") {
+			completed += " "
+		}
+		return completed + tail, len(completed), true
+	}
+
+	prefix := commonPrefix(candidates)
+	if len(prefix) <= len(head) {
+		// Nothing more to fill in: show the choices above the prompt.
+		r.showCandidates(candidates)
+		return line, pos, true
+	}
+	return prefix + tail, len(prefix), true
+}
+
+func (r *terminalReader) showCandidates(candidates []string) {
+	shown := make([]string, len(candidates))
+	for i, c := range candidates {
+		// Show only the last word of each candidate, and only the base
+		// name of paths.
+		c = c[strings.LastIndex(c, " ")+1:]
+		if j := strings.LastIndex(strings.TrimSuffix(c, "/"), "/"); j > 0 {
+			c = c[j+1:]
+		}
+		shown[i] = c
+	}
+	r.terminal.Write([]byte(strings.Join(shown, "  ") + "\n"))
+}
+
+// interruptReader hands Ctrl+C to the terminal as keyInterrupt followed by
+// Enter, so that keyInterrupt reaches onKey with the line being typed, which
+// it clears, and Enter ends the line as usual. The terminal would otherwise
+// report Ctrl+C like Ctrl+D and leave its state mid-line.
+type interruptReader struct {
+	r       io.Reader
+	pending []byte
+	// interrupts counts the Ctrl+C not yet seen by onKey, so that only
+	// those are taken for one.
+	interrupts int
+}
+
+func (i *interruptReader) Read(p []byte) (int, error) {
+	if len(i.pending) > 0 {
+		n := copy(p, i.pending)
+		i.pending = i.pending[n:]
+		return n, nil
+	}
+
+	n, err := i.r.Read(p)
+	var out []byte
+	for _, b := range p[:n] {
+		if b == keyCtrlC {
+			out = utf8.AppendRune(out, keyInterrupt)
+			out = append(out, keyEnter)
+			i.interrupts++
+		} else {
+			out = append(out, b)
+		}
+	}
+	n = copy(p, out)
+	i.pending = out[n:]
+	return n, err
+}
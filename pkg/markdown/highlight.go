@@ -0,0 +1,272 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// language describes enough of a programming language's syntax to color
+// keywords, literals, strings, numbers and comments.
+type language struct {
+	keywords     map[string]bool
+	literals     map[string]bool
+	lineComments []string
+	blockComment [2]string
+	quotes       string
+	// foldCase matches keywords regardless of case, as in SQL.
+	foldCase bool
+}
+
+// highlightState carries what spans lines of code.
+type highlightState struct {
+	inComment bool
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	cLike = language{
+		keywords: words(`auto break case catch char class const continue default delete do double else enum
+			extends final finally float for goto if implements import int interface long namespace new
+			override package private protected public return short signed sizeof static struct super switch
+			template this throw throws try typedef typename union unsigned using var virtual void volatile while
+			fun val when object data companion let func guard protocol extension`),
+		literals:     words("true false null nullptr NULL this self"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+
+	languages = map[string]*language{
+		"go": {
+			keywords: words(`break case chan const continue default defer else fallthrough for func go goto if
+				import interface map package range return select struct switch type var`),
+			literals:     words("true false nil iota"),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       "\"'`",
+		},
+		"python": {
+			keywords: words(`and as assert async await break class continue def del elif else except finally
+				for from global if import in is lambda match case nonlocal not or pass raise return try while
+				with yield`),
+			literals:     words("True False None self"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"javascript": {
+			keywords: words(`async await break case catch class const continue debugger default delete do else
+				export extends finally for from function if import in instanceof let new of return static super
+				switch throw try typeof var void while with yield interface type enum implements declare
+				readonly abstract as private protected public`),
+			literals:     words("true false null undefined this NaN Infinity"),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       "\"'`",
+		},
+		"rust": {
+			keywords: words(`as async await break const continue crate dyn else enum extern fn for if impl in
+				let loop match mod move mut pub ref return static struct super trait type unsafe use where
+				while`),
+			literals:     words("true false self Self None Some Ok Err"),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `"`,
+		},
+		"c": &cLike,
+		"ruby": {
+			keywords: words(`alias and begin break case class def defined? do else elsif end ensure for if in
+				module next not or redo rescue retry return super then undef unless until when while yield
+				require attr_accessor attr_reader`),
+			literals:     words("true false nil self"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"shell": {
+			keywords: words(`if then else elif fi for while until do done case esac in function return local
+				export readonly set unset shift exit echo cd source`),
+			literals:     words("true false"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"sql": {
+			keywords: words(`select from where and or not insert into values update set delete create table
+				drop alter add index primary key foreign references join left right inner outer full on as
+				group by order having limit offset union all distinct case when then else end in is like
+				between exists view with returning default constraint unique asc desc`),
+			literals:     words("null true false"),
+			lineComments: []string{"--"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `'"`,
+			foldCase:     true,
+		},
+		"json": {
+			literals: words("true false null"),
+			quotes:   `"`,
+		},
+		"yaml": {
+			literals:     words("true false null yes no on off"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+	}
+
+	// aliases maps the names used on code fences to languages.
+	aliases = map[string]string{
+		"golang": "go",
+		"py":     "python", "python3": "python",
+		"js": "javascript", "jsx": "javascript", "ts": "javascript", "tsx": "javascript",
+		"typescript": "javascript", "node": "javascript",
+		"rs":  "rust",
+		"cpp": "c", "c++": "c", "cc": "c", "h": "c", "hpp": "c", "java": "c", "kotlin": "c", "kt": "c",
+		"csharp": "c", "cs": "c", "swift": "c", "scala": "c", "dart": "c",
+		"rb": "ruby",
+		"sh": "shell", "bash": "shell", "zsh": "shell", "console": "shell", "shellsession": "shell",
+		"psql": "sql", "mysql": "sql", "postgresql": "sql", "sqlite": "sql",
+		"yml": "yaml",
+	}
+)
+
+func lookupLanguage(name string) *language {
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	return languages[name]
+}
+
+// highlight colors one line of code. Lines of unknown languages are
+// returned as they are.
+func highlight(lang, line string, state *highlightState) string {
+	l := lookupLanguage(lang)
+	if l == nil {
+		return line
+	}
+
+	var b strings.Builder
+	span := func(color, text string) {
+		b.WriteString(color + text + reset)
+	}
+
+	for i := 0; i < len(line); {
+		rest := line[i:]
+
+		if state.inComment {
+			end := strings.Index(rest, l.blockComment[1])
+			if end < 0 {
+				span(dim, rest)
+				break
+			}
+			end += len(l.blockComment[1])
+			span(dim, rest[:end])
+			state.inComment = false
+			i += end
+			continue
+		}
+		if l.blockComment[0] != "" && strings.HasPrefix(rest, l.blockComment[0]) {
+			state.inComment = true
+			span(dim, l.blockComment[0])
+			i += len(l.blockComment[0])
+			continue
+		}
+		if l.startsLineComment(line, i) {
+			span(dim, rest)
+			break
+		}
+
+		c := rest[0]
+		switch {
+		case strings.IndexByte(l.quotes, c) >= 0:
+			end := stringEnd(rest)
+			span(green, rest[:end])
+			i += end
+			continue
+
+		case c >= '0' && c <= '9':
+			end := 1
+			for end < len(rest) && (isWordByte(rest[end]) || rest[end] == '.') {
+				end++
+			}
+			span(yellow, rest[:end])
+			i += end
+			continue
+
+		case isWordByte(c) || c >= utf8.RuneSelf:
+			end := 0
+			for end < len(rest) {
+				r, size := utf8.DecodeRuneInString(rest[end:])
+				if !(r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+					break
+				}
+				end += size
+			}
+			if end == 0 {
+				_, end = utf8.DecodeRuneInString(rest)
+				b.WriteString(rest[:end])
+				i += end
+				continue
+			}
+			word := rest[:end]
+			key := word
+			if l.foldCase {
+				key = strings.ToLower(word)
+			}
+			switch {
+			case l.keywords[key]:
+				span(magenta, word)
+			case l.literals[key]:
+				span(yellow, word)
+			case strings.HasPrefix(rest[end:], "("):
+				span(blue, word)
+			default:
+				b.WriteString(word)
+			}
+			i += end
+			continue
+		}
+
+		b.WriteByte(c)
+		i++
+	}
+	return b.String()
+}
+
+// startsLineComment reports whether a line comment starts at line[i]. A "#"
+// only starts one at the start of a word, so that "$#" or "a#b" do not.
+func (l *language) startsLineComment(line string, i int) bool {
+	for _, marker := range l.lineComments {
+		if !strings.HasPrefix(line[i:], marker) {
+			continue
+		}
+		if marker == "#" && i > 0 && !unicode.IsSpace(rune(line[i-1])) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// stringEnd returns the length of the string literal s starts with, or of s
+// when the literal does not end on this line.
+func stringEnd(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,508 @@
+// Package markdown renders Markdown to ANSI-styled terminal text as it
+// streams in. Block elements are recognized from the start of each line and
+// inline emphasis is styled as soon as its closing marker arrives, so text
+// is shown without waiting for the end of a paragraph; only code lines are
+// held until they are complete, for highlighting.
+package markdown
+
+import (
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ANSI styles used by the renderer.
+const (
+	reset     = "\033[0m"
+	bold      = "\033[1m"
+	dim       = "\033[2m"
+	italic    = "\033[3m"
+	underline = "\033[4m"
+	strike    = "\033[9m"
+	red       = "\033[31m"
+	green     = "\033[32m"
+	yellow    = "\033[33m"
+	blue      = "\033[34m"
+	magenta   = "\033[35m"
+	cyan      = "\033[36m"
+)
+
+// Renderer writes Markdown written to it as styled text. It is not safe for
+// concurrent use.
+type Renderer struct {
+	w io.Writer
+	// pending holds text not rendered yet: an undecided line start, a
+	// marker that may continue in the next write, emphasis not closed yet,
+	// a split UTF-8 sequence, or a code line.
+	pending string
+	// lineStart is set until the block element of the line is known.
+	lineStart bool
+
+	// Fenced code block state.
+	inCode    bool
+	fence     string
+	codeLang  string
+	codeState highlightState
+
+	// Inline state of the current line.
+	lineStyle string
+	bold      bool
+	italic    bool
+	code      bool
+	strike    bool
+	// styled is set while escape codes are in effect on the output.
+	styled bool
+	// prev is the last rune of text written on the line, for deciding
+	// whether an underscore or asterisk opens or closes emphasis.
+	prev rune
+}
+
+// NewRenderer returns a renderer writing to w.
+func NewRenderer(w io.Writer) *Renderer {
+	return &Renderer{w: w, lineStart: true}
+}
+
+// Write renders as much of text as can be decided on.
+func (r *Renderer) Write(p []byte) (int, error) {
+	r.pending += string(p)
+	r.render(false)
+	return len(p), nil
+}
+
+// Flush renders everything held back, as if the text ended here, and resets
+// the style.
+func (r *Renderer) Flush() error {
+	r.render(true)
+	r.endLine()
+	r.pending = ""
+	r.lineStart = true
+	r.inCode = false
+	return nil
+}
+
+func (r *Renderer) render(final bool) {
+	for r.pending != "" {
+		nl := strings.IndexByte(r.pending, '\n')
+
+		if r.inCode {
+			if nl < 0 {
+				if final {
+					r.codeLine(r.pending)
+					r.pending = ""
+				}
+				return
+			}
+			r.codeLine(r.pending[:nl])
+			r.out("\n")
+			r.pending = r.pending[nl+1:]
+			continue
+		}
+
+		if r.lineStart {
+			line, complete := r.pending, final
+			if nl >= 0 {
+				line, complete = r.pending[:nl], true
+			}
+			n, ok := r.block(line, complete)
+			if !ok {
+				return
+			}
+			r.pending = r.pending[n:]
+			r.lineStart = false
+			if r.inCode {
+				// The fence line has been written; code starts on the
+				// next line.
+				if strings.HasPrefix(r.pending, "\n") {
+					r.out("\n")
+					r.pending = r.pending[1:]
+				}
+				continue
+			}
+		}
+
+		nl = strings.IndexByte(r.pending, '\n')
+		if nl < 0 {
+			r.pending = r.inline(r.pending, final)
+			return
+		}
+		r.inline(r.pending[:nl], true)
+		r.endLine()
+		r.out("\n")
+		r.pending = r.pending[nl+1:]
+		r.lineStart = true
+	}
+}
+
+// block recognizes the block element a line starts with and writes its
+// marker. It returns the number of bytes consumed, or false when more of the
+// line is needed to tell.
+func (r *Renderer) block(line string, complete bool) (int, bool) {
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+	rest := line[indent:]
+	if !complete && rest == "" {
+		return 0, false
+	}
+
+	// Fences and rules are only known once the whole line is there.
+	if c := firstRune(rest); !complete && (c == '`' || c == '~') && strings.Trim(rest, string(c)) == "" {
+		return 0, false
+	}
+	if marker := fenceMarker(rest); marker != "" {
+		if !complete {
+			return 0, false
+		}
+		r.inCode = true
+		r.fence = marker
+		r.codeLang = strings.ToLower(strings.TrimSpace(strings.TrimLeft(rest, marker[:1])))
+		r.codeState = highlightState{}
+		label := r.codeLang
+		if label == "" {
+			label = "code"
+		}
+		r.out(dim + line[:indent] + "── " + label + " ──" + reset)
+		return len(line), true
+	}
+	if strings.ContainsRune("-*_", firstRune(rest)) && onlyRuleChars(rest) {
+		if !complete {
+			return 0, false
+		}
+		if isRule(rest) {
+			r.out(dim + line[:indent] + strings.Repeat("─", 40) + reset)
+			return len(line), true
+		}
+	}
+
+	switch c := firstRune(rest); {
+	case c == '#':
+		level := len(rest) - len(strings.TrimLeft(rest, "#"))
+		after := rest[level:]
+		if level > 6 || (after != "" && after[0] != ' ') {
+			break
+		}
+		if after == "" && !complete {
+			return 0, false
+		}
+		r.lineStyle = bold + cyan
+		if level <= 2 {
+			r.lineStyle = bold + magenta
+		}
+		if level == 1 {
+			r.lineStyle += underline
+		}
+		r.out(line[:indent])
+		return indent + level + min(len(after), 1), true
+
+	case c == '-' || c == '*' || c == '+':
+		if len(rest) < 2 {
+			if !complete {
+				return 0, false
+			}
+			break
+		}
+		if rest[1] != ' ' {
+			break
+		}
+		r.out(line[:indent] + yellow + "•" + reset + " ")
+		return indent + 2, true
+
+	case c >= '0' && c <= '9':
+		digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+		if digits == len(rest) && !complete {
+			return 0, false
+		}
+		after := rest[digits:]
+		if len(after) < 2 && !complete {
+			return 0, false
+		}
+		if digits > 9 || len(after) < 2 || (after[0] != '.' && after[0] != ')') || after[1] != ' ' {
+			break
+		}
+		r.out(line[:indent] + yellow + rest[:digits+1] + reset + " ")
+		return indent + digits + 2, true
+
+	case c == '>':
+		if len(rest) < 2 && !complete {
+			return 0, false
+		}
+		n := indent + 1
+		if len(rest) > 1 && rest[1] == ' ' {
+			n++
+		}
+		r.out(line[:indent] + dim + "│" + reset + " ")
+		r.lineStyle = italic
+		return n, true
+	}
+
+	r.out(line[:indent])
+	return indent, true
+}
+
+// inline writes text of the current line, styling emphasis and code spans.
+// Unless final, a marker at the end that may continue in the next write, an
+// incomplete UTF-8 sequence, or emphasis not closed yet is held back and
+// returned. Emphasis still open at the end of the line is written as is.
+func (r *Renderer) inline(text string, final bool) string {
+	var b strings.Builder
+	if r.lineStyle != "" && !r.styled {
+		b.WriteString(r.lineStyle)
+		r.styled = true
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+
+		if c == '`' {
+			r.code = !r.code
+			r.style(&b)
+			i++
+			continue
+		}
+		if !final && !utf8.FullRuneInString(text[i:]) {
+			r.out(b.String())
+			return text[i:]
+		}
+		if r.code {
+			ch, size := utf8.DecodeRuneInString(text[i:])
+			b.WriteRune(ch)
+			r.prev = ch
+			i += size
+			continue
+		}
+
+		switch c {
+		case '\\':
+			if i+1 >= len(text) {
+				if !final {
+					r.out(b.String())
+					return text[i:]
+				}
+				break
+			}
+			if strings.IndexByte("\\`*_~#[]()>-+.!|", text[i+1]) >= 0 {
+				b.WriteByte(text[i+1])
+				r.prev = rune(text[i+1])
+				i += 2
+				continue
+			}
+
+		case '*', '_', '~':
+			run := len(text[i:]) - len(strings.TrimLeft(text[i:], string(c)))
+			if !final && !utf8.FullRuneInString(text[i+run:]) {
+				// The run or the character after it may still come.
+				r.out(b.String())
+				return text[i:]
+			}
+			next, _ := utf8.DecodeRuneInString(text[i+run:])
+			if i+run >= len(text) {
+				next = ' '
+			}
+			ok, opens := r.emphasis(c, run, next)
+			if ok && opens && !closes(text[i+run:], c, run, final) {
+				if !final {
+					// Style the text only once the emphasis is known to
+					// close on this line.
+					r.out(b.String())
+					return text[i:]
+				}
+				ok = false
+			}
+			if ok {
+				r.toggle(c, run)
+				r.style(&b)
+				r.prev = rune(c)
+				i += run
+				continue
+			}
+			b.WriteString(text[i : i+run])
+			r.prev = rune(c)
+			i += run
+			continue
+		}
+
+		ch, size := utf8.DecodeRuneInString(text[i:])
+		b.WriteRune(ch)
+		r.prev = ch
+		i += size
+	}
+
+	r.out(b.String())
+	return ""
+}
+
+// emphasis reports whether a run of markers toggles a style in this
+// position, and whether it opens the style rather than closing it.
+func (r *Renderer) emphasis(c byte, run int, next rune) (ok, opens bool) {
+	prevSpace := r.prev == 0 || unicode.IsSpace(r.prev)
+	nextSpace := unicode.IsSpace(next)
+	// Underscores inside words, as in snake_case, are not emphasis.
+	if c == '_' && isWordRune(r.prev) && isWordRune(next) {
+		return false, false
+	}
+
+	var on bool
+	switch {
+	case c == '~' && run == 2:
+		on = r.strike
+	case c != '~' && run == 2:
+		on = r.bold
+	case c != '~' && run == 1:
+		on = r.italic
+	case c != '~' && run == 3:
+		if r.bold != r.italic {
+			return false, false
+		}
+		on = r.bold
+	default:
+		return false, false
+	}
+
+	// An opening marker is followed by text, a closing one follows text.
+	if !on && nextSpace {
+		return false, false
+	}
+	if on && prevSpace {
+		return false, false
+	}
+	return true, !on
+}
+
+// toggle switches the style a run of markers stands for.
+func (r *Renderer) toggle(c byte, run int) {
+	switch {
+	case c == '~':
+		r.strike = !r.strike
+	case run == 1:
+		r.italic = !r.italic
+	case run == 2:
+		r.bold = !r.bold
+	default:
+		r.bold, r.italic = !r.bold, !r.italic
+	}
+}
+
+// closes reports whether text, which follows an opening run of markers,
+// holds a run that closes it. Unless final, a run at the end may still grow
+// and does not count.
+func closes(text string, c byte, run int, final bool) bool {
+	prev := rune(c)
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '\\':
+			if i+1 < len(text) {
+				prev = rune(text[i+1])
+				i += 2
+				continue
+			}
+		case '`':
+			// Markers in a code span are text.
+			end := strings.IndexByte(text[i+1:], '`')
+			if end < 0 {
+				return false
+			}
+			prev = '`'
+			i += end + 2
+			continue
+		case c:
+			n := len(text[i:]) - len(strings.TrimLeft(text[i:], string(c)))
+			if i+n >= len(text) && !final {
+				return false
+			}
+			next, _ := utf8.DecodeRuneInString(text[i+n:])
+			if i+n >= len(text) {
+				next = ' '
+			}
+			closing := !unicode.IsSpace(prev) && !(c == '_' && isWordRune(prev) && isWordRune(next))
+			if n == run && closing {
+				return true
+			}
+			prev = rune(c)
+			i += n
+			continue
+		}
+		ch, size := utf8.DecodeRuneInString(text[i:])
+		prev = ch
+		i += size
+	}
+	return false
+}
+
+// style writes the escape codes for the current inline state.
+func (r *Renderer) style(b *strings.Builder) {
+	b.WriteString(reset)
+	b.WriteString(r.lineStyle)
+	if r.bold {
+		b.WriteString(bold)
+	}
+	if r.italic {
+		b.WriteString(italic)
+	}
+	if r.strike {
+		b.WriteString(strike)
+	}
+	if r.code {
+		b.WriteString(cyan)
+	}
+	r.styled = true
+}
+
+// endLine resets the styles of the line.
+func (r *Renderer) endLine() {
+	if r.styled {
+		r.out(reset)
+	}
+	r.styled = false
+	r.lineStyle = ""
+	r.bold, r.italic, r.code, r.strike = false, false, false, false
+	r.prev = 0
+}
+
+func (r *Renderer) codeLine(line string) {
+	if strings.HasPrefix(strings.TrimLeft(line, " \t"), r.fence) && strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), r.fence[:1])) == "" {
+		r.inCode = false
+		r.lineStart = true
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		r.out(dim + indent + "──" + reset)
+		return
+	}
+	r.out(highlight(r.codeLang, line, &r.codeState))
+}
+
+func (r *Renderer) out(s string) {
+	if s != "" {
+		io.WriteString(r.w, s)
+	}
+}
+
+// fenceMarker returns the fence a line opens a code block with, if any.
+func fenceMarker(s string) string {
+	for _, c := range []string{"`", "~"} {
+		n := len(s) - len(strings.TrimLeft(s, c))
+		if n >= 3 {
+			if c == "`" && strings.Contains(s[n:], "`") {
+				return ""
+			}
+			return s[:n]
+		}
+	}
+	return ""
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+// onlyRuleChars reports whether s may still turn out to be a thematic break.
+func onlyRuleChars(s string) bool {
+	return strings.Trim(s, string(s[0])+" ") == ""
+}
+
+// isRule reports whether a complete line is a thematic break such as "---".
+func isRule(s string) bool {
+	return onlyRuleChars(s) && strings.Count(s, string(s[0])) >= 3
+}
@@ -0,0 +1,157 @@
+package markdown
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// render renders input written at once.
+func render(t *testing.T, input string) string {
+	t.Helper()
+	var out bytes.Buffer
+	r := NewRenderer(&out)
+	_, err := r.Write([]byte(input))
+	require.NoError(t, err)
+	require.NoError(t, r.Flush())
+	return out.String()
+}
+
+// renderBytes renders input written a byte at a time, as a stream may split
+// it anywhere.
+func renderBytes(t *testing.T, input string) string {
+	t.Helper()
+	var out bytes.Buffer
+	r := NewRenderer(&out)
+	for i := 0; i < len(input); i++ {
+		_, err := r.Write([]byte{input[i]})
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Flush())
+	return out.String()
+}
+
+func TestRenderer(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "plain text",
+			input: "Hello, world.\n",
+			want:  "Hello, world.\n",
+		},
+		{
+			name:  "headings",
+			input: "# Title\n## Section\n### Part\n#hashtag\n",
+			want: bold + magenta + underline + "Title" + reset + "\n" +
+				bold + magenta + "Section" + reset + "\n" +
+				bold + cyan + "Part" + reset + "\n" +
+				"#hashtag\n",
+		},
+		{
+			name:  "lists",
+			input: "- one\n  * two\n3. three\n10) ten\n-not a list\n",
+			want: yellow + "•" + reset + " one\n" +
+				"  " + yellow + "•" + reset + " two\n" +
+				yellow + "3." + reset + " three\n" +
+				yellow + "10)" + reset + " ten\n" +
+				"-not a list\n",
+		},
+		{
+			name:  "quote",
+			input: "> quoted\n",
+			want:  dim + "│" + reset + " " + italic + "quoted" + reset + "\n",
+		},
+		{
+			name:  "rule",
+			input: "---\n",
+			want:  dim + "────────────────────────────────────────" + reset + "\n",
+		},
+		{
+			name:  "emphasis",
+			input: "a **b** *c* ~~d~~ ***e***\n",
+			want: "a " + reset + bold + "b" + reset + " " + reset + italic + "c" + reset + " " +
+				reset + strike + "d" + reset + " " + reset + bold + italic + "e" + reset + reset + "\n",
+		},
+		{
+			name:  "unmatched markers",
+			input: "Answer: 5 * 3 = 15 and 2*4\n",
+			want:  "Answer: 5 * 3 = 15 and 2*4\n",
+		},
+		{
+			name:  "unmatched marker before emphasis",
+			input: "2*4 is **eight**\n",
+			want:  "2*4 is " + reset + bold + "eight" + reset + reset + "\n",
+		},
+		{
+			name:  "emphasis not closed on its line",
+			input: "*one\ntwo*\n",
+			want:  "*one\ntwo*\n",
+		},
+		{
+			name:  "snake case",
+			input: "call snake_case_name and _this_\n",
+			want:  "call snake_case_name and " + reset + italic + "this" + reset + reset + "\n",
+		},
+		{
+			name:  "code span",
+			input: "run `a*b` now\n",
+			want:  "run " + reset + cyan + "a*b" + reset + " now" + reset + "\n",
+		},
+		{
+			name:  "escaped marker",
+			input: `\*not emphasis\*` + "\n",
+			want:  "*not emphasis*\n",
+		},
+		{
+			name:  "multibyte text",
+			input: "café — naïve 日本 _ü_\n",
+			want:  "café — naïve 日本 " + reset + italic + "ü" + reset + reset + "\n",
+		},
+		{
+			name:  "fence",
+			input: "```\nx * y\n```\nafter\n",
+			want: dim + "── code ──" + reset + "\n" +
+				"x * y\n" +
+				dim + "──" + reset + "\n" +
+				"after\n",
+		},
+		{
+			name:  "highlighted fence",
+			input: "```go\nreturn nil // done\n```\n",
+			want: dim + "── go ──" + reset + "\n" +
+				highlight("go", "return nil // done", &highlightState{}) + "\n" +
+				dim + "──" + reset + "\n",
+		},
+		{
+			name:  "unterminated fence",
+			input: "~~~python\nx = 'é'",
+			want: dim + "── python ──" + reset + "\n" +
+				highlight("python", "x = 'é'", &highlightState{}),
+		},
+		{
+			name:  "no trailing newline",
+			input: "ends with *emphasis*",
+			want:  "ends with " + reset + italic + "emphasis" + reset + reset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, render(t, tt.input))
+			assert.Equal(t, tt.want, renderBytes(t, tt.input), "written a byte at a time")
+		})
+	}
+}
+
+func TestRendererHighlighting(t *testing.T) {
+	got := render(t, "```go\nfunc f() string { return \"s\" } // c\n```\n")
+	assert.Contains(t, got, "func")
+	assert.Contains(t, got, "\"s\"")
+	assert.NotEqual(t, dim+"── go ──"+reset+"\nfunc f() string { return \"s\" } // c\n"+dim+"──"+reset+"\n", got,
+		"the code is colored")
+}
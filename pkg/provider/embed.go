@@ -0,0 +1,14 @@
+package provider
+
+import "context"
+
+// Embedder is implemented by providers that can turn text into embedding
+// vectors.
+type Embedder interface {
+	// Embed returns one vector for each input, in the same order.
+	Embed(ctx context.Context, inputs []string, opts *EmbeddingOptions) ([][]float32, error)
+}
+
+type EmbeddingOptions struct {
+	Model string
+}
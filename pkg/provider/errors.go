@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrIncompleteStream is returned when a response stream ends before the
+// backend signalled that generation was finished, e.g. because the server
+// closed the connection mid-answer.
+var ErrIncompleteStream = errors.New("stream ended before the response was complete")
+
+// StreamError is an error reported by the backend inside a response stream,
+// after the HTTP request itself had already succeeded.
+type StreamError struct {
+	Provider string
+	Message  string
+	Type     string
+	Code     string
+}
+
+func (e *StreamError) Error() string {
+	msg := fmt.Sprintf("%s reported an error: %s", e.Provider, e.Message)
+	if e.Code != "" {
+		msg += fmt.Sprintf(" (code %s)", e.Code)
+	}
+	return msg
+}
+
+// MalformedFrameError is returned when a frame of a response stream cannot be
+// decoded according to the provider's protocol.
+type MalformedFrameError struct {
+	Provider string
+	Frame    string
+	Err      error
+}
+
+// maxFrameExcerpt limits how much of a bad frame ends up in error messages.
+const maxFrameExcerpt = 200
+
+// NewMalformedFrameError records a copy of the offending frame, shortened to
+// keep error messages readable.
+func NewMalformedFrameError(providerName string, frame []byte, err error) *MalformedFrameError {
+	excerpt := string(frame)
+	if len(excerpt) > maxFrameExcerpt {
+		excerpt = excerpt[:maxFrameExcerpt] + "..."
+	}
+	return &MalformedFrameError{
+		Provider: providerName,
+		Frame:    excerpt,
+		Err:      err,
+	}
+}
+
+func (e *MalformedFrameError) Error() string {
+	return fmt.Sprintf("malformed %s stream frame %q: %v", e.Provider, e.Frame, e.Err)
+}
+
+func (e *MalformedFrameError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,27 @@
+package localai
+
+import "github.com/ahr9n/ai-cli/pkg/provider"
+
+func init() {
+	provider.Register(provider.Registration{
+		Type:                  provider.LocalAI,
+		Name:                  providerName,
+		Description:           description,
+		DefaultURL:            DefaultURL,
+		DefaultModel:          DefaultModel,
+		DefaultEmbeddingModel: DefaultEmbeddingModel,
+		Capabilities: provider.Capabilities{
+			ListModels: true,
+			Reasoning:  true,
+			APIKey:     true,
+			Images:     true,
+			Embeddings: true,
+		},
+		Examples: []string{
+			`ai-cli localai -p code "Explain binary search"`,
+		},
+		Factory: func(cfg provider.Config) (provider.Provider, error) {
+			return NewClient(cfg), nil
+		},
+	})
+}
@@ -1,38 +1,86 @@
 package ollama
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ahr9n/ai-cli/pkg/api"
-	"github.com/ahr9n/ai-cli/pkg/prompts"
 	"github.com/ahr9n/ai-cli/pkg/provider"
 )
 
+const (
+	providerName = "Ollama"
+	description  = "Run large language models locally"
+
+	DefaultURL   = "http://localhost:11434"
+	DefaultModel = "deepseek-r1:1.5b"
+)
+
 type Client struct {
 	*api.BaseClient
 }
 
+type chatRequest struct {
+	Model    string         `json:"model"`
+	Messages []Message      `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Options  requestOptions `json:"options"`
+}
+
+// generateRequest is used for raw single-shot completions, which have no
+// conversation history and therefore no need for the chat endpoint.
 type generateRequest struct {
-	Model       string    `json:"model"`
-	Prompt      string    `json:"prompt"`
-	Temperature float32   `json:"temperature"`
-	Stream      bool      `json:"stream"`
-	System      string    `json:"system,omitempty"`
-	Messages    []Message `json:"messages,omitempty"`
+	Model   string         `json:"model"`
+	Prompt  string         `json:"prompt"`
+	System  string         `json:"system,omitempty"`
+	Stream  bool           `json:"stream"`
+	Options requestOptions `json:"options"`
+}
+
+// responseFrame is one line of a chat or generate response stream. Chat
+// frames carry Message, generate frames carry Response; the final frame of
+// either sets Done and reports usage.
+type responseFrame struct {
+	Message            Message `json:"message"`
+	Response           string  `json:"response"`
+	Done               bool    `json:"done"`
+	DoneReason         string  `json:"done_reason"`
+	PromptEvalCount    int     `json:"prompt_eval_count"`
+	PromptEvalDuration int64   `json:"prompt_eval_duration"` // nanoseconds
+	EvalCount          int     `json:"eval_count"`
+	EvalDuration       int64   `json:"eval_duration"`
+	TotalDuration      int64   `json:"total_duration"`
+}
+
+type requestOptions struct {
+	Temperature float32 `json:"temperature"`
 }
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role     string `json:"role"`
+	Content  string `json:"content"`
+	Thinking string `json:"thinking,omitempty"`
+	// Images are base64-encoded pictures for vision models.
+	Images []string `json:"images,omitempty"`
 }
 
-type generateResponse struct {
-	Response string `json:"response"`
-	Done     bool   `json:"done"`
+// showRequest asks for the details of a model.
+type showRequest struct {
+	Model string `json:"model"`
+}
+
+// showResponse holds the parts of a model's details that tell its context
+// length: the parameters from its Modelfile, one "name value" pair per line,
+// and metadata keyed like "llama.context_length".
+type showResponse struct {
+	Parameters string         `json:"parameters"`
+	ModelInfo  map[string]any `json:"model_info"`
 }
 
 type modelInfo struct {
@@ -45,99 +93,130 @@ type modelInfo struct {
 	} `json:"details"`
 }
 
-func NewClient(baseURL string) provider.Provider {
+func NewClient(cfg provider.Config) provider.Provider {
 	return &Client{
-		BaseClient: &api.BaseClient{
-			BaseURL: baseURL,
-			HTTPClient: &http.Client{
-				Timeout: 60 * time.Second,
-			},
-		},
+		BaseClient: api.NewBaseClient(cfg.BaseURL, cfg.RequestHeaders()),
 	}
 }
 
-func (c *Client) CreateCompletion(messages []provider.Message, opts *provider.CompletionOptions) (string, error) {
-	var result string
-	err := c.StreamCompletion(messages, opts, func(response string) {
-		result += response
-	})
-	return result, err
+func (c *Client) CreateCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions) (*provider.Completion, error) {
+	var collector provider.Collector
+	err := c.StreamCompletion(ctx, messages, opts, collector.Handle)
+	return collector.Completion(), err
 }
 
-func (c *Client) StreamCompletion(messages []provider.Message, opts *provider.CompletionOptions, onResponse func(string)) error {
+func (c *Client) StreamCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions, onEvent provider.StreamHandler) error {
 	if len(messages) == 0 {
 		return fmt.Errorf("no messages provided")
 	}
 
-	var systemPrompt string
-	var ollamaMessages []Message
-	var userPrompt string
-	for _, msg := range messages {
-		if msg.Role == prompts.RoleSystem {
-			systemPrompt = msg.Content
-		} else {
-			ollamaMessages = append(ollamaMessages, Message{
-				Role:    msg.Role,
-				Content: msg.Content,
-			})
+	ollamaMessages := make([]Message, len(messages))
+	for i, msg := range messages {
+		ollamaMessages[i] = Message{
+			Role:    msg.Role,
+			Content: msg.Content,
 		}
-	}
-	for i := len(messages) - 1; i >= 0; i-- {
-		if messages[i].Role == prompts.RoleUser {
-			userPrompt = messages[i].Content
-			break
+		for _, image := range msg.Images {
+			ollamaMessages[i].Images = append(ollamaMessages[i].Images, image.Base64())
 		}
 	}
 
+	reqBody := chatRequest{
+		Model:    opts.Model,
+		Messages: ollamaMessages,
+		Stream:   true,
+		Options: requestOptions{
+			Temperature: opts.Temperature,
+		},
+	}
+
+	return c.stream(ctx, "api/chat", reqBody, opts.Model, provider.SplitReasoning(onEvent))
+}
+
+// Generate runs a raw single-shot completion against the generate endpoint.
+// It is only for prompts sent on their own; use CreateCompletion for
+// anything that carries conversation history or a system prompt.
+func (c *Client) Generate(ctx context.Context, prompt string, opts *provider.CompletionOptions) (*provider.Completion, error) {
 	reqBody := generateRequest{
-		Model:       opts.Model,
-		Prompt:      userPrompt,
-		System:      systemPrompt,
-		Messages:    ollamaMessages,
-		Temperature: opts.Temperature,
-		Stream:      true,
+		Model:  opts.Model,
+		Prompt: prompt,
+		Stream: true,
+		Options: requestOptions{
+			Temperature: opts.Temperature,
+		},
 	}
 
-	resp, err := c.DoPost("api/generate", reqBody)
+	var collector provider.Collector
+	err := c.stream(ctx, "api/generate", reqBody, opts.Model, provider.SplitReasoning(collector.Handle))
+	return collector.Completion(), err
+}
+
+func (c *Client) stream(ctx context.Context, path string, reqBody interface{}, model string, onEvent provider.StreamHandler) error {
+	resp, err := c.DoPost(ctx, path, reqBody)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
-		return fmt.Errorf("model '%s' not found - try running: ollama pull %s", opts.Model, opts.Model)
+		return fmt.Errorf("model '%s' not found - try running: ollama pull %s", model, model)
 	}
 
 	if err := c.HandleError(resp); err != nil {
 		return err
 	}
 
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
+	decoder := newStreamDecoder(resp.Body)
+	for {
+		var frame responseFrame
+		err := decoder.Next(&frame)
+		if err == io.EOF {
+			err = provider.ErrIncompleteStream
 		}
-
-		var response generateResponse
-		if err := json.Unmarshal(line, &response); err != nil {
-			continue
+		if err != nil {
+			onEvent(provider.StreamEvent{Type: provider.EventError, Err: err})
+			return err
 		}
 
-		if response.Response != "" {
-			onResponse(response.Response)
+		frame.emit(onEvent)
+		if frame.Done {
+			return nil
 		}
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading stream: %w", err)
+func (f *responseFrame) emit(onEvent provider.StreamHandler) {
+	if f.Message.Thinking != "" {
+		onEvent(provider.StreamEvent{Type: provider.EventReasoning, Content: f.Message.Thinking})
 	}
+	if content := f.Message.Content + f.Response; content != "" {
+		onEvent(provider.StreamEvent{Type: provider.EventContent, Content: content})
+	}
+	if !f.Done {
+		return
+	}
+
+	onEvent(provider.StreamEvent{
+		Type: provider.EventUsage,
+		Usage: &provider.Usage{
+			PromptTokens:     f.PromptEvalCount,
+			CompletionTokens: f.EvalCount,
+			TotalTokens:      f.PromptEvalCount + f.EvalCount,
+			PromptDuration:   time.Duration(f.PromptEvalDuration),
+			EvalDuration:     time.Duration(f.EvalDuration),
+			TotalDuration:    time.Duration(f.TotalDuration),
+		},
+	})
 
-	return nil
+	reason := f.DoneReason
+	if reason == "" {
+		reason = provider.FinishStop
+	}
+	onEvent(provider.StreamEvent{Type: provider.EventFinish, FinishReason: reason})
 }
 
-func (c *Client) ListModels() ([]provider.ModelInfo, error) {
-	resp, err := c.DoGet("api/tags")
+func (c *Client) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	resp, err := c.DoGet(ctx, "api/tags")
 	if err != nil {
 		return nil, err
 	}
@@ -167,14 +246,51 @@ func (c *Client) ListModels() ([]provider.ModelInfo, error) {
 	return models, nil
 }
 
+// ContextLength reports the context window of a model: the num_ctx its
+// Modelfile sets, or else the length it was trained with.
+func (c *Client) ContextLength(ctx context.Context, model string) (int, error) {
+	resp, err := c.DoPost(ctx, "api/show", showRequest{Model: model})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, fmt.Errorf("model '%s' not found - try running: ollama pull %s", model, model)
+	}
+	if err := c.HandleError(resp); err != nil {
+		return 0, err
+	}
+
+	var response showResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	for _, line := range strings.Split(response.Parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				return n, nil
+			}
+		}
+	}
+
+	arch, _ := response.ModelInfo["general.architecture"].(string)
+	if length, ok := response.ModelInfo[arch+".context_length"].(float64); ok {
+		return int(length), nil
+	}
+	return 0, nil
+}
+
 func (c *Client) GetDefaultModel() string {
-	return "deepseek-r1:1.5b"
+	return DefaultModel
 }
 
 func (c *Client) Name() string {
-	return "Ollama"
+	return providerName
 }
 
 func (c *Client) Description() string {
-	return "Run large language models locally"
+	return description
 }
@@ -0,0 +1,51 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+// DefaultEmbeddingModel is used by the embed command unless told otherwise.
+const DefaultEmbeddingModel = "nomic-embed-text"
+
+type embedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// Embed embeds inputs with the /api/embed endpoint.
+func (c *Client) Embed(ctx context.Context, inputs []string, opts *provider.EmbeddingOptions) ([][]float32, error) {
+	if opts.Model == "" {
+		return nil, fmt.Errorf("no embedding model given")
+	}
+
+	resp, err := c.DoPost(ctx, "api/embed", embedRequest{Model: opts.Model, Input: inputs})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("model '%s' not found - try running: ollama pull %s", opts.Model, opts.Model)
+	}
+	if err := c.HandleError(resp); err != nil {
+		return nil, err
+	}
+
+	var response embedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(response.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("%s returned %d embeddings for %d inputs", providerName, len(response.Embeddings), len(inputs))
+	}
+	return response.Embeddings, nil
+}
@@ -0,0 +1,28 @@
+package ollama
+
+import "github.com/ahr9n/ai-cli/pkg/provider"
+
+func init() {
+	provider.Register(provider.Registration{
+		Type:                  provider.Ollama,
+		Name:                  providerName,
+		Description:           description,
+		DefaultURL:            DefaultURL,
+		DefaultModel:          DefaultModel,
+		DefaultEmbeddingModel: DefaultEmbeddingModel,
+		URLEnv:                "OLLAMA_HOST",
+		Capabilities: provider.Capabilities{
+			ListModels: true,
+			Reasoning:  true,
+			APIKey:     true,
+			Images:     true,
+			Embeddings: true,
+		},
+		Examples: []string{
+			`ai-cli ollama --model mistral "Write a story"`,
+		},
+		Factory: func(cfg provider.Config) (provider.Provider, error) {
+			return NewClient(cfg), nil
+		},
+	})
+}
@@ -0,0 +1,64 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+// maxFrameSize bounds a single line of the response stream.
+const maxFrameSize = 10 * 1024 * 1024
+
+// errorFrame is what Ollama sends instead of a regular frame when generation
+// fails after the response has started, e.g. {"error": "model runner crashed"}.
+type errorFrame struct {
+	Error string `json:"error"`
+}
+
+// streamDecoder reads Ollama's newline-delimited JSON response stream.
+type streamDecoder struct {
+	scanner *bufio.Scanner
+}
+
+func newStreamDecoder(r io.Reader) *streamDecoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxFrameSize)
+	return &streamDecoder{scanner: scanner}
+}
+
+// Next decodes the next frame into v. Error frames are returned as a
+// *provider.StreamError and undecodable frames as a *provider.MalformedFrameError.
+// Next returns io.EOF once the stream is exhausted.
+func (d *streamDecoder) Next(v interface{}) error {
+	for d.scanner.Scan() {
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var frame errorFrame
+		if err := json.Unmarshal(line, &frame); err != nil {
+			return provider.NewMalformedFrameError(providerName, line, err)
+		}
+		if frame.Error != "" {
+			return &provider.StreamError{
+				Provider: providerName,
+				Message:  frame.Error,
+			}
+		}
+
+		if err := json.Unmarshal(line, v); err != nil {
+			return provider.NewMalformedFrameError(providerName, line, err)
+		}
+		return nil
+	}
+
+	if err := d.scanner.Err(); err != nil {
+		return fmt.Errorf("error reading stream: %w", err)
+	}
+	return io.EOF
+}
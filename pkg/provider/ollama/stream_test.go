@@ -0,0 +1,181 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Recorded response streams of /api/chat.
+const (
+	chatStream = `{"model":"llama3","message":{"role":"assistant","content":"Hel"},"done":false}
+{"model":"llama3","message":{"role":"assistant","content":"lo"},"done":false}
+
+{"model":"llama3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":2,"eval_duration":2000000}
+`
+	thinkingStream = `{"message":{"role":"assistant","content":"","thinking":"Let me see."},"done":false}
+{"message":{"role":"assistant","content":"42"},"done":false}
+{"message":{"role":"assistant","content":""},"done":true,"done_reason":"length"}
+`
+	errorStream = `{"message":{"role":"assistant","content":"Hel"},"done":false}
+{"error":"model runner has unexpectedly stopped"}
+`
+	truncatedStream = `{"message":{"role":"assistant","content":"Hel"},"done":false}
+{"message":{"role":"assistant","content":"lo"},"done":false}
+`
+	malformedStream = `{"message":{"role":"assistant","content":"Hel"},"done":false}
+{"message":{"role":"assistant","content":
+`
+)
+
+func TestStreamCompletion(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantContent   string
+		wantReasoning string
+		wantFinish    string
+		wantUsage     *provider.Usage
+		check         func(t *testing.T, err error)
+	}{
+		{
+			name:        "complete stream",
+			body:        chatStream,
+			wantContent: "Hello",
+			wantFinish:  provider.FinishStop,
+			wantUsage:   &provider.Usage{PromptTokens: 12, CompletionTokens: 2, TotalTokens: 14, EvalDuration: 2000000},
+		},
+		{
+			name:          "thinking field",
+			body:          thinkingStream,
+			wantContent:   "42",
+			wantReasoning: "Let me see.",
+			wantFinish:    "length",
+			wantUsage:     &provider.Usage{},
+		},
+		{
+			name:        "error frame",
+			body:        errorStream,
+			wantContent: "Hel",
+			check: func(t *testing.T, err error) {
+				var streamErr *provider.StreamError
+				require.ErrorAs(t, err, &streamErr)
+				assert.Equal(t, "model runner has unexpectedly stopped", streamErr.Message)
+				assert.Equal(t, providerName, streamErr.Provider)
+			},
+		},
+		{
+			name:        "truncated stream",
+			body:        truncatedStream,
+			wantContent: "Hello",
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, provider.ErrIncompleteStream)
+			},
+		},
+		{
+			name:        "malformed frame",
+			body:        malformedStream,
+			wantContent: "Hel",
+			check: func(t *testing.T, err error) {
+				var frameErr *provider.MalformedFrameError
+				require.ErrorAs(t, err, &frameErr)
+				assert.Equal(t, `{"message":{"role":"assistant","content":`, frameErr.Frame)
+			},
+		},
+		{
+			name: "empty stream",
+			body: "",
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, provider.ErrIncompleteStream)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/chat", r.URL.Path)
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			client := NewClient(provider.Config{BaseURL: server.URL})
+			var (
+				collector provider.Collector
+				errEvents []error
+			)
+			err := client.StreamCompletion(context.Background(), []provider.Message{{Role: "user", Content: "Hi"}},
+				&provider.CompletionOptions{Model: "llama3"}, func(event provider.StreamEvent) {
+					if event.Type == provider.EventError {
+						errEvents = append(errEvents, event.Err)
+					}
+					collector.Handle(event)
+				})
+
+			completion := collector.Completion()
+			assert.Equal(t, tt.wantContent, completion.Content)
+			assert.Equal(t, tt.wantReasoning, completion.Reasoning)
+			if tt.check != nil {
+				require.Error(t, err)
+				tt.check(t, err)
+				assert.Equal(t, []error{err}, errEvents, "the error is also sent as an event")
+				return
+			}
+			require.NoError(t, err)
+			assert.Empty(t, errEvents)
+			assert.Equal(t, tt.wantFinish, completion.FinishReason)
+			assert.Equal(t, tt.wantUsage, completion.Usage)
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/generate", r.URL.Path)
+		var req generateRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "Say hi", req.Prompt)
+		assert.Equal(t, "llama3", req.Model)
+		io.WriteString(w, `{"response":"Hel","done":false}
+{"response":"lo","done":false}
+{"response":"","done":true,"done_reason":"stop","prompt_eval_count":3,"eval_count":2}
+`)
+	}))
+	defer server.Close()
+
+	client := NewClient(provider.Config{BaseURL: server.URL})
+	completion, err := client.(*Client).Generate(context.Background(), "Say hi", &provider.CompletionOptions{Model: "llama3"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", completion.Content)
+	assert.Equal(t, provider.FinishStop, completion.FinishReason)
+	assert.Equal(t, 5, completion.Usage.TotalTokens)
+}
+
+func TestStreamDecoder(t *testing.T) {
+	decoder := newStreamDecoder(strings.NewReader("\n  \n{\"done\":true}\n\n"))
+
+	var frame responseFrame
+	require.NoError(t, decoder.Next(&frame))
+	assert.True(t, frame.Done)
+	assert.Equal(t, io.EOF, decoder.Next(&frame))
+}
+
+func TestStreamDecoderNonObjectFrame(t *testing.T) {
+	decoder := newStreamDecoder(strings.NewReader("[1,2]\n"))
+
+	var frame responseFrame
+	err := decoder.Next(&frame)
+	var frameErr *provider.MalformedFrameError
+	require.True(t, errors.As(err, &frameErr))
+	assert.Equal(t, "[1,2]", frameErr.Frame)
+}
@@ -0,0 +1,409 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/api"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+const (
+	compatibleName        = "OpenAI-compatible"
+	compatibleDescription = "Any server speaking the OpenAI API, such as vLLM, llama.cpp or LM Studio"
+
+	// DefaultBasePath is the path prefix of the API on most OpenAI-compatible
+	// servers.
+	DefaultBasePath = "v1"
+	// DefaultURL is where vLLM listens by default.
+	DefaultURL = "http://localhost:8000"
+)
+
+// Config describes how to reach an OpenAI-compatible server and how the
+// resulting provider presents itself. An empty BasePath defaults to
+// DefaultBasePath; use "/" for servers that mount the API at the root.
+type Config struct {
+	provider.Config
+
+	Name         string
+	Description  string
+	DefaultModel string
+}
+
+// Client talks to any server implementing the OpenAI chat completions API,
+// such as LocalAI, vLLM, the llama.cpp server or LM Studio.
+type Client struct {
+	*api.BaseClient
+	basePath     string
+	name         string
+	description  string
+	defaultModel string
+}
+
+type completionRequest struct {
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Temperature   float32        `json:"temperature"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type Message struct {
+	Role string `json:"role"`
+	// Content is a string, or a list of content parts when the message
+	// carries images.
+	Content any `json:"content"`
+}
+
+// ContentPart is one part of a multi-part message: text or an image.
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL points to an image, here always a data: URL.
+type ImageURL struct {
+	URL string `json:"url"`
+}
+
+// newMessage converts a message to the chat API's form.
+func newMessage(msg provider.Message) Message {
+	if len(msg.Images) == 0 {
+		return Message{Role: msg.Role, Content: msg.Content}
+	}
+	parts := []ContentPart{{Type: "text", Text: msg.Content}}
+	for _, image := range msg.Images {
+		parts = append(parts, ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: image.DataURL()}})
+	}
+	return Message{Role: msg.Role, Content: parts}
+}
+
+type streamingResponse struct {
+	Choices []struct {
+		Delta        messageDelta `json:"delta"`
+		FinishReason *string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *usage `json:"usage"`
+}
+
+type completionResponse struct {
+	Choices []struct {
+		Message      messageDelta `json:"message"`
+		FinishReason *string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *usage `json:"usage"`
+}
+
+type messageDelta struct {
+	Content          string `json:"content"`
+	ReasoningContent string `json:"reasoning_content"`
+}
+
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type modelInfo struct {
+	ID          string `json:"id"`
+	Object      string `json:"object"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+	// Context lengths are not part of the OpenAI API, but vLLM reports
+	// max_model_len and some other servers context_length.
+	MaxModelLen   int `json:"max_model_len"`
+	ContextLength int `json:"context_length"`
+}
+type listModelsResponse struct {
+	Data []modelInfo `json:"data"`
+}
+
+func NewClient(cfg Config) *Client {
+	basePath := cfg.BasePath
+	if basePath == "" {
+		basePath = DefaultBasePath
+	}
+	basePath = strings.Trim(basePath, "/")
+	if basePath != "" {
+		basePath += "/"
+	}
+
+	return &Client{
+		BaseClient:   api.NewBaseClient(strings.TrimRight(cfg.BaseURL, "/"), cfg.RequestHeaders()),
+		basePath:     basePath,
+		name:         cfg.Name,
+		description:  cfg.Description,
+		defaultModel: cfg.DefaultModel,
+	}
+}
+
+// NewCompatibleClient returns the generic provider for self-hosted servers
+// that speak the OpenAI API.
+func NewCompatibleClient(cfg provider.Config) provider.Provider {
+	return NewClient(Config{
+		Config:      cfg,
+		Name:        compatibleName,
+		Description: compatibleDescription,
+	})
+}
+
+func (c *Client) CreateCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions) (*provider.Completion, error) {
+	var collector provider.Collector
+	err := c.StreamCompletion(ctx, messages, opts, collector.Handle)
+	return collector.Completion(), err
+}
+
+func (c *Client) StreamCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions, onEvent provider.StreamHandler) error {
+	if len(messages) == 0 {
+		return fmt.Errorf("no messages provided")
+	}
+
+	model := opts.Model
+	if model == "" {
+		var err error
+		if model, err = c.firstModel(ctx); err != nil {
+			return err
+		}
+	}
+
+	chatMessages := make([]Message, len(messages))
+	for i, msg := range messages {
+		chatMessages[i] = newMessage(msg)
+	}
+
+	reqBody := completionRequest{
+		Model:       model,
+		Messages:    chatMessages,
+		Temperature: opts.Temperature,
+		Stream:      true,
+		StreamOptions: &streamOptions{
+			IncludeUsage: true,
+		},
+	}
+
+	resp, err := c.DoPost(ctx, c.basePath+"chat/completions", reqBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := c.HandleError(resp); err != nil {
+		return err
+	}
+
+	onEvent = provider.SplitReasoning(onEvent)
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		err = readCompletion(c.name, resp.Body, onEvent)
+	} else {
+		err = readStream(c.name, resp.Body, onEvent)
+	}
+	if err != nil {
+		onEvent(provider.StreamEvent{Type: provider.EventError, Err: err})
+	}
+	return err
+}
+
+// readStream emits the events of a streamed chat completion.
+func readStream(name string, r io.Reader, onEvent provider.StreamHandler) error {
+	var finishReason *string
+	completed := false
+	decoder := newSSEDecoder(r)
+	for {
+		event, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if string(bytes.TrimSpace(event.Data)) == doneSentinel {
+			completed = true
+			break
+		}
+		if err := streamError(name, event); err != nil {
+			return err
+		}
+
+		var chunk streamingResponse
+		if err := json.Unmarshal(event.Data, &chunk); err != nil {
+			return provider.NewMalformedFrameError(name, event.Data, err)
+		}
+
+		if len(chunk.Choices) > 0 {
+			choice := chunk.Choices[0]
+			emitDelta(choice.Delta, onEvent)
+			if choice.FinishReason != nil {
+				finishReason = choice.FinishReason
+			}
+		}
+		emitUsage(chunk.Usage, onEvent)
+	}
+
+	if !completed && finishReason == nil {
+		return provider.ErrIncompleteStream
+	}
+
+	// The finish reason arrives before the usage chunk, so it is reported last
+	// to keep EventFinish the final event for every provider.
+	reason := provider.FinishStop
+	if finishReason != nil {
+		reason = *finishReason
+	}
+	onEvent(provider.StreamEvent{Type: provider.EventFinish, FinishReason: reason})
+	return nil
+}
+
+// readCompletion emits the events of a server that ignored the stream flag
+// and answered with a single JSON document.
+func readCompletion(name string, r io.Reader, onEvent provider.StreamHandler) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	event := sseEvent{Data: body}
+	if err := streamError(name, event); err != nil {
+		return err
+	}
+
+	var response completionResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return provider.NewMalformedFrameError(name, body, err)
+	}
+
+	finishReason := provider.FinishStop
+	if len(response.Choices) > 0 {
+		choice := response.Choices[0]
+		emitDelta(choice.Message, onEvent)
+		if choice.FinishReason != nil {
+			finishReason = *choice.FinishReason
+		}
+	}
+	emitUsage(response.Usage, onEvent)
+	onEvent(provider.StreamEvent{Type: provider.EventFinish, FinishReason: finishReason})
+	return nil
+}
+
+func emitDelta(delta messageDelta, onEvent provider.StreamHandler) {
+	if delta.ReasoningContent != "" {
+		onEvent(provider.StreamEvent{Type: provider.EventReasoning, Content: delta.ReasoningContent})
+	}
+	if delta.Content != "" {
+		onEvent(provider.StreamEvent{Type: provider.EventContent, Content: delta.Content})
+	}
+}
+
+func emitUsage(u *usage, onEvent provider.StreamHandler) {
+	if u == nil {
+		return
+	}
+	onEvent(provider.StreamEvent{
+		Type: provider.EventUsage,
+		Usage: &provider.Usage{
+			PromptTokens:     u.PromptTokens,
+			CompletionTokens: u.CompletionTokens,
+			TotalTokens:      u.TotalTokens,
+		},
+	})
+}
+
+func (c *Client) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	data, err := c.listModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]provider.ModelInfo, len(data))
+	for i, m := range data {
+		models[i] = provider.ModelInfo{
+			Name:        m.ID,
+			Family:      m.Object,
+			Description: m.Description,
+		}
+	}
+
+	return models, nil
+}
+
+// ContextLength reports the context window of a model when the server lists
+// it with its models.
+func (c *Client) ContextLength(ctx context.Context, model string) (int, error) {
+	data, err := c.listModels(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range data {
+		if m.ID != model {
+			continue
+		}
+		if m.MaxModelLen > 0 {
+			return m.MaxModelLen, nil
+		}
+		return m.ContextLength, nil
+	}
+	return 0, nil
+}
+
+func (c *Client) listModels(ctx context.Context) ([]modelInfo, error) {
+	resp, err := c.DoGet(ctx, c.basePath+"models")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := c.HandleError(resp); err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var response listModelsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		// Try to parse as array if object parsing fails
+		var altResponse []modelInfo
+		if altErr := json.Unmarshal(body, &altResponse); altErr != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		response.Data = altResponse
+	}
+	return response.Data, nil
+}
+
+// firstModel picks the first model the server lists. Single-model servers
+// such as llama.cpp and LM Studio need no --model flag this way.
+func (c *Client) firstModel(ctx context.Context) (string, error) {
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return "", fmt.Errorf("no model given and the server's models could not be listed: %w", err)
+	}
+	if len(models) == 0 {
+		return "", fmt.Errorf("no model given and the server lists no models")
+	}
+	return models[0].Name, nil
+}
+
+func (c *Client) GetDefaultModel() string {
+	return c.defaultModel
+}
+
+func (c *Client) Name() string {
+	return c.name
+}
+
+func (c *Client) Description() string {
+	return c.description
+}
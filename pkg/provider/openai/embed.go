@@ -0,0 +1,64 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed embeds inputs with the embeddings endpoint. Without a model it uses
+// the first one the server lists, like completions do.
+func (c *Client) Embed(ctx context.Context, inputs []string, opts *provider.EmbeddingOptions) ([][]float32, error) {
+	model := opts.Model
+	if model == "" {
+		var err error
+		if model, err = c.firstModel(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.DoPost(ctx, c.basePath+"embeddings", embeddingRequest{Model: model, Input: inputs})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := c.HandleError(resp); err != nil {
+		return nil, err
+	}
+
+	var response embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Servers may return the vectors in any order; index says whose
+	// they are.
+	vectors := make([][]float32, len(inputs))
+	for _, d := range response.Data {
+		if d.Index < 0 || d.Index >= len(inputs) || vectors[d.Index] != nil {
+			return nil, fmt.Errorf("%s returned an embedding with unexpected index %d", c.name, d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("%s returned no embedding for input %d", c.name, i)
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,29 @@
+package openai
+
+import "github.com/ahr9n/ai-cli/pkg/provider"
+
+func init() {
+	provider.Register(provider.Registration{
+		Type:        provider.OpenAICompatible,
+		Name:        compatibleName,
+		Description: compatibleDescription,
+		DefaultURL:  DefaultURL,
+		APIKeyEnv:   "OPENAI_API_KEY",
+		Capabilities: provider.Capabilities{
+			ListModels: true,
+			Reasoning:  true,
+			APIKey:     true,
+			BasePath:   true,
+			Images:     true,
+			Embeddings: true,
+		},
+		Examples: []string{
+			`ai-cli openai-compatible -u http://localhost:1234 -i  # LM Studio`,
+			`OPENAI_API_KEY=secret ai-cli openai-compatible -u https://gateway.lab --model llama3 "Hello"`,
+			`ai-cli openai-compatible -H "X-Team: ml" --base-path /api/v1 "Hello"`,
+		},
+		Factory: func(cfg provider.Config) (provider.Provider, error) {
+			return NewCompatibleClient(cfg), nil
+		},
+	})
+}
@@ -0,0 +1,132 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+// maxFrameSize bounds a single line of the response stream.
+const maxFrameSize = 10 * 1024 * 1024
+
+// doneSentinel is the data payload OpenAI-compatible servers send as the last
+// event of a successful stream.
+const doneSentinel = "[DONE]"
+
+// sseDecoder reads an OpenAI-style server-sent event stream. Servers that
+// stream bare JSON lines instead of SSE events are accepted as well: every
+// such line is treated as a complete event.
+type sseDecoder struct {
+	scanner *bufio.Scanner
+}
+
+type sseEvent struct {
+	Name string
+	Data []byte
+}
+
+func newSSEDecoder(r io.Reader) *sseDecoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxFrameSize)
+	return &sseDecoder{scanner: scanner}
+}
+
+// Next returns the next event of the stream, or io.EOF once it is exhausted.
+func (d *sseDecoder) Next() (sseEvent, error) {
+	var event sseEvent
+	var data [][]byte
+
+	for d.scanner.Scan() {
+		line := d.scanner.Bytes()
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			if len(data) > 0 {
+				event.Data = bytes.Join(data, []byte("\n"))
+				return event, nil
+			}
+			event = sseEvent{}
+			continue
+		}
+
+		if line[0] == '{' && len(data) == 0 {
+			event.Data = append([]byte(nil), line...)
+			return event, nil
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "":
+			// Comment line, used by some servers as a keep-alive.
+		case "data":
+			data = append(data, append([]byte(nil), value...))
+		case "event":
+			event.Name = string(value)
+		}
+	}
+
+	if err := d.scanner.Err(); err != nil {
+		return sseEvent{}, fmt.Errorf("error reading stream: %w", err)
+	}
+	if len(data) > 0 {
+		event.Data = bytes.Join(data, []byte("\n"))
+		return event, nil
+	}
+	return sseEvent{}, io.EOF
+}
+
+// errorFrame matches the error payloads OpenAI-compatible servers send, either
+// {"error": {"message": "...", "type": "...", "code": ...}} or {"error": "..."}.
+type errorFrame struct {
+	Error json.RawMessage `json:"error"`
+}
+
+type errorBody struct {
+	Message string          `json:"message"`
+	Type    string          `json:"type"`
+	Code    json.RawMessage `json:"code"`
+}
+
+// streamError returns the error carried by event, or nil for a regular event.
+func streamError(name string, event sseEvent) error {
+	var frame errorFrame
+	if err := json.Unmarshal(event.Data, &frame); err != nil {
+		if event.Name == "error" {
+			return &provider.StreamError{Provider: name, Message: string(event.Data)}
+		}
+		return provider.NewMalformedFrameError(name, event.Data, err)
+	}
+
+	raw := bytes.TrimSpace(frame.Error)
+	if len(raw) == 0 || string(raw) == "null" {
+		if event.Name == "error" {
+			return &provider.StreamError{Provider: name, Message: string(event.Data)}
+		}
+		return nil
+	}
+
+	var message string
+	if json.Unmarshal(raw, &message) == nil {
+		return &provider.StreamError{Provider: name, Message: message}
+	}
+
+	var body errorBody
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return provider.NewMalformedFrameError(name, event.Data, err)
+	}
+	code := strings.Trim(string(body.Code), `"`)
+	if code == "null" {
+		code = ""
+	}
+	return &provider.StreamError{
+		Provider: name,
+		Message:  body.Message,
+		Type:     body.Type,
+		Code:     code,
+	}
+}
@@ -0,0 +1,306 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testName = "Test"
+
+// Recorded response streams of /v1/chat/completions.
+const (
+	sseStream = `data: {"id":"1","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]}
+
+data: {"id":"1","choices":[{"index":0,"delta":{"content":"Hel"},"finish_reason":null}]}
+
+: keep-alive
+
+data: {"id":"1","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}
+
+data: {"id":"1","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}
+
+data: [DONE]
+
+`
+	reasoningStream = `data: {"choices":[{"delta":{"reasoning_content":"Think."}}]}
+
+data: {"choices":[{"delta":{"content":"42"},"finish_reason":"length"}]}
+
+data: [DONE]
+`
+	// Some servers split an event's data over several lines.
+	multiLineStream = "data: {\"choices\":[{\"delta\":\ndata: {\"content\":\"Hi\"}}]}\n\ndata: [DONE]\n\n"
+	// Some servers stream bare JSON lines instead of SSE events.
+	bareJSONStream = `{"choices":[{"delta":{"content":"Hel"}}]}
+{"choices":[{"delta":{"content":"lo"},"finish_reason":"stop"}]}
+`
+	// A stream ending with [DONE] but no finish reason is complete.
+	doneOnlyStream = `data: {"choices":[{"delta":{"content":"Hi"}}]}
+
+data: [DONE]
+`
+	// A stream ending with a finish reason but no [DONE] is complete.
+	finishOnlyStream = `data: {"choices":[{"delta":{"content":"Hi"},"finish_reason":"stop"}]}
+`
+	errorObjectStream = `data: {"choices":[{"delta":{"content":"Hel"}}]}
+
+data: {"error":{"message":"out of memory","type":"server_error","code":"oom"}}
+
+`
+	errorStringStream = `data: {"error":"backend crashed"}
+
+`
+	errorNumericCodeStream = `data: {"error":{"message":"rate limited","code":429}}
+
+`
+	errorEventStream = `data: {"choices":[{"delta":{"content":"Hel"}}]}
+
+event: error
+data: upstream connection reset
+
+`
+	errorEventJSONStream = `event: error
+data: {"detail":"overloaded"}
+
+`
+	truncatedStream = `data: {"choices":[{"delta":{"content":"Hel"}}]}
+
+data: {"choices":[{"delta":{"content":"lo"}}]}
+
+`
+	malformedStream = `data: {"choices":[{"delta":{"content":"Hel"}}]}
+
+data: {"choices":[{"delta":
+
+`
+)
+
+func TestReadStream(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantContent   string
+		wantReasoning string
+		wantFinish    string
+		wantUsage     *provider.Usage
+		wantErr       error
+		wantStreamErr *provider.StreamError
+		wantMalformed bool
+	}{
+		{
+			name:        "complete stream",
+			body:        sseStream,
+			wantContent: "Hello",
+			wantFinish:  provider.FinishStop,
+			wantUsage:   &provider.Usage{PromptTokens: 9, CompletionTokens: 2, TotalTokens: 11},
+		},
+		{
+			name:          "reasoning content",
+			body:          reasoningStream,
+			wantContent:   "42",
+			wantReasoning: "Think.",
+			wantFinish:    "length",
+		},
+		{
+			name:        "multi-line data",
+			body:        multiLineStream,
+			wantContent: "Hi",
+			wantFinish:  provider.FinishStop,
+		},
+		{
+			name:        "bare JSON lines",
+			body:        bareJSONStream,
+			wantContent: "Hello",
+			wantFinish:  provider.FinishStop,
+		},
+		{
+			name:        "done without finish reason",
+			body:        doneOnlyStream,
+			wantContent: "Hi",
+			wantFinish:  provider.FinishStop,
+		},
+		{
+			name:        "finish reason without done",
+			body:        finishOnlyStream,
+			wantContent: "Hi",
+			wantFinish:  provider.FinishStop,
+		},
+		{
+			name:          "error object",
+			body:          errorObjectStream,
+			wantContent:   "Hel",
+			wantStreamErr: &provider.StreamError{Provider: testName, Message: "out of memory", Type: "server_error", Code: "oom"},
+		},
+		{
+			name:          "error string",
+			body:          errorStringStream,
+			wantStreamErr: &provider.StreamError{Provider: testName, Message: "backend crashed"},
+		},
+		{
+			name:          "error with numeric code",
+			body:          errorNumericCodeStream,
+			wantStreamErr: &provider.StreamError{Provider: testName, Message: "rate limited", Code: "429"},
+		},
+		{
+			name:          "error event with text",
+			body:          errorEventStream,
+			wantContent:   "Hel",
+			wantStreamErr: &provider.StreamError{Provider: testName, Message: "upstream connection reset"},
+		},
+		{
+			name:          "error event with JSON",
+			body:          errorEventJSONStream,
+			wantStreamErr: &provider.StreamError{Provider: testName, Message: `{"detail":"overloaded"}`},
+		},
+		{
+			name:        "truncated stream",
+			body:        truncatedStream,
+			wantContent: "Hello",
+			wantErr:     provider.ErrIncompleteStream,
+		},
+		{
+			name:    "empty stream",
+			body:    "",
+			wantErr: provider.ErrIncompleteStream,
+		},
+		{
+			name:          "malformed frame",
+			body:          malformedStream,
+			wantContent:   "Hel",
+			wantMalformed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var collector provider.Collector
+			err := readStream(testName, strings.NewReader(tt.body), provider.SplitReasoning(collector.Handle))
+			completion := collector.Completion()
+
+			assert.Equal(t, tt.wantContent, completion.Content)
+			assert.Equal(t, tt.wantReasoning, completion.Reasoning)
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.wantStreamErr != nil:
+				var streamErr *provider.StreamError
+				require.ErrorAs(t, err, &streamErr)
+				assert.Equal(t, tt.wantStreamErr, streamErr)
+			case tt.wantMalformed:
+				var frameErr *provider.MalformedFrameError
+				require.ErrorAs(t, err, &frameErr)
+				assert.Equal(t, testName, frameErr.Provider)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantFinish, completion.FinishReason)
+				assert.Equal(t, tt.wantUsage, completion.Usage)
+			}
+		})
+	}
+}
+
+func TestSSEDecoder(t *testing.T) {
+	stream := ": comment\nevent: message\ndata: one\ndata: two\n\n" +
+		"data:three\n\n" +
+		"{\"bare\":true}\n" +
+		"data: unterminated"
+	decoder := newSSEDecoder(strings.NewReader(stream))
+
+	want := []sseEvent{
+		{Name: "message", Data: []byte("one\ntwo")},
+		{Data: []byte("three")},
+		{Data: []byte(`{"bare":true}`)},
+		{Data: []byte("unterminated")},
+	}
+	for _, w := range want {
+		event, err := decoder.Next()
+		require.NoError(t, err)
+		assert.Equal(t, w.Name, event.Name)
+		assert.Equal(t, string(w.Data), string(event.Data))
+	}
+	_, err := decoder.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestStreamCompletion(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantContent string
+		wantFinish  string
+		wantErr     string
+	}{
+		{
+			name:        "event stream",
+			contentType: "text/event-stream",
+			body:        sseStream,
+			wantContent: "Hello",
+			wantFinish:  provider.FinishStop,
+		},
+		{
+			// A server ignoring "stream": true answers with one document.
+			name:        "JSON document",
+			contentType: "application/json",
+			body:        `{"choices":[{"message":{"role":"assistant","content":"Hello"},"finish_reason":"length"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`,
+			wantContent: "Hello",
+			wantFinish:  "length",
+		},
+		{
+			name:        "JSON error document",
+			contentType: "application/json",
+			body:        `{"error":{"message":"model not loaded"}}`,
+			wantErr:     "Test reported an error: model not loaded",
+		},
+		{
+			name:        "truncated event stream",
+			contentType: "text/event-stream",
+			body:        truncatedStream,
+			wantContent: "Hello",
+			wantErr:     provider.ErrIncompleteStream.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+				w.Header().Set("Content-Type", tt.contentType)
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			client := NewClient(Config{Config: provider.Config{BaseURL: server.URL}, Name: testName})
+			var (
+				collector provider.Collector
+				errEvents int
+			)
+			err := client.StreamCompletion(context.Background(), []provider.Message{{Role: "user", Content: "Hi"}},
+				&provider.CompletionOptions{Model: "m"}, func(event provider.StreamEvent) {
+					if event.Type == provider.EventError {
+						errEvents++
+					}
+					collector.Handle(event)
+				})
+
+			completion := collector.Completion()
+			assert.Equal(t, tt.wantContent, completion.Content)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				assert.Equal(t, 1, errEvents)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 0, errEvents)
+			assert.Equal(t, tt.wantFinish, completion.FinishReason)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+// infoTimeout bounds the info request made to fill in the plugin's metadata.
+const infoTimeout = 5 * time.Second
+
+// Client is a provider backed by a plugin executable. The plugin is started
+// for every request, so no state is kept between calls.
+type Client struct {
+	name string
+	path string
+	cfg  provider.Config
+
+	infoOnce sync.Once
+	info     Result
+}
+
+// NewClient returns a provider that runs the plugin at path. The connection
+// settings in cfg are passed to the plugin through its environment.
+func NewClient(name, path string, cfg provider.Config) *Client {
+	return &Client{
+		name: name,
+		path: path,
+		cfg:  cfg,
+	}
+}
+
+func (c *Client) CreateCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions) (*provider.Completion, error) {
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("no messages provided")
+	}
+
+	var completion *provider.Completion
+	err := c.run(ctx, newRequest(MethodComplete, messages, opts), func(resp Response) (bool, error) {
+		if resp.Type != TypeResult || resp.Result == nil {
+			return false, nil
+		}
+		completion = &provider.Completion{
+			Content:      resp.Result.Content,
+			Reasoning:    resp.Result.Reasoning,
+			FinishReason: resp.Result.FinishReason,
+			Usage:        toProviderUsage(resp.Result.Usage),
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return completion, nil
+}
+
+func (c *Client) StreamCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions, onEvent provider.StreamHandler) error {
+	if len(messages) == 0 {
+		return fmt.Errorf("no messages provided")
+	}
+
+	onEvent = provider.SplitReasoning(onEvent)
+	err := c.run(ctx, newRequest(MethodStream, messages, opts), func(resp Response) (bool, error) {
+		switch resp.Type {
+		case TypeDelta:
+			onEvent(provider.StreamEvent{Type: provider.EventContent, Content: resp.Content})
+		case TypeReasoning:
+			onEvent(provider.StreamEvent{Type: provider.EventReasoning, Content: resp.Content})
+		case TypeUsage:
+			onEvent(provider.StreamEvent{Type: provider.EventUsage, Usage: toProviderUsage(resp.Usage)})
+		case TypeDone:
+			reason := resp.FinishReason
+			if reason == "" {
+				reason = provider.FinishStop
+			}
+			onEvent(provider.StreamEvent{Type: provider.EventFinish, FinishReason: reason})
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		onEvent(provider.StreamEvent{Type: provider.EventError, Err: err})
+	}
+	return err
+}
+
+func (c *Client) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	var models []provider.ModelInfo
+	err := c.run(ctx, Request{ID: 1, Method: MethodListModels}, func(resp Response) (bool, error) {
+		if resp.Type != TypeResult || resp.Result == nil {
+			return false, nil
+		}
+		for _, m := range resp.Result.Models {
+			models = append(models, provider.ModelInfo{
+				Name:        m.Name,
+				Size:        m.Size,
+				Modified:    m.Modified,
+				Family:      m.Family,
+				Description: m.Description,
+			})
+		}
+		return true, nil
+	})
+	return models, err
+}
+
+// Info asks the plugin to describe itself.
+func (c *Client) Info(ctx context.Context) (Result, error) {
+	var info Result
+	err := c.run(ctx, Request{ID: 1, Method: MethodInfo}, func(resp Response) (bool, error) {
+		if resp.Type != TypeResult || resp.Result == nil {
+			return false, nil
+		}
+		info = *resp.Result
+		return true, nil
+	})
+	return info, err
+}
+
+// cachedInfo fetches the plugin's metadata once. Failures are not fatal: the
+// plugin simply has no description or default model then.
+func (c *Client) cachedInfo() Result {
+	c.infoOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), infoTimeout)
+		defer cancel()
+		c.info, _ = c.Info(ctx)
+	})
+	return c.info
+}
+
+func (c *Client) GetDefaultModel() string {
+	return c.cachedInfo().DefaultModel
+}
+
+func (c *Client) Name() string {
+	if name := c.cachedInfo().Name; name != "" {
+		return name
+	}
+	return c.name
+}
+
+func (c *Client) Description() string {
+	if description := c.cachedInfo().Description; description != "" {
+		return description
+	}
+	return fmt.Sprintf("Provider plugin at %s", c.path)
+}
+
+func newRequest(method string, messages []provider.Message, opts *provider.CompletionOptions) Request {
+	params := &RequestParams{
+		Messages:    make([]Message, len(messages)),
+		Model:       opts.Model,
+		Temperature: opts.Temperature,
+	}
+	for i, msg := range messages {
+		params.Messages[i] = Message{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+	}
+	return Request{ID: 1, Method: method, Params: params}
+}
@@ -0,0 +1,124 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+// checkTimeout bounds every conformance check.
+const checkTimeout = 30 * time.Second
+
+// CheckResult is the outcome of a single conformance check. Err is nil when
+// the plugin passed.
+type CheckResult struct {
+	Name string
+	Err  error
+}
+
+type conformanceCheck struct {
+	name string
+	run  func(ctx context.Context, c *Client) error
+}
+
+var conformanceChecks = []conformanceCheck{
+	{"info reports the protocol version and a name", checkInfo},
+	{"list_models answers with a result", checkListModels},
+	{"complete answers with a finished result", checkComplete},
+	{"stream sends deltas and ends with done", checkStream},
+	{"unknown methods are answered with an error", checkUnknownMethod},
+}
+
+// Conformance runs the protocol conformance checks against the plugin at
+// path. Plugin authors can run them with "ai-cli plugins check".
+func Conformance(ctx context.Context, path string) []CheckResult {
+	c := NewClient(filepath.Base(path), path, provider.Config{})
+
+	results := make([]CheckResult, len(conformanceChecks))
+	for i, check := range conformanceChecks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		results[i] = CheckResult{Name: check.name, Err: check.run(checkCtx, c)}
+		cancel()
+	}
+	return results
+}
+
+func checkInfo(ctx context.Context, c *Client) error {
+	info, err := c.Info(ctx)
+	if err != nil {
+		return err
+	}
+	if info.ProtocolVersion != ProtocolVersion {
+		return fmt.Errorf("protocol_version is %d, want %d", info.ProtocolVersion, ProtocolVersion)
+	}
+	if info.Name == "" {
+		return errors.New("name is empty")
+	}
+	return nil
+}
+
+func checkListModels(ctx context.Context, c *Client) error {
+	_, err := c.ListModels(ctx)
+	return err
+}
+
+func checkComplete(ctx context.Context, c *Client) error {
+	completion, err := c.CreateCompletion(ctx, conformancePrompt(), &provider.CompletionOptions{})
+	if err != nil {
+		return err
+	}
+	if completion.FinishReason == "" {
+		return errors.New("result has no finish_reason")
+	}
+	return nil
+}
+
+func checkStream(ctx context.Context, c *Client) error {
+	deltas := 0
+	err := c.run(ctx, newRequest(MethodStream, conformancePrompt(), &provider.CompletionOptions{}), func(resp Response) (bool, error) {
+		switch resp.Type {
+		case TypeDelta:
+			deltas++
+		case TypeReasoning, TypeUsage:
+		case TypeDone:
+			if resp.FinishReason == "" {
+				return true, errors.New("done response has no finish_reason")
+			}
+			return true, nil
+		default:
+			return true, fmt.Errorf("unexpected response type %q in a stream", resp.Type)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+	if deltas == 0 {
+		return errors.New("stream finished without any delta")
+	}
+	return nil
+}
+
+func checkUnknownMethod(ctx context.Context, c *Client) error {
+	err := c.run(ctx, Request{ID: 42, Method: "no_such_method"}, func(resp Response) (bool, error) {
+		return true, fmt.Errorf("got a %q response instead of an error", resp.Type)
+	})
+
+	var streamErr *provider.StreamError
+	if errors.As(err, &streamErr) {
+		return nil
+	}
+	if err == nil {
+		return errors.New("plugin did not answer")
+	}
+	return err
+}
+
+func conformancePrompt() []provider.Message {
+	return []provider.Message{{Role: prompts.RoleUser, Content: "Reply with a short greeting."}}
+}
@@ -0,0 +1,47 @@
+package plugin
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildEcho compiles the reference plugin into a temporary directory.
+func buildEcho(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds the echo plugin")
+	}
+	goTool := filepath.Join(runtime.GOROOT(), "bin", "go")
+	path := filepath.Join(t.TempDir(), ExecutablePrefix+"echo")
+	if runtime.GOOS == "windows" {
+		path += ".exe"
+	}
+	cmd := exec.Command(goTool, "build", "-o", path, "../../../cmd/ai-cli-provider-echo")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "building the echo plugin: %s", out)
+	return path
+}
+
+func TestConformanceEcho(t *testing.T) {
+	path := buildEcho(t)
+
+	results := Conformance(context.Background(), path)
+	require.Len(t, results, len(conformanceChecks))
+	for _, result := range results {
+		assert.NoError(t, result.Err, result.Name)
+	}
+}
+
+func TestConformanceFailsForBrokenPlugin(t *testing.T) {
+	path := writePlugin(t, `read line; echo '{"id":1,"type":"result","result":{"protocol_version":99}}'`)
+
+	results := Conformance(context.Background(), path)
+	require.Len(t, results, len(conformanceChecks))
+	assert.EqualError(t, results[0].Err, "protocol_version is 99, want 1")
+}
@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+// Discover finds plugin executables on PATH and returns their paths keyed by
+// provider name. When several PATH entries contain the same plugin, the first
+// one wins, as it would for the shell.
+func Discover() map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok {
+				continue
+			}
+			if _, seen := plugins[name]; seen {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if isExecutable(path) {
+				plugins[name] = path
+			}
+		}
+	}
+	return plugins
+}
+
+func pluginName(file string) (string, bool) {
+	if !strings.HasPrefix(file, ExecutablePrefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(file, ExecutablePrefix)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, ".exe")
+	}
+	return name, name != ""
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	return info.Mode()&0111 != 0
+}
+
+// Register makes the plugin at path available as the provider name. It does
+// nothing if a provider of that name is already registered, so built-in
+// providers cannot be shadowed by plugins.
+func Register(name, path string) {
+	providerType := provider.ProviderType(name)
+	if _, exists := provider.Lookup(providerType); exists {
+		return
+	}
+
+	provider.Register(provider.Registration{
+		Type:        providerType,
+		Name:        name,
+		Description: "Provider plugin at " + path,
+		Capabilities: provider.Capabilities{
+			ListModels: true,
+			Reasoning:  true,
+			APIKey:     true,
+		},
+		Factory: func(cfg provider.Config) (provider.Provider, error) {
+			return NewClient(name, path, cfg), nil
+		},
+	})
+}
+
+// RegisterDiscovered registers every plugin found on PATH.
+func RegisterDiscovered() {
+	for name, path := range Discover() {
+		Register(name, path)
+	}
+}
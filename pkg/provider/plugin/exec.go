@@ -0,0 +1,141 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+const (
+	// maxLineSize bounds a single response line.
+	maxLineSize = 10 * 1024 * 1024
+	// exitGrace is how long a plugin may keep running after it answered.
+	exitGrace = 2 * time.Second
+)
+
+// run starts the plugin, sends it req and passes every response to onResponse
+// until onResponse reports that the request is finished. The plugin's stderr
+// is passed through so that plugins can log.
+func (c *Client) run(ctx context.Context, req Request, onResponse func(Response) (bool, error)) error {
+	name := c.name
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode plugin request: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, c.path)
+	cmd.Env = append(os.Environ(), c.environment()...)
+	cmd.Stdin = bytes.NewReader(append(payload, '\n'))
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to connect to plugin %s: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", name, err)
+	}
+
+	finished := false
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for !finished && scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var resp Response
+		if err := json.Unmarshal(line, &resp); err != nil {
+			stop(cmd)
+			return provider.NewMalformedFrameError(name, line, err)
+		}
+		if resp.ID != req.ID {
+			stop(cmd)
+			return provider.NewMalformedFrameError(name, line, fmt.Errorf("response id %d does not match request id %d", resp.ID, req.ID))
+		}
+		if resp.Type == TypeError {
+			stop(cmd)
+			return responseError(name, resp)
+		}
+
+		if finished, err = onResponse(resp); err != nil {
+			stop(cmd)
+			return err
+		}
+	}
+	scanErr := scanner.Err()
+
+	waitErr := wait(cmd)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if scanErr != nil {
+		return fmt.Errorf("error reading from plugin %s: %w", name, scanErr)
+	}
+	if !finished {
+		if waitErr != nil {
+			return fmt.Errorf("plugin %s exited before answering: %w", name, waitErr)
+		}
+		return provider.ErrIncompleteStream
+	}
+	return nil
+}
+
+// environment passes the connection settings given to ai-cli on to the
+// plugin.
+func (c *Client) environment() []string {
+	var env []string
+	if c.cfg.BaseURL != "" {
+		env = append(env, "AI_CLI_PROVIDER_URL="+c.cfg.BaseURL)
+	}
+	if c.cfg.APIKey != "" {
+		env = append(env, "AI_CLI_PROVIDER_API_KEY="+c.cfg.APIKey)
+	}
+	for key, value := range c.cfg.Headers {
+		env = append(env, "AI_CLI_PROVIDER_HEADER_"+headerEnvName(key)+"="+value)
+	}
+	return env
+}
+
+// headerEnvName turns a header name like X-Team-Id into X_TEAM_ID.
+func headerEnvName(header string) string {
+	return strings.ToUpper(strings.ReplaceAll(header, "-", "_"))
+}
+
+func responseError(name string, resp Response) error {
+	err := &provider.StreamError{Provider: name, Message: "unknown error"}
+	if resp.Error != nil {
+		err.Message = resp.Error.Message
+		err.Code = resp.Error.Code
+	}
+	return err
+}
+
+// wait gives the plugin exitGrace to exit on its own before killing it.
+func wait(cmd *exec.Cmd) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(exitGrace):
+		_ = cmd.Process.Kill()
+		return <-done
+	}
+}
+
+func stop(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+}
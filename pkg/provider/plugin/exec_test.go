@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePlugin writes a shell script acting as a plugin.
+func writePlugin(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+	path := filepath.Join(t.TempDir(), ExecutablePrefix+"test")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	return path
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		check   func(t *testing.T, err error)
+		content string
+	}{
+		{
+			name: "stream",
+			script: `read line
+echo '{"id":7,"type":"delta","content":"Hel"}'
+echo ''
+echo '{"id":7,"type":"delta","content":"lo"}'
+echo '{"id":7,"type":"done","finish_reason":"stop"}'`,
+			content: "Hello",
+		},
+		{
+			name: "mismatched id",
+			script: `read line
+echo '{"id":8,"type":"delta","content":"Hel"}'`,
+			check: func(t *testing.T, err error) {
+				var frameErr *provider.MalformedFrameError
+				require.ErrorAs(t, err, &frameErr)
+				assert.EqualError(t, frameErr.Err, "response id 8 does not match request id 7")
+			},
+		},
+		{
+			name: "error response",
+			script: `read line
+echo '{"id":7,"type":"delta","content":"Hel"}'
+echo '{"id":7,"type":"error","error":{"message":"model crashed","code":"crash"}}'`,
+			content: "Hel",
+			check: func(t *testing.T, err error) {
+				var streamErr *provider.StreamError
+				require.ErrorAs(t, err, &streamErr)
+				assert.Equal(t, &provider.StreamError{Provider: "test", Message: "model crashed", Code: "crash"}, streamErr)
+			},
+		},
+		{
+			name: "error response without payload",
+			script: `read line
+echo '{"id":7,"type":"error"}'`,
+			check: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "test reported an error: unknown error")
+			},
+		},
+		{
+			name: "malformed response",
+			script: `read line
+echo 'not json'`,
+			check: func(t *testing.T, err error) {
+				var frameErr *provider.MalformedFrameError
+				require.ErrorAs(t, err, &frameErr)
+				assert.Equal(t, "not json", frameErr.Frame)
+			},
+		},
+		{
+			name: "exits early with a failure",
+			script: `read line
+echo '{"id":7,"type":"delta","content":"Hel"}'
+exit 3`,
+			content: "Hel",
+			check: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "plugin test exited before answering: exit status 3")
+			},
+		},
+		{
+			name: "exits early without a failure",
+			script: `read line
+echo '{"id":7,"type":"delta","content":"Hel"}'`,
+			content: "Hel",
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, provider.ErrIncompleteStream)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient("test", writePlugin(t, tt.script), provider.Config{})
+			content := ""
+			err := c.run(context.Background(), Request{ID: 7, Method: MethodStream}, func(resp Response) (bool, error) {
+				content += resp.Content
+				return resp.Type == TypeDone, nil
+			})
+
+			assert.Equal(t, tt.content, content)
+			if tt.check == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			tt.check(t, err)
+		})
+	}
+}
+
+func TestRunPassesSettings(t *testing.T) {
+	path := writePlugin(t, `read line
+echo "{\"id\":1,\"type\":\"result\",\"result\":{\"name\":\"$AI_CLI_PROVIDER_URL $AI_CLI_PROVIDER_API_KEY $AI_CLI_PROVIDER_HEADER_X_TEAM_ID\"}}"`)
+	c := NewClient("test", path, provider.Config{
+		BaseURL: "http://host",
+		APIKey:  "key",
+		Headers: map[string]string{"X-Team-Id": "7"},
+	})
+
+	info, err := c.Info(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "http://host key 7", info.Name)
+}
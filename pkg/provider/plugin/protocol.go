@@ -0,0 +1,127 @@
+// Package plugin runs providers as external programs that speak a
+// line-delimited JSON protocol over stdin and stdout. See
+// docs/plugin-protocol.md for the full description of the protocol.
+package plugin
+
+import (
+	"time"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+// ProtocolVersion is the version of the protocol described in this package.
+const ProtocolVersion = 1
+
+// ExecutablePrefix is the prefix of plugin executables found on PATH: the
+// program ai-cli-provider-foo provides the "foo" provider.
+const ExecutablePrefix = "ai-cli-provider-"
+
+// Methods a plugin must implement.
+const (
+	MethodInfo       = "info"
+	MethodListModels = "list_models"
+	MethodComplete   = "complete"
+	MethodStream     = "stream"
+)
+
+// Response types written by plugins.
+const (
+	TypeResult    = "result"
+	TypeDelta     = "delta"
+	TypeReasoning = "reasoning"
+	TypeUsage     = "usage"
+	TypeDone      = "done"
+	TypeError     = "error"
+)
+
+// Request is a single line sent to a plugin's stdin.
+type Request struct {
+	ID     int            `json:"id"`
+	Method string         `json:"method"`
+	Params *RequestParams `json:"params,omitempty"`
+}
+
+// RequestParams carries the arguments of complete and stream requests.
+type RequestParams struct {
+	Messages    []Message `json:"messages"`
+	Model       string    `json:"model,omitempty"`
+	Temperature float32   `json:"temperature"`
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Response is a single line written to a plugin's stdout. Every response
+// echoes the ID of the request it answers. A request is finished by exactly
+// one response of type result, done or error.
+type Response struct {
+	ID           int           `json:"id"`
+	Type         string        `json:"type"`
+	Content      string        `json:"content,omitempty"`
+	Usage        *Usage        `json:"usage,omitempty"`
+	FinishReason string        `json:"finish_reason,omitempty"`
+	Result       *Result       `json:"result,omitempty"`
+	Error        *ErrorPayload `json:"error,omitempty"`
+}
+
+// Result is the payload of a result response. Which fields are set depends
+// on the method: info sets the plugin fields, list_models sets Models and
+// complete sets the completion fields.
+type Result struct {
+	ProtocolVersion int         `json:"protocol_version,omitempty"`
+	Name            string      `json:"name,omitempty"`
+	Description     string      `json:"description,omitempty"`
+	DefaultModel    string      `json:"default_model,omitempty"`
+	Models          []ModelInfo `json:"models,omitempty"`
+	Content         string      `json:"content,omitempty"`
+	Reasoning       string      `json:"reasoning,omitempty"`
+	FinishReason    string      `json:"finish_reason,omitempty"`
+	Usage           *Usage      `json:"usage,omitempty"`
+}
+
+type ModelInfo struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size,omitempty"`
+	Modified    string `json:"modified,omitempty"`
+	Family      string `json:"family,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Usage mirrors provider.Usage with durations in milliseconds.
+type Usage struct {
+	PromptTokens     int   `json:"prompt_tokens"`
+	CompletionTokens int   `json:"completion_tokens"`
+	TotalTokens      int   `json:"total_tokens"`
+	DurationMS       int64 `json:"duration_ms,omitempty"`
+}
+
+type ErrorPayload struct {
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
+}
+
+func toProviderUsage(u *Usage) *provider.Usage {
+	if u == nil {
+		return nil
+	}
+	return &provider.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		TotalDuration:    time.Duration(u.DurationMS) * time.Millisecond,
+	}
+}
+
+func fromProviderUsage(u *provider.Usage) *Usage {
+	if u == nil {
+		return nil
+	}
+	return &Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		DurationMS:       u.TotalDuration.Milliseconds(),
+	}
+}
@@ -0,0 +1,152 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+// Serve answers the requests read from r with p and writes the responses to w
+// until r is exhausted. It lets any provider.Provider implementation be
+// shipped as a plugin.
+func Serve(ctx context.Context, p provider.Provider, r io.Reader, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var req Request
+		if err := json.Unmarshal(line, &req); err != nil {
+			if err := encoder.Encode(errorResponse(0, "invalid_request", err)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := serveRequest(ctx, p, req, encoder); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Main serves p on stdin and stdout. Plugins written in Go can use it as
+// their entire main function.
+func Main(p provider.Provider) {
+	if err := Serve(context.Background(), p, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", p.Name(), err)
+		os.Exit(1)
+	}
+}
+
+// serveRequest writes the responses to req. Only failures to write are
+// returned; provider errors are reported to the host as error responses.
+func serveRequest(ctx context.Context, p provider.Provider, req Request, encoder *json.Encoder) error {
+	switch req.Method {
+	case MethodInfo:
+		return encoder.Encode(Response{ID: req.ID, Type: TypeResult, Result: &Result{
+			ProtocolVersion: ProtocolVersion,
+			Name:            p.Name(),
+			Description:     p.Description(),
+			DefaultModel:    p.GetDefaultModel(),
+		}})
+
+	case MethodListModels:
+		models, err := p.ListModels(ctx)
+		if err != nil {
+			return encoder.Encode(errorResponse(req.ID, "", err))
+		}
+		result := &Result{Models: make([]ModelInfo, len(models))}
+		for i, m := range models {
+			result.Models[i] = ModelInfo{
+				Name:        m.Name,
+				Size:        m.Size,
+				Modified:    m.Modified,
+				Family:      m.Family,
+				Description: m.Description,
+			}
+		}
+		return encoder.Encode(Response{ID: req.ID, Type: TypeResult, Result: result})
+
+	case MethodComplete, MethodStream:
+		if req.Params == nil {
+			return encoder.Encode(errorResponse(req.ID, "invalid_request", fmt.Errorf("missing params")))
+		}
+		messages := make([]provider.Message, len(req.Params.Messages))
+		for i, msg := range req.Params.Messages {
+			messages[i] = provider.Message{Role: msg.Role, Content: msg.Content}
+		}
+		opts := &provider.CompletionOptions{
+			Model:       req.Params.Model,
+			Temperature: req.Params.Temperature,
+		}
+		if opts.Model == "" {
+			opts.Model = p.GetDefaultModel()
+		}
+
+		if req.Method == MethodComplete {
+			return serveComplete(ctx, p, req.ID, messages, opts, encoder)
+		}
+		return serveStream(ctx, p, req.ID, messages, opts, encoder)
+
+	default:
+		return encoder.Encode(errorResponse(req.ID, "unknown_method", fmt.Errorf("unknown method %q", req.Method)))
+	}
+}
+
+func serveComplete(ctx context.Context, p provider.Provider, id int, messages []provider.Message, opts *provider.CompletionOptions, encoder *json.Encoder) error {
+	completion, err := p.CreateCompletion(ctx, messages, opts)
+	if err != nil {
+		return encoder.Encode(errorResponse(id, "", err))
+	}
+	return encoder.Encode(Response{ID: id, Type: TypeResult, Result: &Result{
+		Content:      completion.Content,
+		Reasoning:    completion.Reasoning,
+		FinishReason: completion.FinishReason,
+		Usage:        fromProviderUsage(completion.Usage),
+	}})
+}
+
+func serveStream(ctx context.Context, p provider.Provider, id int, messages []provider.Message, opts *provider.CompletionOptions, encoder *json.Encoder) error {
+	var writeErr error
+	write := func(resp Response) {
+		if writeErr == nil {
+			writeErr = encoder.Encode(resp)
+		}
+	}
+
+	finishReason := provider.FinishStop
+	err := p.StreamCompletion(ctx, messages, opts, func(event provider.StreamEvent) {
+		switch event.Type {
+		case provider.EventContent:
+			write(Response{ID: id, Type: TypeDelta, Content: event.Content})
+		case provider.EventReasoning:
+			write(Response{ID: id, Type: TypeReasoning, Content: event.Content})
+		case provider.EventUsage:
+			write(Response{ID: id, Type: TypeUsage, Usage: fromProviderUsage(event.Usage)})
+		case provider.EventFinish:
+			finishReason = event.FinishReason
+		}
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	if err != nil {
+		return encoder.Encode(errorResponse(id, "", err))
+	}
+	return encoder.Encode(Response{ID: id, Type: TypeDone, FinishReason: finishReason})
+}
+
+func errorResponse(id int, code string, err error) Response {
+	return Response{ID: id, Type: TypeError, Error: &ErrorPayload{Message: err.Error(), Code: code}}
+}
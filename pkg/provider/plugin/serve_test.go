@@ -0,0 +1,168 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider answers with fixed content, or fails with err.
+type fakeProvider struct {
+	err error
+}
+
+func (f fakeProvider) CreateCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions) (*provider.Completion, error) {
+	var collector provider.Collector
+	err := f.StreamCompletion(ctx, messages, opts, collector.Handle)
+	return collector.Completion(), err
+}
+
+func (f fakeProvider) StreamCompletion(ctx context.Context, messages []provider.Message, opts *provider.CompletionOptions, onEvent provider.StreamHandler) error {
+	if f.err != nil {
+		return f.err
+	}
+	onEvent(provider.StreamEvent{Type: provider.EventContent, Content: opts.Model + ":"})
+	onEvent(provider.StreamEvent{Type: provider.EventContent, Content: messages[len(messages)-1].Content})
+	onEvent(provider.StreamEvent{Type: provider.EventUsage, Usage: &provider.Usage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3}})
+	onEvent(provider.StreamEvent{Type: provider.EventFinish, FinishReason: "length"})
+	return nil
+}
+
+func (f fakeProvider) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []provider.ModelInfo{{Name: "fake", Family: "test"}}, nil
+}
+
+func (fakeProvider) GetDefaultModel() string { return "fake" }
+func (fakeProvider) Name() string            { return "Fake" }
+func (fakeProvider) Description() string     { return "A fake provider" }
+
+// serve runs Serve on input and returns the responses written.
+func serve(t *testing.T, p provider.Provider, input string) []Response {
+	t.Helper()
+	var out bytes.Buffer
+	require.NoError(t, Serve(context.Background(), p, strings.NewReader(input), &out))
+
+	var responses []Response
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var resp Response
+		require.NoError(t, decoder.Decode(&resp))
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+func TestServe(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     provider.Provider
+		input string
+		want  []Response
+	}{
+		{
+			name:  "info",
+			p:     fakeProvider{},
+			input: `{"id":1,"method":"info"}`,
+			want: []Response{{ID: 1, Type: TypeResult, Result: &Result{
+				ProtocolVersion: ProtocolVersion,
+				Name:            "Fake",
+				Description:     "A fake provider",
+				DefaultModel:    "fake",
+			}}},
+		},
+		{
+			name:  "list models",
+			p:     fakeProvider{},
+			input: `{"id":2,"method":"list_models"}`,
+			want:  []Response{{ID: 2, Type: TypeResult, Result: &Result{Models: []ModelInfo{{Name: "fake", Family: "test"}}}}},
+		},
+		{
+			name:  "complete with the default model",
+			p:     fakeProvider{},
+			input: `{"id":3,"method":"complete","params":{"messages":[{"role":"user","content":"hi"}]}}`,
+			want: []Response{{ID: 3, Type: TypeResult, Result: &Result{
+				Content:      "fake:hi",
+				FinishReason: "length",
+				Usage:        &Usage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3},
+			}}},
+		},
+		{
+			name:  "stream",
+			p:     fakeProvider{},
+			input: `{"id":4,"method":"stream","params":{"model":"m","messages":[{"role":"user","content":"hi"}]}}`,
+			want: []Response{
+				{ID: 4, Type: TypeDelta, Content: "m:"},
+				{ID: 4, Type: TypeDelta, Content: "hi"},
+				{ID: 4, Type: TypeUsage, Usage: &Usage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3}},
+				{ID: 4, Type: TypeDone, FinishReason: "length"},
+			},
+		},
+		{
+			name:  "several requests and blank lines",
+			p:     fakeProvider{},
+			input: "{\"id\":5,\"method\":\"list_models\"}\n\n{\"id\":6,\"method\":\"nope\"}\n",
+			want: []Response{
+				{ID: 5, Type: TypeResult, Result: &Result{Models: []ModelInfo{{Name: "fake", Family: "test"}}}},
+				{ID: 6, Type: TypeError, Error: &ErrorPayload{Message: `unknown method "nope"`, Code: "unknown_method"}},
+			},
+		},
+		{
+			name:  "missing params",
+			p:     fakeProvider{},
+			input: `{"id":7,"method":"stream"}`,
+			want:  []Response{{ID: 7, Type: TypeError, Error: &ErrorPayload{Message: "missing params", Code: "invalid_request"}}},
+		},
+		{
+			name:  "invalid request",
+			p:     fakeProvider{},
+			input: `{"id":`,
+			want:  []Response{{ID: 0, Type: TypeError, Error: &ErrorPayload{Message: "unexpected end of JSON input", Code: "invalid_request"}}},
+		},
+		{
+			name:  "provider error in a stream",
+			p:     fakeProvider{err: errors.New("backend down")},
+			input: `{"id":8,"method":"stream","params":{"messages":[{"role":"user","content":"hi"}]}}`,
+			want:  []Response{{ID: 8, Type: TypeError, Error: &ErrorPayload{Message: "backend down"}}},
+		},
+		{
+			name:  "provider error in complete",
+			p:     fakeProvider{err: errors.New("backend down")},
+			input: `{"id":9,"method":"complete","params":{"messages":[{"role":"user","content":"hi"}]}}`,
+			want:  []Response{{ID: 9, Type: TypeError, Error: &ErrorPayload{Message: "backend down"}}},
+		},
+		{
+			name:  "provider error listing models",
+			p:     fakeProvider{err: errors.New("backend down")},
+			input: `{"id":10,"method":"list_models"}`,
+			want:  []Response{{ID: 10, Type: TypeError, Error: &ErrorPayload{Message: "backend down"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, serve(t, tt.p, tt.input))
+		})
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestServeWriteError(t *testing.T) {
+	err := Serve(context.Background(), fakeProvider{}, strings.NewReader(`{"id":1,"method":"info"}`), failingWriter{})
+	assert.EqualError(t, err, "broken pipe")
+}
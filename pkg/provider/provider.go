@@ -1,8 +1,37 @@
 package provider
 
+import (
+	"context"
+	"encoding/base64"
+)
+
 type Message struct {
-	Role    string
-	Content string
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Interrupted marks an answer the user stopped before the end.
+	Interrupted bool `json:"interrupted,omitempty"`
+	// Summary marks a system message that stands in for earlier messages
+	// of the conversation.
+	Summary bool `json:"summary,omitempty"`
+	// Images are sent along with the content to vision models.
+	Images []Image `json:"images,omitempty"`
+}
+
+// Image is a picture attached to a message.
+type Image struct {
+	// MIMEType is the media type of Data, such as image/png.
+	MIMEType string `json:"mime_type"`
+	Data     []byte `json:"data"`
+}
+
+// Base64 returns the image data in standard base64 encoding.
+func (i Image) Base64() string {
+	return base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// DataURL returns the image as a data: URL.
+func (i Image) DataURL() string {
+	return "data:" + i.MIMEType + ";base64," + i.Base64()
 }
 
 type CompletionOptions struct {
@@ -11,32 +40,58 @@ type CompletionOptions struct {
 }
 
 type ModelInfo struct {
-	Name        string
-	Size        int64
-	Modified    string
-	Family      string
-	Description string
+	Name        string `json:"name"`
+	Size        int64  `json:"size,omitempty"`
+	Modified    string `json:"modified,omitempty"`
+	Family      string `json:"family,omitempty"`
+	Description string `json:"description,omitempty"`
 }
 
 type Provider interface {
-	CreateCompletion(messages []Message, opts *CompletionOptions) (string, error)
-	StreamCompletion(messages []Message, opts *CompletionOptions, onResponse func(string)) error
+	CreateCompletion(ctx context.Context, messages []Message, opts *CompletionOptions) (*Completion, error)
+	StreamCompletion(ctx context.Context, messages []Message, opts *CompletionOptions, onEvent StreamHandler) error
 
-	ListModels() ([]ModelInfo, error)
+	ListModels(ctx context.Context) ([]ModelInfo, error)
 	GetDefaultModel() string
 
 	Name() string
 	Description() string
 }
 
+// ContextSizer is implemented by providers that can tell how many tokens a
+// model accepts. A length of zero means the backend does not say.
+type ContextSizer interface {
+	ContextLength(ctx context.Context, model string) (int, error)
+}
+
+// Config holds the connection settings used to create a provider.
+type Config struct {
+	BaseURL string
+	// BasePath is the API path prefix of OpenAI-compatible servers.
+	BasePath string
+	// APIKey is sent as a bearer token by providers that support it.
+	APIKey  string
+	Headers map[string]string
+}
+
+// RequestHeaders returns the headers to send with every request, including the
+// Authorization header derived from APIKey.
+func (c Config) RequestHeaders() map[string]string {
+	headers := make(map[string]string, len(c.Headers)+1)
+	for key, value := range c.Headers {
+		headers[key] = value
+	}
+	if c.APIKey != "" {
+		headers["Authorization"] = "Bearer " + c.APIKey
+	}
+	return headers
+}
+
 type ProviderType string
 
+// Types of the providers that ship with ai-cli.
 const (
-	Ollama  ProviderType = "ollama"
-	LocalAI ProviderType = "localai"
+	Ollama           ProviderType = "ollama"
+	LocalAI          ProviderType = "localai"
+	OpenAICompatible ProviderType = "openai-compatible"
 )
-
-var DefaultURLs = map[ProviderType]string{
-	Ollama:  "http://localhost:11434",
-	LocalAI: "http://localhost:8080",
-}
@@ -0,0 +1,128 @@
+package provider
+
+import "strings"
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+type splitState int
+
+const (
+	splitStart splitState = iota
+	splitThinking
+	splitAnswer
+)
+
+// reasoningSplitter turns inline <think>...</think> blocks at the start of an
+// answer into EventReasoning events. Tags may be split across any number of
+// content deltas, so text that could still turn out to be part of a tag is
+// held back until the next delta arrives.
+type reasoningSplitter struct {
+	next    StreamHandler
+	state   splitState
+	pending string
+	// trimLeading drops the whitespace models put after the opening and
+	// closing tags.
+	trimLeading bool
+}
+
+// SplitReasoning wraps next so that reasoning emitted inline as a leading
+// <think>...</think> block is reported as EventReasoning instead of content.
+// Providers apply it to every stream, so EventContent only ever carries the
+// answer itself.
+func SplitReasoning(next StreamHandler) StreamHandler {
+	s := &reasoningSplitter{next: next}
+	return s.handle
+}
+
+func (s *reasoningSplitter) handle(event StreamEvent) {
+	if event.Type != EventContent {
+		s.flush()
+		s.next(event)
+		return
+	}
+
+	s.pending += event.Content
+	for s.pending != "" {
+		if !s.step() {
+			return
+		}
+	}
+}
+
+// step consumes as much of the pending text as it can and reports whether
+// further progress is possible without more input.
+func (s *reasoningSplitter) step() bool {
+	switch s.state {
+	case splitStart:
+		trimmed := strings.TrimLeft(s.pending, " \t\r\n")
+		switch {
+		case trimmed == "" || strings.HasPrefix(thinkOpenTag, trimmed):
+			return false
+		case strings.HasPrefix(trimmed, thinkOpenTag):
+			s.pending = trimmed[len(thinkOpenTag):]
+			s.state = splitThinking
+			s.trimLeading = true
+		default:
+			s.state = splitAnswer
+		}
+		return true
+
+	case splitThinking:
+		if i := strings.Index(s.pending, thinkCloseTag); i >= 0 {
+			s.emit(EventReasoning, s.pending[:i])
+			s.pending = s.pending[i+len(thinkCloseTag):]
+			s.state = splitAnswer
+			s.trimLeading = true
+			return true
+		}
+		keep := partialSuffix(s.pending, thinkCloseTag)
+		s.emit(EventReasoning, s.pending[:len(s.pending)-keep])
+		s.pending = s.pending[len(s.pending)-keep:]
+		return false
+
+	default:
+		s.emit(EventContent, s.pending)
+		s.pending = ""
+		return false
+	}
+}
+
+// flush releases held-back text before a non-content event.
+func (s *reasoningSplitter) flush() {
+	if s.pending == "" {
+		return
+	}
+	if s.state == splitThinking {
+		s.emit(EventReasoning, s.pending)
+	} else {
+		s.emit(EventContent, s.pending)
+	}
+	s.pending = ""
+}
+
+func (s *reasoningSplitter) emit(eventType EventType, text string) {
+	if s.trimLeading {
+		text = strings.TrimLeft(text, " \t\r\n")
+		if text != "" {
+			s.trimLeading = false
+		}
+	}
+	if text == "" {
+		return
+	}
+	s.next(StreamEvent{Type: eventType, Content: text})
+}
+
+// partialSuffix returns the length of the longest suffix of s that is a proper
+// prefix of tag.
+func partialSuffix(s, tag string) int {
+	for n := len(tag) - 1; n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder keeps the events a handler receives.
+type recorder struct {
+	events []StreamEvent
+}
+
+func (r *recorder) handle(event StreamEvent) {
+	r.events = append(r.events, event)
+}
+
+// text joins the content of the events of one type.
+func (r *recorder) text(eventType EventType) string {
+	var s string
+	for _, e := range r.events {
+		if e.Type == eventType {
+			s += e.Content
+		}
+	}
+	return s
+}
+
+func TestSplitReasoning(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		wantReasoning string
+		wantContent   string
+	}{
+		{
+			name:        "no tag",
+			input:       "Just an answer.",
+			wantContent: "Just an answer.",
+		},
+		{
+			name:          "leading block",
+			input:         "<think>Let me see.</think>42",
+			wantReasoning: "Let me see.",
+			wantContent:   "42",
+		},
+		{
+			name:          "whitespace around the tags",
+			input:         "\n  <think>\nHmm.\n</think>\n\nThe answer is 42.",
+			wantReasoning: "Hmm.\n",
+			wantContent:   "The answer is 42.",
+		},
+		{
+			name:          "unclosed tag",
+			input:         "<think>Still thinking",
+			wantReasoning: "Still thinking",
+		},
+		{
+			name:        "text before the tag",
+			input:       "Answer first <think>not reasoning</think> then more",
+			wantContent: "Answer first <think>not reasoning</think> then more",
+		},
+		{
+			name:          "tag after the block",
+			input:         "<think>a</think>b <think>c</think>",
+			wantReasoning: "a",
+			wantContent:   "b <think>c</think>",
+		},
+		{
+			name:          "close tag look-alike",
+			input:         "<think>a </thin b</think>c",
+			wantReasoning: "a </thin b",
+			wantContent:   "c",
+		},
+		{
+			name:        "open tag look-alike",
+			input:       "<thinking>x",
+			wantContent: "<thinking>x",
+		},
+		{
+			name:  "empty block",
+			input: "<think></think>",
+		},
+	}
+
+	// check feeds chunks to SplitReasoning, ends the stream with a finish
+	// event and compares what came out.
+	check := func(t *testing.T, chunks []string, wantReasoning, wantContent string) {
+		t.Helper()
+		var r recorder
+		handle := SplitReasoning(r.handle)
+		for _, chunk := range chunks {
+			handle(StreamEvent{Type: EventContent, Content: chunk})
+		}
+		handle(StreamEvent{Type: EventFinish, FinishReason: FinishStop})
+
+		assert.Equal(t, wantReasoning, r.text(EventReasoning), "reasoning of %q", chunks)
+		assert.Equal(t, wantContent, r.text(EventContent), "content of %q", chunks)
+		last := r.events[len(r.events)-1]
+		assert.Equal(t, EventFinish, last.Type, "the finish event comes last")
+		answered := false
+		for _, e := range r.events[:len(r.events)-1] {
+			assert.NotEmpty(t, e.Content, "no empty events")
+			if e.Type == EventContent {
+				answered = true
+			} else {
+				assert.False(t, answered, "no reasoning after the answer in %q", chunks)
+			}
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check(t, []string{tt.input}, tt.wantReasoning, tt.wantContent)
+			for i := 1; i < len(tt.input); i++ {
+				check(t, []string{tt.input[:i], tt.input[i:]}, tt.wantReasoning, tt.wantContent)
+			}
+			var bytes []string
+			for i := range len(tt.input) {
+				bytes = append(bytes, tt.input[i:i+1])
+			}
+			check(t, bytes, tt.wantReasoning, tt.wantContent)
+		})
+	}
+}
+
+func TestPartialSuffix(t *testing.T) {
+	assert.Equal(t, 0, partialSuffix("abc", thinkCloseTag))
+	assert.Equal(t, 1, partialSuffix("abc<", thinkCloseTag))
+	assert.Equal(t, 7, partialSuffix("abc</think", thinkCloseTag))
+	assert.Equal(t, 0, partialSuffix("</think>", thinkCloseTag), "a whole tag is no partial one")
+}
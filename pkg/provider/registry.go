@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Capabilities describes the optional features of a provider. The CLI uses
+// them to decide which flags and commands to offer.
+type Capabilities struct {
+	// ListModels is set when the backend can enumerate its models.
+	ListModels bool
+	// Reasoning is set when models may emit separate reasoning output.
+	Reasoning bool
+	// APIKey is set when the backend accepts a bearer token.
+	APIKey bool
+	// BasePath is set when the API can be mounted under a custom path.
+	BasePath bool
+	// Images is set when messages can carry images for vision models.
+	Images bool
+	// Embeddings is set when the provider implements Embedder.
+	Embeddings bool
+}
+
+// Factory creates a provider from connection settings. The registry fills in
+// the default URL before calling it.
+type Factory func(cfg Config) (Provider, error)
+
+// Registration describes a provider backend to the registry.
+type Registration struct {
+	Type        ProviderType
+	Name        string
+	Description string
+	DefaultURL  string
+	// DefaultModel may be empty for backends that pick a model themselves.
+	DefaultModel string
+	// DefaultEmbeddingModel is the model used for embeddings; it may be
+	// empty like DefaultModel.
+	DefaultEmbeddingModel string
+	// APIKeyEnv names an environment variable consulted for the API key,
+	// in addition to the generic AI_CLI_API_KEY.
+	APIKeyEnv string
+	// URLEnv names an environment variable consulted for the URL, such as
+	// OLLAMA_HOST. A value without a scheme is taken to be an http address.
+	URLEnv       string
+	Capabilities Capabilities
+	// Examples are extra usage examples shown in the provider's help.
+	Examples []string
+	Factory  Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[ProviderType]Registration)
+)
+
+// Register makes a provider available to NewProvider and the CLI. Backends
+// usually call it from an init function. It panics if the registration is
+// incomplete or the type is already registered.
+func Register(r Registration) {
+	if r.Type == "" || r.Factory == nil {
+		panic("provider: Register requires a type and a factory")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, dup := registry[r.Type]; dup {
+		panic(fmt.Sprintf("provider: Register called twice for %s", r.Type))
+	}
+	if r.Name == "" {
+		r.Name = string(r.Type)
+	}
+	registry[r.Type] = r
+}
+
+// Lookup returns the registration of a provider type.
+func Lookup(providerType ProviderType) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	r, ok := registry[providerType]
+	return r, ok
+}
+
+// Registered returns all registrations sorted by type.
+func Registered() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	registrations := make([]Registration, 0, len(registry))
+	for _, r := range registry {
+		registrations = append(registrations, r)
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].Type < registrations[j].Type
+	})
+	return registrations
+}
+
+// NewProvider creates a registered provider, using its default URL when cfg
+// does not set one.
+func NewProvider(providerType ProviderType, cfg Config) (Provider, error) {
+	r, ok := Lookup(providerType)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider type: %s", providerType)
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = r.DefaultURL
+	}
+	return r.Factory(cfg)
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"strings"
+	"time"
+)
+
+// EventType identifies what a StreamEvent carries.
+type EventType string
+
+const (
+	// EventContent carries a delta of the answer text.
+	EventContent EventType = "content"
+	// EventReasoning carries a delta of the model's reasoning ("thinking") text.
+	EventReasoning EventType = "reasoning"
+	// EventUsage carries token counts and timings reported by the backend.
+	EventUsage EventType = "usage"
+	// EventFinish marks the end of generation and carries the finish reason.
+	EventFinish EventType = "finish"
+	// EventError carries an error reported by the backend while streaming.
+	EventError EventType = "error"
+)
+
+// Finish reasons shared by all providers. Backends that report other values
+// are passed through unchanged.
+const (
+	FinishStop   = "stop"
+	FinishLength = "length"
+	// FinishInterrupted marks answers the user stopped before the end.
+	FinishInterrupted = "interrupted"
+)
+
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	PromptDuration   time.Duration
+	EvalDuration     time.Duration
+	TotalDuration    time.Duration
+}
+
+// TokensPerSecond returns the generation speed, or 0 when the backend did not
+// report an evaluation time.
+func (u Usage) TokensPerSecond() float64 {
+	if u.EvalDuration <= 0 {
+		return 0
+	}
+	return float64(u.CompletionTokens) / u.EvalDuration.Seconds()
+}
+
+type StreamEvent struct {
+	Type         EventType
+	Content      string
+	Usage        *Usage
+	FinishReason string
+	Err          error
+}
+
+// StreamHandler receives events in the order the backend produced them.
+type StreamHandler func(StreamEvent)
+
+// Completion is the accumulated result of a completion request.
+type Completion struct {
+	Content      string
+	Reasoning    string
+	FinishReason string
+	Usage        *Usage
+}
+
+// Truncated reports whether generation stopped because of a length limit.
+func (c *Completion) Truncated() bool {
+	return c.FinishReason == FinishLength
+}
+
+// Collector accumulates stream events into a Completion. Its Handle method can
+// be passed directly to StreamCompletion.
+type Collector struct {
+	content      strings.Builder
+	reasoning    strings.Builder
+	finishReason string
+	usage        *Usage
+}
+
+func (c *Collector) Handle(event StreamEvent) {
+	switch event.Type {
+	case EventContent:
+		c.content.WriteString(event.Content)
+	case EventReasoning:
+		c.reasoning.WriteString(event.Content)
+	case EventUsage:
+		c.usage = event.Usage
+	case EventFinish:
+		c.finishReason = event.FinishReason
+	}
+}
+
+func (c *Collector) Completion() *Completion {
+	return &Completion{
+		Content:      c.content.String(),
+		Reasoning:    c.reasoning.String(),
+		FinishReason: c.finishReason,
+		Usage:        c.usage,
+	}
+}
@@ -0,0 +1,242 @@
+package rag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/ahr9n/ai-cli/pkg/files"
+)
+
+// DefaultBatchSize is how many chunks are embedded in one request unless
+// BuildOptions say otherwise.
+const DefaultBatchSize = 32
+
+// EmbedFunc returns one embedding vector for each text, in the same order.
+type EmbedFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+// BuildOptions control how an index is built.
+type BuildOptions struct {
+	// Provider and Model name the embedding model. Chunks embedded by
+	// another model are embedded again.
+	Provider string
+	URL      string
+	Model    string
+	// ChunkSize and ChunkOverlap are in bytes; changing them re-chunks
+	// every file.
+	ChunkSize    int
+	ChunkOverlap int
+	BatchSize    int
+	// MaxFileSize leaves out larger files; zero means no limit.
+	MaxFileSize int64
+	// Progress, if set, is called with each file about to be embedded.
+	Progress func(path string)
+}
+
+// BuildStats counts what a build did.
+type BuildStats struct {
+	Added     int
+	Updated   int
+	Unchanged int
+	Removed   int
+	Chunks    int
+	Skipped   []files.Skipped
+}
+
+// pendingFile is a changed file whose chunks are being embedded.
+type pendingFile struct {
+	path      string
+	file      *File
+	remaining int
+	added     bool
+}
+
+// chunkRef is a chunk waiting for its embedding.
+type chunkRef struct {
+	file  *pendingFile
+	index int
+}
+
+// Build brings the index up to date with the files under its root: new and
+// changed files, told apart by their SHA-256, are chunked and embedded,
+// unchanged ones are kept as they are and deleted ones are removed. Hidden
+// files and those .gitignore excludes are left out, like binary files.
+//
+// Files are only replaced once all their chunks are embedded, so after an
+// error the index is still consistent and can be saved; the next build
+// carries on where this one stopped.
+func Build(ctx context.Context, idx *Index, embed EmbedFunc, opts BuildOptions) (BuildStats, error) {
+	var stats BuildStats
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if opts.ChunkOverlap < 0 || opts.ChunkOverlap >= opts.ChunkSize {
+		return stats, fmt.Errorf("chunk overlap must be at least 0 and less than the chunk size %d", opts.ChunkSize)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	if idx.Provider != opts.Provider || idx.Model != opts.Model ||
+		idx.ChunkSize != opts.ChunkSize || idx.ChunkOverlap != opts.ChunkOverlap {
+		// Vectors of different models cannot be compared, and other
+		// chunk settings make other chunks: start over.
+		idx.Files = make(map[string]*File)
+		idx.Dimensions = 0
+	}
+	idx.Provider, idx.URL, idx.Model = opts.Provider, opts.URL, opts.Model
+	idx.ChunkSize, idx.ChunkOverlap = opts.ChunkSize, opts.ChunkOverlap
+
+	paths, err := files.Walk(idx.Root)
+	if err != nil {
+		return stats, err
+	}
+
+	seen := make(map[string]bool, len(paths))
+	var queue []chunkRef
+	flush := func() error {
+		if len(queue) == 0 {
+			return nil
+		}
+		texts := make([]string, len(queue))
+		for i, ref := range queue {
+			texts[i] = embeddingText(ref.file.path, ref.file.file.Chunks[ref.index].Text)
+		}
+		vectors, err := embed(ctx, texts)
+		if err != nil {
+			return err
+		}
+		if len(vectors) != len(texts) {
+			return fmt.Errorf("got %d embeddings for %d chunks", len(vectors), len(texts))
+		}
+
+		for i, ref := range queue {
+			vector := vectors[i]
+			if idx.Dimensions == 0 {
+				idx.Dimensions = len(vector)
+			} else if len(vector) != idx.Dimensions {
+				return fmt.Errorf("the embedding of %s has %d dimensions, not %d like the others", ref.file.path, len(vector), idx.Dimensions)
+			}
+			normalize(vector)
+			ref.file.file.Chunks[ref.index].Vector = vector
+
+			ref.file.remaining--
+			if ref.file.remaining == 0 {
+				idx.Files[ref.file.path] = ref.file.file
+				stats.Chunks += len(ref.file.file.Chunks)
+				if ref.file.added {
+					stats.Added++
+				} else {
+					stats.Updated++
+				}
+			}
+		}
+		queue = queue[:0]
+		return nil
+	}
+
+	for _, path := range paths {
+		rel, err := filepath.Rel(idx.Root, path)
+		if err != nil {
+			return stats, err
+		}
+		rel = filepath.ToSlash(rel)
+
+		data, skipped := readFile(path, opts.MaxFileSize)
+		if skipped != nil {
+			skipped.Path = rel
+			stats.Skipped = append(stats.Skipped, *skipped)
+			continue
+		}
+		seen[rel] = true
+
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		old, ok := idx.Files[rel]
+		if ok && old.Hash == hash {
+			stats.Unchanged++
+			continue
+		}
+
+		file := &File{Hash: hash, Chunks: chunkText(string(data), isMarkdown(rel), opts.ChunkSize, opts.ChunkOverlap)}
+		if len(file.Chunks) == 0 {
+			// Nothing to embed; remember the file so that it is not
+			// looked at again.
+			idx.Files[rel] = file
+			if ok {
+				stats.Updated++
+			} else {
+				stats.Added++
+			}
+			continue
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(rel)
+		}
+		pending := &pendingFile{path: rel, file: file, remaining: len(file.Chunks), added: !ok}
+		for i := range file.Chunks {
+			queue = append(queue, chunkRef{file: pending, index: i})
+			if len(queue) == opts.BatchSize {
+				if err := flush(); err != nil {
+					return stats, err
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return stats, err
+	}
+
+	for rel := range idx.Files {
+		if !seen[rel] {
+			delete(idx.Files, rel)
+			stats.Removed++
+		}
+	}
+	return stats, nil
+}
+
+// readFile reads a file to index, or says why it is left out.
+func readFile(path string, maxSize int64) ([]byte, *files.Skipped) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, &files.Skipped{Reason: err.Error()}
+	}
+	if maxSize > 0 && info.Size() > maxSize {
+		return nil, &files.Skipped{Reason: files.ReasonTooLarge, Size: info.Size()}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &files.Skipped{Reason: err.Error()}
+	}
+	if files.IsBinary(data) {
+		return nil, &files.Skipped{Reason: files.ReasonBinary, Size: info.Size()}
+	}
+	return data, nil
+}
+
+// embeddingText is what is embedded for a chunk: its text headed by the
+// file name, which often says what the text is about.
+func embeddingText(path, text string) string {
+	return "File: " + path + "\n\n" + text
+}
+
+// normalize scales v to unit length in place.
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	scale := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= scale
+	}
+}
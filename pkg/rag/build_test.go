@@ -0,0 +1,120 @@
+package rag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEmbedder embeds a text as its length and a constant, and keeps the
+// texts and batch sizes it was asked for.
+type fakeEmbedder struct {
+	texts   []string
+	batches []int
+}
+
+func (e *fakeEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts = append(e.texts, texts...)
+	e.batches = append(e.batches, len(texts))
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len(text)), 1}
+	}
+	return vectors, nil
+}
+
+// embeddedFiles returns the files whose chunks e embedded, sorted.
+func (e *fakeEmbedder) embeddedFiles() []string {
+	seen := make(map[string]bool)
+	for _, text := range e.texts {
+		name, _, _ := strings.Cut(strings.TrimPrefix(text, "File: "), "\n")
+		seen[name] = true
+	}
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeFiles(t *testing.T, dir string, contents map[string]string) {
+	t.Helper()
+	for name, content := range contents {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func hashOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt":        "alpha\n",
+		"docs/b.md":    "# B\nbeta\n",
+		"c.txt":        "gamma\n",
+		".hidden":      "secret\n",
+		"image.bin":    "\x00\x01\x02",
+		"empty.txt":    "",
+		".gitignore":   "ignored.txt\n",
+		"ignored.txt":  "ignored\n",
+		"docs/long.md": "# One\nfirst\n# Two\nsecond\n",
+	})
+	idx := New("test", dir)
+	opts := BuildOptions{Provider: "fake", Model: "m", BatchSize: 2}
+
+	e := &fakeEmbedder{}
+	stats, err := Build(context.Background(), idx, e.embed, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "c.txt", "docs/b.md", "docs/long.md"}, e.embeddedFiles())
+	assert.Equal(t, []int{2, 2, 1}, e.batches)
+	assert.Equal(t, 5, stats.Added, "the empty file is remembered too")
+	assert.Equal(t, 5, stats.Chunks)
+	require.Len(t, stats.Skipped, 1)
+	assert.Equal(t, "image.bin", stats.Skipped[0].Path)
+	assert.Equal(t, 2, idx.Dimensions)
+	assert.Len(t, idx.Files["docs/long.md"].Chunks, 2)
+	assert.Equal(t, hashOf("alpha\n"), idx.Files["a.txt"].Hash)
+	assert.InDelta(t, 1, dot(idx.Files["a.txt"].Chunks[0].Vector, idx.Files["a.txt"].Chunks[0].Vector), 1e-6,
+		"vectors are stored with unit length")
+
+	// Only the changed file is embedded again, and the deleted one goes.
+	unchanged := idx.Files["a.txt"]
+	writeFiles(t, dir, map[string]string{"docs/b.md": "# B\nbeta, revised\n"})
+	require.NoError(t, os.Remove(filepath.Join(dir, "c.txt")))
+
+	e = &fakeEmbedder{}
+	stats, err = Build(context.Background(), idx, e.embed, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docs/b.md"}, e.embeddedFiles())
+	assert.Equal(t, BuildStats{Updated: 1, Unchanged: 3, Removed: 1, Chunks: 1, Skipped: stats.Skipped}, stats)
+	assert.Same(t, unchanged, idx.Files["a.txt"])
+	assert.Equal(t, hashOf("# B\nbeta, revised\n"), idx.Files["docs/b.md"].Hash)
+	assert.NotContains(t, idx.Files, "c.txt")
+
+	// Another model embeds everything again.
+	e = &fakeEmbedder{}
+	opts.Model = "other"
+	_, err = Build(context.Background(), idx, e.embed, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "docs/b.md", "docs/long.md"}, e.embeddedFiles())
+}
+
+func TestBuildOptions(t *testing.T) {
+	idx := New("test", t.TempDir())
+	_, err := Build(context.Background(), idx, (&fakeEmbedder{}).embed, BuildOptions{ChunkSize: 100, ChunkOverlap: 100})
+	assert.EqualError(t, err, "chunk overlap must be at least 0 and less than the chunk size 100")
+}
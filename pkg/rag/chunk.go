@@ -0,0 +1,102 @@
+package rag
+
+import "strings"
+
+// Default chunking settings, in bytes.
+const (
+	DefaultChunkSize    = 1500
+	DefaultChunkOverlap = 200
+)
+
+// chunkText splits text into runs of whole lines of about size bytes, each
+// starting with up to overlap bytes of the lines before it so that a
+// passage cut in two is still found whole in one chunk. In Markdown files a
+// heading outside code blocks starts a new chunk. Lines longer than size
+// make chunks of their own.
+func chunkText(text string, markdown bool, size, overlap int) []Chunk {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+
+	var chunks []Chunk
+	start := 0 // first line of the current chunk
+	length := 0
+	flush := func(end int) {
+		if end <= start {
+			return
+		}
+		last := end
+		for last > start && strings.TrimSpace(lines[last-1]) == "" {
+			last--
+		}
+		if last > start {
+			body := strings.Join(lines[start:last], "\n")
+			chunks = append(chunks, Chunk{StartLine: start + 1, EndLine: last, Text: body})
+		}
+
+		// The next chunk starts with the last lines of this one, as
+		// many as fit in the overlap.
+		next, kept := end, 0
+		for next > start+1 && kept+len(lines[next-1])+1 <= overlap {
+			next--
+			kept += len(lines[next]) + 1
+		}
+		start, length = next, kept
+	}
+
+	fence := "" // the marker of the open code block, if any
+	for i, line := range lines {
+		heading := false
+		if markdown {
+			trimmed := strings.TrimLeft(line, " \t")
+			switch {
+			case fence != "":
+				if strings.HasPrefix(trimmed, fence) && strings.Trim(strings.TrimSpace(trimmed), fence[:1]) == "" {
+					fence = ""
+				}
+			case fenceMarker(trimmed) != "":
+				fence = fenceMarker(trimmed)
+			default:
+				heading = isHeading(trimmed) && i > start
+			}
+		}
+		if heading {
+			// A section starts fresh, without overlap.
+			flush(i)
+			start, length = i, 0
+		} else if length > 0 && length+len(line)+1 > size && i > start {
+			flush(i)
+		}
+		length += len(line) + 1
+	}
+	flush(len(lines))
+	return chunks
+}
+
+// fenceMarker returns the fence a line opens a code block with, if any.
+func fenceMarker(s string) string {
+	for _, c := range []string{"`", "~"} {
+		n := len(s) - len(strings.TrimLeft(s, c))
+		if n >= 3 {
+			if c == "`" && strings.Contains(s[n:], "`") {
+				return ""
+			}
+			return s[:n]
+		}
+	}
+	return ""
+}
+
+// isHeading reports whether a line is an ATX heading: one to six #s
+// followed by a space or the end of the line.
+func isHeading(s string) bool {
+	level := len(s) - len(strings.TrimLeft(s, "#"))
+	if level == 0 || level > 6 {
+		return false
+	}
+	return level == len(s) || s[level] == ' '
+}
+
+// isMarkdown reports whether a file is Markdown by its name.
+func isMarkdown(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".md") || strings.HasSuffix(lower, ".markdown") || strings.HasSuffix(lower, ".mdx")
+}
@@ -0,0 +1,101 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkText(t *testing.T) {
+	type span struct {
+		start, end int
+		text       string
+	}
+	tests := []struct {
+		name     string
+		text     string
+		markdown bool
+		size     int
+		overlap  int
+		want     []span
+	}{
+		{
+			name: "fits in one chunk",
+			text: "one\ntwo\n",
+			size: 100,
+			want: []span{{1, 2, "one\ntwo"}},
+		},
+		{
+			name: "size limit",
+			text: "aaaa\nbbbb\ncccc\ndddd",
+			size: 10,
+			want: []span{{1, 2, "aaaa\nbbbb"}, {3, 4, "cccc\ndddd"}},
+		},
+		{
+			name:    "overlap",
+			text:    "aaaa\nbbbb\ncccc\ndddd",
+			size:    10,
+			overlap: 5,
+			want:    []span{{1, 2, "aaaa\nbbbb"}, {2, 3, "bbbb\ncccc"}, {3, 4, "cccc\ndddd"}},
+		},
+		{
+			name: "long line on its own",
+			text: "short\n" + strings.Repeat("x", 30) + "\nend",
+			size: 10,
+			want: []span{{1, 1, "short"}, {2, 2, strings.Repeat("x", 30)}, {3, 3, "end"}},
+		},
+		{
+			name:     "markdown sections",
+			text:     "# A\ntext\n\n## B\nmore\n#\nlast",
+			markdown: true,
+			size:     1000,
+			overlap:  100,
+			want:     []span{{1, 2, "# A\ntext"}, {4, 5, "## B\nmore"}, {6, 7, "#\nlast"}},
+		},
+		{
+			name:     "not headings",
+			text:     "intro\n#hashtag\n####### seven\nend",
+			markdown: true,
+			size:     1000,
+			want:     []span{{1, 4, "intro\n#hashtag\n####### seven\nend"}},
+		},
+		{
+			name: "headings only count in markdown",
+			text: "# A\ntext\n# B\nmore",
+			size: 1000,
+			want: []span{{1, 4, "# A\ntext\n# B\nmore"}},
+		},
+		{
+			name:     "fenced code",
+			text:     "# Setup\n```sh\n# install it\nmake\n```\n~~~\n# not a heading\n~~~\n## Use\nrun",
+			markdown: true,
+			size:     1000,
+			want: []span{
+				{1, 8, "# Setup\n```sh\n# install it\nmake\n```\n~~~\n# not a heading\n~~~"},
+				{9, 10, "## Use\nrun"},
+			},
+		},
+		{
+			name:     "fence closed only by its own marker",
+			text:     "# A\n````\n```\n# still code\n````\n# B",
+			markdown: true,
+			size:     1000,
+			want:     []span{{1, 5, "# A\n````\n```\n# still code\n````"}, {6, 6, "# B"}},
+		},
+		{
+			name: "blank",
+			text: "\n\n",
+			size: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []span
+			for _, c := range chunkText(tt.text, tt.markdown, tt.size, tt.overlap) {
+				got = append(got, span{c.StartLine, c.EndLine, c.Text})
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,188 @@
+// Package rag keeps local indexes of embedded document chunks and retrieves
+// the chunks closest to a question, for retrieval-augmented chat. An index
+// is a single file encoded with encoding/gob; it is read whole into memory
+// and searched exhaustively, which suits the size of a local document
+// directory.
+package rag
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileExt is the extension of index files.
+const FileExt = ".index"
+
+// formatVersion changes whenever the encoding of Index does.
+const formatVersion = 1
+
+// ErrNotFound is returned when no index matches a reference.
+var ErrNotFound = errors.New("index not found")
+
+// Index holds the embedded chunks of the files under a directory.
+type Index struct {
+	Version int
+	Name    string
+	// Root is the absolute path of the indexed directory.
+	Root string
+	// Provider, URL and Model are what the chunks were embedded with;
+	// questions must be embedded the same way.
+	Provider   string
+	URL        string
+	Model      string
+	Dimensions int
+	// ChunkSize and ChunkOverlap are the chunking settings in bytes.
+	ChunkSize    int
+	ChunkOverlap int
+	// Files maps slash-separated paths relative to Root to their chunks.
+	Files   map[string]*File
+	Updated time.Time
+}
+
+// File is an indexed file.
+type File struct {
+	// Hash is the SHA-256 of the content the chunks were made from.
+	Hash   string
+	Chunks []Chunk
+}
+
+// Chunk is a run of lines of a file and its embedding.
+type Chunk struct {
+	StartLine int
+	EndLine   int
+	Text      string
+	// Vector has unit length, so that dot products are cosine similarities.
+	Vector []float32
+}
+
+// New returns an empty index of root.
+func New(name, root string) *Index {
+	return &Index{
+		Version: formatVersion,
+		Name:    name,
+		Root:    root,
+		Files:   make(map[string]*File),
+	}
+}
+
+// Chunks counts the chunks of all files.
+func (idx *Index) Chunks() int {
+	n := 0
+	for _, f := range idx.Files {
+		n += len(f.Chunks)
+	}
+	return n
+}
+
+// DefaultDir returns the directory of named indexes, honoring XDG_DATA_HOME.
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "ai-cli", "indexes"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "ai-cli", "indexes"), nil
+}
+
+// Path returns the file of an index reference: a path when it contains a
+// separator or ends in FileExt, otherwise the name of an index in
+// DefaultDir.
+func Path(ref string) (string, error) {
+	if strings.ContainsRune(ref, os.PathSeparator) || strings.HasSuffix(ref, FileExt) {
+		return ref, nil
+	}
+	dir, err := DefaultDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, ref+FileExt), nil
+}
+
+// Load reads the index a reference names.
+func Load(ref string) (*Index, error) {
+	path, err := Path(ref)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var idx Index
+	if err := gob.NewDecoder(f).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("failed to read index %s: %w", path, err)
+	}
+	if idx.Version != formatVersion {
+		return nil, fmt.Errorf("index %s has format %d, not %d; rebuild it", path, idx.Version, formatVersion)
+	}
+	if idx.Files == nil {
+		idx.Files = make(map[string]*File)
+	}
+	return &idx, nil
+}
+
+// Save writes the index to path, replacing the file atomically.
+func (idx *Index) Save(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	idx.Updated = time.Now()
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(idx); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// List returns the named indexes in DefaultDir, sorted by name. Files that
+// cannot be read are skipped.
+func List() ([]*Index, error) {
+	dir, err := DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []*Index
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), FileExt) {
+			continue
+		}
+		idx, err := Load(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		indexes = append(indexes, idx)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	return indexes, nil
+}
@@ -0,0 +1,78 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Result is a chunk found for a question.
+type Result struct {
+	// Path is relative to the root of the index, with slashes.
+	Path      string
+	StartLine int
+	EndLine   int
+	Text      string
+	// Score is the cosine similarity of the chunk and the question.
+	Score float32
+}
+
+// Search returns the k chunks most similar to vector, best first.
+func (idx *Index) Search(vector []float32, k int) ([]Result, error) {
+	if len(vector) != idx.Dimensions {
+		return nil, fmt.Errorf("the question embedding has %d dimensions, but the index has %d; was it built with another model?", len(vector), idx.Dimensions)
+	}
+	query := append([]float32(nil), vector...)
+	normalize(query)
+
+	var results []Result
+	for path, file := range idx.Files {
+		for _, chunk := range file.Chunks {
+			results = append(results, Result{
+				Path:      path,
+				StartLine: chunk.StartLine,
+				EndLine:   chunk.EndLine,
+				Text:      chunk.Text,
+				Score:     dot(query, chunk.Vector),
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.StartLine < b.StartLine
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// Query embeds question and returns the k chunks closest to it.
+func (idx *Index) Query(ctx context.Context, embed EmbedFunc, question string, k int) ([]Result, error) {
+	if idx.Dimensions == 0 {
+		return nil, nil
+	}
+	vectors, err := embed(ctx, []string{question})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("got %d embeddings for 1 question", len(vectors))
+	}
+	return idx.Search(vectors[0], k)
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
@@ -0,0 +1,67 @@
+package rag
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	idx := New("test", "/docs")
+	idx.Dimensions = 2
+	idx.Files = map[string]*File{
+		"a.md": {Chunks: []Chunk{
+			{StartLine: 1, EndLine: 2, Text: "east", Vector: []float32{1, 0}},
+			{StartLine: 3, EndLine: 4, Text: "north", Vector: []float32{0, 1}},
+		}},
+		"b.md": {Chunks: []Chunk{
+			{StartLine: 1, EndLine: 5, Text: "north-east", Vector: []float32{0.6, 0.8}},
+			{StartLine: 6, EndLine: 7, Text: "west", Vector: []float32{-1, 0}},
+		}},
+	}
+
+	texts := func(results []Result) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, r.Text)
+		}
+		return out
+	}
+
+	// The question need not have unit length.
+	results, err := idx.Search([]float32{3, 3}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"north-east", "east", "north", "west"}, texts(results),
+		"best first; equal scores by path and line")
+	assert.InDelta(t, 0.9899, results[0].Score, 1e-4)
+	assert.Equal(t, "b.md", results[0].Path)
+	assert.Equal(t, 1, results[0].StartLine)
+	assert.Equal(t, 5, results[0].EndLine)
+	assert.InDelta(t, -0.7071, results[3].Score, 1e-4)
+
+	results, err = idx.Search([]float32{0, 2}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"north", "north-east"}, texts(results))
+
+	_, err = idx.Search([]float32{1, 0, 0}, 2)
+	assert.EqualError(t, err, "the question embedding has 3 dimensions, but the index has 2; was it built with another model?")
+}
+
+func TestQuery(t *testing.T) {
+	idx := New("test", "/docs")
+	e := &fakeEmbedder{}
+	results, err := idx.Query(context.Background(), e.embed, "question", 3)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	assert.Empty(t, e.texts, "an empty index embeds nothing")
+
+	idx.Dimensions = 2
+	idx.Files = map[string]*File{"a.md": {Chunks: []Chunk{{Text: "x", Vector: []float32{1, 0}}}}}
+	results, err = idx.Query(context.Background(), e.embed, "question", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"question"}, e.texts)
+	require.Len(t, results, 1)
+	assert.Equal(t, "x", results[0].Text)
+}
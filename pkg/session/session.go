@@ -0,0 +1,253 @@
+// Package session stores interactive conversations on disk so that they can
+// be listed, inspected and resumed later.
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+// Last is the session reference that stands for the most recently updated
+// session.
+const Last = "last"
+
+// titleLength caps titles derived from the first prompt.
+const titleLength = 60
+
+// ErrNotFound is returned when no session matches a reference.
+var ErrNotFound = errors.New("session not found")
+
+// Session is a saved conversation with the settings needed to continue it.
+type Session struct {
+	ID           string             `json:"id"`
+	Title        string             `json:"title"`
+	Provider     string             `json:"provider"`
+	Model        string             `json:"model,omitempty"`
+	SystemPrompt string             `json:"system_prompt,omitempty"`
+	Messages     []provider.Message `json:"messages"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+}
+
+// New starts an unsaved session with a fresh ID.
+func New(providerType, model, systemPrompt string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:           newID(now),
+		Provider:     providerType,
+		Model:        model,
+		SystemPrompt: systemPrompt,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// newID returns a sortable, unique ID such as "20240518-142501-3fa2".
+func newID(now time.Time) string {
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// Turns counts the user messages of the session.
+func (s *Session) Turns() int {
+	n := 0
+	for _, msg := range s.Messages {
+		if msg.Role == prompts.RoleUser {
+			n++
+		}
+	}
+	return n
+}
+
+// DefaultTitle derives a title from the first user message.
+func (s *Session) DefaultTitle() string {
+	for _, msg := range s.Messages {
+		if msg.Role != prompts.RoleUser {
+			continue
+		}
+		title := strings.Join(strings.Fields(msg.Content), " ")
+		if r := []rune(title); len(r) > titleLength {
+			title = string(r[:titleLength-3]) + "..."
+		}
+		return title
+	}
+	return "Untitled"
+}
+
+// Store keeps sessions as JSON files in a directory.
+type Store struct {
+	Dir string
+}
+
+// DefaultDir returns the session directory, honoring XDG_DATA_HOME.
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "ai-cli", "sessions"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "ai-cli", "sessions"), nil
+}
+
+// NewStore returns a store in the default session directory.
+func NewStore() (*Store, error) {
+	dir, err := DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{Dir: dir}, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
+
+// Save writes the session, replacing any earlier version atomically. It sets
+// the title from the first prompt if there is none yet.
+func (s *Store) Save(sess *Session) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+	if sess.Title == "" {
+		sess.Title = sess.DefaultTitle()
+	}
+	sess.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, sess.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(sess.ID))
+}
+
+// List returns all sessions, most recently updated first. Files that cannot
+// be read are skipped.
+func (s *Store) List() ([]*Session, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		sess, err := s.read(id)
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// Load returns the session matching ref: an ID, a unique ID prefix, or Last.
+func (s *Store) Load(ref string) (*Session, error) {
+	id, err := s.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	return s.read(id)
+}
+
+// Delete removes the session matching ref.
+func (s *Store) Delete(ref string) (*Session, error) {
+	sess, err := s.Load(ref)
+	if err != nil {
+		return nil, err
+	}
+	return sess, os.Remove(s.path(sess.ID))
+}
+
+// Rename changes the title of the session matching ref.
+func (s *Store) Rename(ref, title string) (*Session, error) {
+	sess, err := s.Load(ref)
+	if err != nil {
+		return nil, err
+	}
+	sess.Title = title
+	return sess, s.Save(sess)
+}
+
+func (s *Store) read(id string) (*Session, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	sess := &Session{}
+	if err := json.Unmarshal(data, sess); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", id, err)
+	}
+	return sess, nil
+}
+
+func (s *Store) resolve(ref string) (string, error) {
+	if ref == "" || strings.ContainsAny(ref, `/\`) {
+		return "", fmt.Errorf("%w: %q", ErrNotFound, ref)
+	}
+	if _, err := os.Stat(s.path(ref)); err == nil && ref != Last {
+		return ref, nil
+	}
+
+	sessions, err := s.List()
+	if err != nil {
+		return "", err
+	}
+	if ref == Last {
+		if len(sessions) == 0 {
+			return "", fmt.Errorf("%w: there are no saved sessions", ErrNotFound)
+		}
+		return sessions[0].ID, nil
+	}
+
+	var matches []string
+	for _, sess := range sessions {
+		if strings.HasPrefix(sess.ID, ref) {
+			matches = append(matches, sess.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrNotFound, ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("session reference %q is ambiguous: %s", ref, strings.Join(matches, ", "))
+	}
+}
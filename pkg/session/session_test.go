@@ -0,0 +1,128 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveSessions saves a session for each ID, in order, so that the last one
+// is the most recently updated.
+func saveSessions(t *testing.T, store *Store, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		sess := New("ollama", "llama3", "")
+		sess.ID = id
+		require.NoError(t, store.Save(sess))
+		// Keep the update times apart on coarse clocks.
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	store := &Store{Dir: filepath.Join(t.TempDir(), "sessions")}
+
+	sess := New("ollama", "llama3", "Be brief.")
+	sess.Messages = []provider.Message{
+		{Role: prompts.RoleSystem, Content: "Be brief."},
+		{Role: prompts.RoleUser, Content: "  What is\nthe answer?  "},
+		{Role: prompts.RoleAssistant, Content: "42"},
+	}
+	require.NoError(t, store.Save(sess))
+	assert.Equal(t, "What is the answer?", sess.Title)
+
+	info, err := os.Stat(filepath.Join(store.Dir, sess.ID+".json"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := store.Load(sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sess.ID, loaded.ID)
+	assert.Equal(t, sess.Title, loaded.Title)
+	assert.Equal(t, "ollama", loaded.Provider)
+	assert.Equal(t, "llama3", loaded.Model)
+	assert.Equal(t, "Be brief.", loaded.SystemPrompt)
+	assert.Equal(t, sess.Messages, loaded.Messages)
+	assert.Equal(t, 1, loaded.Turns())
+	assert.True(t, sess.UpdatedAt.Equal(loaded.UpdatedAt))
+
+	entries, err := os.ReadDir(store.Dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files are left behind")
+}
+
+func TestStoreResolve(t *testing.T) {
+	store := &Store{Dir: t.TempDir()}
+	saveSessions(t, store, "20240101-100000-aaaa", "20240101-100000-bbbb", "20240102-090000-cccc")
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{ref: "20240101-100000-aaaa", want: "20240101-100000-aaaa"},
+		{ref: "20240102", want: "20240102-090000-cccc"},
+		{ref: "20240101-100000-b", want: "20240101-100000-bbbb"},
+		{ref: Last, want: "20240102-090000-cccc"},
+		{ref: "20240101", wantErr: `session reference "20240101" is ambiguous: 20240101-100000-bbbb, 20240101-100000-aaaa`},
+		{ref: "2025", wantErr: "session not found: 2025"},
+		{ref: "", wantErr: `session not found: ""`},
+		{ref: "../sessions", wantErr: `session not found: "../sessions"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			sess, err := store.Load(tt.ref)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, sess.ID)
+		})
+	}
+}
+
+func TestStoreLastFollowsUpdates(t *testing.T) {
+	store := &Store{Dir: t.TempDir()}
+
+	_, err := store.Load(Last)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	saveSessions(t, store, "20240101-100000-aaaa", "20240102-090000-cccc")
+	_, err = store.Rename("20240101-100000-aaaa", "Renamed")
+	require.NoError(t, err)
+
+	last, err := store.Load(Last)
+	require.NoError(t, err)
+	assert.Equal(t, "20240101-100000-aaaa", last.ID, "the most recently updated session, not the newest ID")
+	assert.Equal(t, "Renamed", last.Title)
+
+	deleted, err := store.Delete(Last)
+	require.NoError(t, err)
+	assert.Equal(t, "20240101-100000-aaaa", deleted.ID)
+	sessions, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "20240102-090000-cccc", sessions[0].ID)
+}
+
+func TestStoreListSkipsBrokenFiles(t *testing.T) {
+	store := &Store{Dir: t.TempDir()}
+	saveSessions(t, store, "20240101-100000-aaaa")
+	require.NoError(t, os.WriteFile(filepath.Join(store.Dir, "broken.json"), []byte("{"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(store.Dir, "notes.txt"), []byte("x"), 0600))
+
+	sessions, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "20240101-100000-aaaa", sessions[0].ID)
+
+	_, err = store.Load("broken")
+	assert.ErrorContains(t, err, "failed to parse session broken")
+}
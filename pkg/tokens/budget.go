@@ -0,0 +1,74 @@
+package tokens
+
+import (
+	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+)
+
+// MessageOverhead is the number of tokens added to each message by the chat
+// template, for the role and the markers around it.
+const MessageOverhead = 4
+
+// CountMessages returns the estimated number of tokens of a conversation.
+func CountMessages(t Tokenizer, messages []provider.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += t.Count(msg.Content) + MessageOverhead
+	}
+	return total
+}
+
+// Budget returns how many tokens of a context window a conversation may use,
+// leaving a quarter of it for the answer. It returns zero when the window is
+// unknown.
+func Budget(contextLength int) int {
+	return contextLength - contextLength/4
+}
+
+// Trim drops the oldest messages until the conversation fits in budget
+// tokens, and returns what is left and how many messages were dropped. The
+// system messages at the start, such as the system prompt, and the latest
+// user message, with anything after it, are always kept, so the result may
+// still exceed a small budget.
+func Trim(t Tokenizer, messages []provider.Message, budget int) ([]provider.Message, int) {
+	if budget <= 0 {
+		return messages, 0
+	}
+
+	total := CountMessages(t, messages)
+	if total <= budget {
+		return messages, 0
+	}
+
+	start := 0
+	for start < len(messages) && messages[start].Role == prompts.RoleSystem {
+		start++
+	}
+	latest := len(messages)
+	for i := len(messages) - 1; i >= start; i-- {
+		if messages[i].Role == prompts.RoleUser {
+			latest = i
+			break
+		}
+	}
+
+	// Drop messages from the start of the history, keeping the one the
+	// next answer replies to.
+	end := start
+	for end < latest && total > budget {
+		total -= t.Count(messages[end].Content) + MessageOverhead
+		end++
+	}
+	// Never leave an answer without its question.
+	for end < latest && messages[end].Role != prompts.RoleUser {
+		end++
+	}
+	if end == start {
+		return messages, 0
+	}
+
+	trimmed := make([]provider.Message, 0, len(messages)-(end-start))
+	trimmed = append(trimmed, messages[:start]...)
+	trimmed = append(trimmed, messages[end:]...)
+	return trimmed, end - start
+}
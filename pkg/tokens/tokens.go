@@ -0,0 +1,110 @@
+// Package tokens estimates how many tokens text takes up in a model's
+// context, and trims conversations to fit a token budget.
+package tokens
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens of text for a family of models.
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+// Registration describes a tokenizer to the registry.
+type Registration struct {
+	Name string
+	// Prefixes are the model name prefixes the tokenizer is used for, such
+	// as "llama3". Matching ignores case.
+	Prefixes []string
+	New      func() Tokenizer
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Registration
+)
+
+// Register makes a tokenizer available to ForModel. Later registrations win
+// over earlier ones for the same prefix, and longer prefixes over shorter
+// ones.
+func Register(r Registration) {
+	if r.Name == "" || r.New == nil {
+		panic("tokens: Register requires a name and a constructor")
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, r)
+}
+
+// ForModel returns the tokenizer registered for model, or Heuristic when
+// none matches.
+func ForModel(model string) Tokenizer {
+	model = strings.ToLower(model)
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var (
+		best       *Registration
+		bestLength = -1
+	)
+	for i := range registry {
+		for _, prefix := range registry[i].Prefixes {
+			if strings.HasPrefix(model, strings.ToLower(prefix)) && len(prefix) >= bestLength {
+				best, bestLength = &registry[i], len(prefix)
+			}
+		}
+	}
+	if best == nil {
+		return Heuristic{}
+	}
+	return best.New()
+}
+
+// Heuristic estimates tokens without a vocabulary, from how BPE tokenizers
+// commonly split text: a token for every four letters or digits of a word,
+// one for each punctuation mark or symbol, and one for each character of
+// scripts written without spaces, such as Chinese or Japanese.
+type Heuristic struct{}
+
+// Name returns "heuristic".
+func (Heuristic) Name() string {
+	return "heuristic"
+}
+
+// Count returns the estimated number of tokens in text.
+func (Heuristic) Count(text string) int {
+	count, word := 0, 0
+	endWord := func() {
+		count += (word + 3) / 4
+		word = 0
+	}
+
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+
+		switch {
+		case unicode.IsSpace(r):
+			endWord()
+		case isIdeograph(r):
+			endWord()
+			count++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			word++
+		default:
+			endWord()
+			count++
+		}
+	}
+	endWord()
+	return count
+}
+
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai)
+}
@@ -0,0 +1,181 @@
+package tokens
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ahr9n/ai-cli/pkg/prompts"
+	"github.com/ahr9n/ai-cli/pkg/provider"
+	"github.com/stretchr/testify/assert"
+)
+
+// wordTokenizer counts a token per word, for budgets that are easy to
+// follow.
+type wordTokenizer struct{}
+
+func (wordTokenizer) Name() string          { return "words" }
+func (wordTokenizer) Count(text string) int { return len(strings.Fields(text)) }
+
+func TestHeuristicCount(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"   \n\t", 0},
+		{"cat", 1},
+		{"four", 1},
+		{"fives", 2},
+		{"internationalization", 5},
+		{"Hello, world!", 6},
+		{"x := 42", 4},
+		{"日本語", 3},
+		{"漢字abc", 3},
+		{"naïve café", 3},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Heuristic{}.Count(tt.text), "%q", tt.text)
+	}
+}
+
+func TestForModel(t *testing.T) {
+	Register(Registration{Name: "test-short", Prefixes: []string{"TestModel"}, New: func() Tokenizer { return wordTokenizer{} }})
+	Register(Registration{Name: "test-long", Prefixes: []string{"testmodel-large"}, New: func() Tokenizer { return Heuristic{} }})
+
+	assert.Equal(t, "words", ForModel("testmodel-small").Name(), "matching ignores case")
+	assert.Equal(t, "heuristic", ForModel("TESTMODEL-LARGE:7b").Name(), "the longer prefix wins")
+	assert.Equal(t, "heuristic", ForModel("unknown").Name())
+	assert.Panics(t, func() { Register(Registration{Name: "broken"}) })
+}
+
+func TestBudget(t *testing.T) {
+	assert.Equal(t, 0, Budget(0))
+	assert.Equal(t, 3072, Budget(4096))
+}
+
+func msg(role, content string) provider.Message {
+	return provider.Message{Role: role, Content: content}
+}
+
+func TestTrim(t *testing.T) {
+	const (
+		system    = prompts.RoleSystem
+		user      = prompts.RoleUser
+		assistant = prompts.RoleAssistant
+	)
+	// Each message costs its words plus MessageOverhead: 5 tokens for one
+	// word.
+	conversation := []provider.Message{
+		msg(system, "prompt"),
+		msg(user, "one"),
+		msg(assistant, "two"),
+		msg(user, "three"),
+		msg(assistant, "four"),
+		msg(user, "five"),
+	}
+
+	tests := []struct {
+		name        string
+		messages    []provider.Message
+		budget      int
+		want        []string
+		wantDropped int
+	}{
+		{
+			name:     "unknown budget",
+			messages: conversation,
+			budget:   0,
+			want:     []string{"prompt", "one", "two", "three", "four", "five"},
+		},
+		{
+			name:     "fits",
+			messages: conversation,
+			budget:   30,
+			want:     []string{"prompt", "one", "two", "three", "four", "five"},
+		},
+		{
+			name:        "oldest turn dropped first",
+			messages:    conversation,
+			budget:      20,
+			want:        []string{"prompt", "three", "four", "five"},
+			wantDropped: 2,
+		},
+		{
+			name:        "only the system prompt and the question left",
+			messages:    conversation,
+			budget:      10,
+			want:        []string{"prompt", "five"},
+			wantDropped: 4,
+		},
+		{
+			name:        "question kept over budget",
+			messages:    conversation,
+			budget:      1,
+			want:        []string{"prompt", "five"},
+			wantDropped: 4,
+		},
+		{
+			name: "several leading system messages",
+			messages: []provider.Message{
+				msg(system, "prompt"),
+				msg(system, "summary of earlier messages"),
+				msg(user, "one"),
+				msg(assistant, "two"),
+				msg(user, "three"),
+			},
+			budget:      20,
+			want:        []string{"prompt", "summary of earlier messages", "three"},
+			wantDropped: 2,
+		},
+		{
+			name: "answer after the latest question kept",
+			messages: []provider.Message{
+				msg(user, "one"),
+				msg(assistant, "two"),
+				msg(user, "three"),
+				msg(assistant, "partial answer"),
+			},
+			budget:      12,
+			want:        []string{"three", "partial answer"},
+			wantDropped: 2,
+		},
+		{
+			name: "no answer left without its question",
+			messages: []provider.Message{
+				msg(user, "a long first question"),
+				msg(assistant, "two"),
+				msg(user, "three"),
+			},
+			budget:      14,
+			want:        []string{"three"},
+			wantDropped: 2,
+		},
+		{
+			name: "nothing to drop",
+			messages: []provider.Message{
+				msg(system, "a long system prompt"),
+				msg(user, "question"),
+			},
+			budget: 1,
+			want:   []string{"a long system prompt", "question"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trimmed, dropped := Trim(wordTokenizer{}, tt.messages, tt.budget)
+			var got []string
+			for _, m := range trimmed {
+				got = append(got, m.Content)
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantDropped, dropped)
+			assert.Equal(t, len(tt.messages)-tt.wantDropped, len(trimmed))
+		})
+	}
+}
+
+func TestCountMessages(t *testing.T) {
+	messages := []provider.Message{msg(prompts.RoleUser, "two words"), msg(prompts.RoleAssistant, "")}
+	assert.Equal(t, 2+2*MessageOverhead, CountMessages(wordTokenizer{}, messages))
+}
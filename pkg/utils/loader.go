@@ -2,44 +2,151 @@ package utils
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"sync"
 	"time"
 )
 
 type SpinnerStyle struct {
+	Name    string
 	Frames  []string
 	Message string
 	Color   string
 	Speed   time.Duration
+	// Elapsed adds the time since the loader started, once it exceeds a
+	// second.
+	Elapsed bool
 }
 
 var (
 	Dots = SpinnerStyle{
+		Name:    "dots",
 		Frames:  []string{"⣾", "⣽", "⣻", "⢿", "⡿", "⣟", "⣯", "⣷"},
 		Speed:   100 * time.Millisecond,
 		Message: "Thinking",
 		Color:   "\033[32m",
+		Elapsed: true,
 	}
+	Line = SpinnerStyle{
+		Name:    "line",
+		Frames:  []string{"-", "\\", "|", "/"},
+		Speed:   120 * time.Millisecond,
+		Message: "Thinking",
+		Color:   "\033[36m",
+		Elapsed: true,
+	}
+	Arc = SpinnerStyle{
+		Name:    "arc",
+		Frames:  []string{"◜", "◠", "◝", "◞", "◡", "◟"},
+		Speed:   100 * time.Millisecond,
+		Message: "Thinking",
+		Color:   "\033[35m",
+		Elapsed: true,
+	}
+	// Timer shows only the message and the elapsed time.
+	Timer = SpinnerStyle{
+		Name:    "timer",
+		Frames:  []string{""},
+		Speed:   100 * time.Millisecond,
+		Message: "Waiting",
+		Color:   "\033[33m",
+		Elapsed: true,
+	}
+)
+
+// loaderDelay is how long a loader waits before drawing anything, so that
+// fast responses do not flash a spinner.
+const loaderDelay = 150 * time.Millisecond
+
+var (
+	stylesMu sync.RWMutex
+	styles   = map[string]SpinnerStyle{}
 )
 
+func init() {
+	for _, s := range []SpinnerStyle{Dots, Line, Arc, Timer} {
+		RegisterStyle(s)
+	}
+}
+
+// RegisterStyle makes a spinner style available by name, replacing any style
+// of the same name.
+func RegisterStyle(s SpinnerStyle) {
+	stylesMu.Lock()
+	defer stylesMu.Unlock()
+	styles[s.Name] = s
+}
+
+// LookupStyle returns the spinner style registered under name.
+func LookupStyle(name string) (SpinnerStyle, bool) {
+	stylesMu.RLock()
+	defer stylesMu.RUnlock()
+	s, ok := styles[name]
+	return s, ok
+}
+
+// StyleNames returns the names of all registered spinner styles, sorted.
+func StyleNames() []string {
+	stylesMu.RLock()
+	defer stylesMu.RUnlock()
+	names := make([]string, 0, len(styles))
+	for name := range styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoaderOptions controls where and whether a loader draws.
+type LoaderOptions struct {
+	// Out receives the spinner; it defaults to stderr. Nothing is drawn
+	// unless it is a terminal.
+	Out *os.File
+	// Quiet disables the spinner entirely.
+	Quiet bool
+	// NoColor draws the spinner without colors.
+	NoColor bool
+}
+
 type Loader struct {
+	out     io.Writer
 	stop    chan struct{}
+	done    chan struct{}
 	stopped bool
+	drawn   bool
 	mu      sync.Mutex
 	style   SpinnerStyle
+	color   bool
+	started time.Time
 }
 
-func InitLoader(style SpinnerStyle) *Loader {
+// StartLoader shows a spinner while the caller waits for a response. It
+// returns at once; the spinner appears only if the wait is noticeable, and
+// only on an interactive terminal, so redirected output and scripts never
+// see it.
+func StartLoader(style SpinnerStyle, opts LoaderOptions) *Loader {
+	out := opts.Out
+	if out == nil {
+		out = os.Stderr
+	}
+
 	loader := &Loader{
-		stop:  make(chan struct{}),
-		style: style,
+		out:     out,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		style:   style,
+		color:   !opts.NoColor && os.Getenv("NO_COLOR") == "",
+		started: time.Now(),
+	}
+	if opts.Quiet || !IsTerminal(out) || len(style.Frames) == 0 {
+		loader.stopped = true
+		close(loader.done)
+		return loader
 	}
-	time.Sleep(500 * time.Millisecond)
-	loader.Start()
-	time.Sleep(500 * time.Millisecond)
-	loader.SetMessage("Generating the answer")
-	time.Sleep(500 * time.Millisecond)
 
+	go loader.run()
 	return loader
 }
 
@@ -49,43 +156,48 @@ func (l *Loader) SetMessage(message string) {
 	l.mu.Unlock()
 }
 
-func (l *Loader) Start() {
-	l.mu.Lock()
-	if l.stopped {
-		l.mu.Unlock()
+func (l *Loader) run() {
+	defer close(l.done)
+
+	select {
+	case <-l.stop:
 		return
+	case <-time.After(loaderDelay):
 	}
-	l.mu.Unlock()
 
-	resetColor := "\033[0m"
-
-	go func() {
-		ticker := time.NewTicker(l.style.Speed)
-		defer ticker.Stop()
-
-		i := 0
-		for {
-			select {
-			case <-l.stop:
-				return
-			case <-ticker.C:
-				l.mu.Lock()
-				message := l.style.Message
-				l.mu.Unlock()
-
-				fmt.Print("\r\033[K")
-				fmt.Printf("%s%s %s%s",
-					l.style.Color,
-					message,
-					l.style.Frames[i%len(l.style.Frames)],
-					resetColor,
-				)
-				i++
-			}
+	ticker := time.NewTicker(l.style.Speed)
+	defer ticker.Stop()
+
+	for i := 0; ; i++ {
+		l.draw(i)
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
 		}
-	}()
+	}
 }
 
+func (l *Loader) draw(frame int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	line := l.style.Message
+	if f := l.style.Frames[frame%len(l.style.Frames)]; f != "" {
+		line += " " + f
+	}
+	if elapsed := time.Since(l.started); l.style.Elapsed && elapsed >= time.Second {
+		line += fmt.Sprintf(" %.1fs", elapsed.Seconds())
+	}
+	if l.color && l.style.Color != "" {
+		line = l.style.Color + line + "\033[0m"
+	}
+
+	fmt.Fprint(l.out, "\r\033[K"+line)
+	l.drawn = true
+}
+
+// Stop removes the spinner. It is safe to call more than once.
 func (l *Loader) Stop() {
 	l.mu.Lock()
 	if !l.stopped {
@@ -93,5 +205,12 @@ func (l *Loader) Stop() {
 		close(l.stop)
 	}
 	l.mu.Unlock()
-	fmt.Print("\r\033[K")
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.drawn {
+		fmt.Fprint(l.out, "\r\033[K")
+		l.drawn = false
+	}
 }